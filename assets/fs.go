@@ -1,13 +1,112 @@
 package assets
 
 import (
+	"bytes"
+	"fmt"
+	"io"
 	"net/http"
-
-	"github.com/go-serve/bindatafs"
-	"golang.org/x/tools/godoc/vfs/httpfs"
+	"os"
+	"path"
+	"strings"
+	"time"
 )
 
 // FileSystem returns a http.FileSystem of the assets
 func FileSystem() (fs http.FileSystem) {
-	return httpfs.New(bindatafs.New("assets://", Asset, AssetDir, AssetInfo))
+	return assetFS{}
+}
+
+// assetFS serves the assets compiled in by go-bindata
+type assetFS struct{}
+
+// Open implements http.FileSystem
+func (assetFS) Open(name string) (http.File, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if b, err := Asset(name); err == nil {
+		info, err := AssetInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		return &assetFile{Reader: bytes.NewReader(b), info: info}, nil
+	}
+	children, err := AssetDir(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: "/" + name, Err: os.ErrNotExist}
+	}
+	return &assetDir{name: name, children: children}, nil
+}
+
+// assetFile is an asset opened
+type assetFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+// Close implements http.File
+func (f *assetFile) Close() error {
+	return nil
+}
+
+// Readdir implements http.File
+func (f *assetFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("assets: %s is not a directory", f.info.Name())
+}
+
+// Stat implements http.File
+func (f *assetFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// assetDir is a directory of assets opened
+type assetDir struct {
+	name     string
+	children []string
+	read     int
+}
+
+// Close implements http.File
+func (d *assetDir) Close() error {
+	return nil
+}
+
+// Read implements http.File
+func (d *assetDir) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("assets: %s is a directory", d.name)
+}
+
+// Seek implements http.File
+func (d *assetDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
 }
+
+// Readdir implements http.File
+func (d *assetDir) Readdir(count int) (list []os.FileInfo, err error) {
+	for d.read < len(d.children) && (count <= 0 || len(list) < count) {
+		name := path.Join(d.name, d.children[d.read])
+		d.read++
+		info, err := AssetInfo(name)
+		if err != nil {
+			info = dirInfo(path.Base(name))
+		}
+		list = append(list, info)
+	}
+	if count > 0 && len(list) == 0 {
+		err = io.EOF
+	}
+	return
+}
+
+// Stat implements http.File
+func (d *assetDir) Stat() (os.FileInfo, error) {
+	return dirInfo(path.Base("/" + d.name)), nil
+}
+
+// dirInfo is the os.FileInfo of a directory of assets
+type dirInfo string
+
+func (fi dirInfo) Name() string       { return string(fi) }
+func (fi dirInfo) Size() int64        { return 0 }
+func (fi dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (fi dirInfo) ModTime() time.Time { return time.Time{} }
+func (fi dirInfo) IsDir() bool        { return true }
+func (fi dirInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,735 @@
+// Package config loads goserve settings from a YAML configuration file
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-serve/goserve/server"
+	"github.com/go-serve/goserve/server/accesslog"
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/compress"
+	"github.com/go-serve/goserve/server/glob"
+	"github.com/go-serve/goserve/server/ignore"
+	"github.com/go-serve/goserve/server/metrics"
+	"github.com/go-serve/goserve/server/proxy"
+	"github.com/go-serve/goserve/server/share"
+	"github.com/go-serve/goserve/server/thumb"
+	"github.com/go-serve/goserve/server/upload"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the complete settings of a goserve instance
+type Config struct {
+	// Listen addresses, e.g. ":8080" or "127.0.0.1:8443"
+	Listen []string `yaml:"listen"`
+
+	// Roots are the directories to serve
+	Roots []Root `yaml:"roots"`
+
+	TLS   TLS   `yaml:"tls"`
+	Auth  Auth  `yaml:"auth,omitempty"`
+	Modes Modes `yaml:"modes"`
+	Share Share `yaml:"share"`
+	Log   Log   `yaml:"log,omitempty"`
+
+	Metrics  Metrics  `yaml:"metrics,omitempty"`
+	Compress Compress `yaml:"compress"`
+	SPA      SPA      `yaml:"spa,omitempty"`
+	Upload   Upload   `yaml:"upload,omitempty"`
+	DAV      DAV      `yaml:"dav,omitempty"`
+	Thumbs   Thumbs   `yaml:"thumbs"`
+
+	// Proxy forwards URL path prefixes to backend servers
+	Proxy []ProxyRule `yaml:"proxy,omitempty"`
+
+	// Headers to set on every response
+	Headers map[string]string `yaml:"headers,omitempty"`
+
+	// Cache is either a duration (e.g. "1h") for the max-age of
+	// responses, or a literal Cache-Control header value
+	Cache string `yaml:"cache,omitempty"`
+
+	// Hidden are glob patterns of files and directories to hide
+	Hidden []string `yaml:"hidden,omitempty"`
+
+	// Dotfiles is the policy for files and directories with a name
+	// starting with ".": "show", "hide" (from listings) or "deny"
+	Dotfiles string `yaml:"dotfiles"`
+
+	// Gitignore hides files ignored by .gitignore files, in
+	// addition to .goserveignore files
+	Gitignore bool `yaml:"gitignore,omitempty"`
+
+	// Paths are per-path overrides, applied in order
+	Paths []PathRule `yaml:"paths,omitempty"`
+}
+
+// Root is a directory served under a URL path prefix
+type Root struct {
+	Dir    string `yaml:"dir"`
+	Prefix string `yaml:"prefix"`
+}
+
+// TLS configures HTTPS serving
+type TLS struct {
+	Cert  string `yaml:"cert,omitempty"`
+	Key   string `yaml:"key,omitempty"`
+	Auto  bool   `yaml:"auto"`
+	Cache string `yaml:"cache,omitempty"`
+
+	// Redirect is an address to listen for plain HTTP
+	// requests and redirect them to HTTPS
+	Redirect string `yaml:"redirect,omitempty"`
+}
+
+// Enabled reports if HTTPS is configured
+func (t TLS) Enabled() bool {
+	return t.Auto || t.Cert != "" || t.Key != ""
+}
+
+// Auth configures HTTP Basic authentication. Users of the top level
+// protect the whole site. Scopes override them for URL path prefixes.
+type Auth struct {
+	Realm    string `yaml:"realm,omitempty"`
+	Htpasswd string `yaml:"htpasswd,omitempty"`
+
+	// Users map user names to password hashes (bcrypt or {SHA})
+	// or plain text passwords
+	Users map[string]string `yaml:"users,omitempty"`
+
+	Scopes []AuthScope `yaml:"scopes,omitempty"`
+}
+
+// AuthScope protects a URL path prefix with its own users,
+// or makes it public
+type AuthScope struct {
+	Prefix   string            `yaml:"prefix"`
+	Realm    string            `yaml:"realm,omitempty"`
+	Htpasswd string            `yaml:"htpasswd,omitempty"`
+	Users    map[string]string `yaml:"users,omitempty"`
+	Public   bool              `yaml:"public,omitempty"`
+}
+
+// Modes enables or disables goserve features
+type Modes struct {
+	Live        bool `yaml:"live"`
+	API         bool `yaml:"api"`
+	VideoPlayer bool `yaml:"videoplayer"`
+	Archive     bool `yaml:"archive"`
+
+	// ArchiveDirs browses zip, tar and tar.gz files as directories
+	ArchiveDirs bool `yaml:"archivedirs"`
+
+	// Markdown renders Markdown files and READMEs as HTML
+	Markdown bool `yaml:"markdown"`
+
+	// SourceLinks links source files in listings to
+	// their syntax highlighted view
+	SourceLinks bool `yaml:"sourcelinks"`
+
+	// SiteFiles applies Netlify style _headers and _redirects files
+	SiteFiles bool `yaml:"sitefiles"`
+}
+
+// Share configures expiring share links
+type Share struct {
+	// Enabled serves share links, which bypass authentication,
+	// and the API to create them. Disabled by default.
+	Enabled bool `yaml:"enabled"`
+
+	// Key is the file of the secret key to sign links with.
+	// It is generated if missing.
+	Key string `yaml:"key,omitempty"`
+
+	// State is the file to keep download counts in
+	State string `yaml:"state,omitempty"`
+}
+
+// Log configures the access log and debug logs
+type Log struct {
+	// Access is the file to write the access log to,
+	// or "-" for the standard output
+	Access string `yaml:"access,omitempty"`
+
+	// Format is "common", "combined" (default) or "json"
+	Format string `yaml:"format,omitempty"`
+
+	// MaxSize in megabytes of the access log file before rotation
+	MaxSize int `yaml:"maxsize,omitempty"`
+
+	// MaxBackups is the number of rotated files to keep
+	MaxBackups int `yaml:"maxbackups,omitempty"`
+
+	// Debug logs accessed paths and API responses
+	Debug bool `yaml:"debug,omitempty"`
+}
+
+// Metrics configures the Prometheus metrics at "/_goserve/metrics"
+type Metrics struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Token allows requests with "Authorization: Bearer <token>"
+	Token string `yaml:"token,omitempty"`
+
+	// Allow are IP addresses or CIDR networks allowed to read
+	// the metrics. Only loopback addresses are allowed if neither
+	// token nor allow is set.
+	Allow []string `yaml:"allow,omitempty"`
+}
+
+// Access returns the access rules of the metrics
+func (m Metrics) Access() (access metrics.Access, err error) {
+	access.Token = m.Token
+	access.Allow, err = metrics.ParseNetworks(m.Allow...)
+	return
+}
+
+// Compress configures compression of responses
+type Compress struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MinSize in bytes of responses to compress
+	MinSize int `yaml:"minsize,omitempty"`
+
+	// Brotli enables brotli in addition to gzip
+	Brotli bool `yaml:"brotli"`
+}
+
+// Options returns the compression options
+func (c Compress) Options() compress.Options {
+	return compress.Options{
+		MinSize:  c.MinSize,
+		NoBrotli: !c.Brotli,
+	}
+}
+
+// DAV configures the WebDAV server
+type DAV struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Prefix is the URL path of the server (default: "/_goserve/dav")
+	Prefix string `yaml:"prefix,omitempty"`
+}
+
+// Thumbs configures thumbnails of images and the gallery view
+type Thumbs struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Cache is the directory to cache thumbnails in
+	// (default: "goserve/thumbs" in the user cache directory)
+	Cache string `yaml:"cache,omitempty"`
+
+	// Concurrency is the number of thumbnails generated
+	// at once (default: 2)
+	Concurrency int `yaml:"concurrency,omitempty"`
+}
+
+// Thumbnailer returns the generator of thumbnails
+func (t Thumbs) Thumbnailer() (*thumb.Thumbnailer, error) {
+	cache := t.Cache
+	if cache == "" {
+		dir, err := thumb.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		cache = dir
+	}
+	return thumb.New(thumb.Options{
+		Cache:       cache,
+		Concurrency: t.Concurrency,
+	}), nil
+}
+
+// Upload configures uploads of files
+type Upload struct {
+	Enabled bool `yaml:"enabled"`
+
+	// MaxSize in megabytes of an uploaded file, 0 for no limit
+	MaxSize int64 `yaml:"maxsize,omitempty"`
+
+	// Conflict is the policy for existing files:
+	// "overwrite", "rename" or "reject" (default)
+	Conflict string `yaml:"conflict,omitempty"`
+
+	// Paths where uploads are allowed. Anywhere if empty.
+	Paths []UploadPath `yaml:"paths,omitempty"`
+}
+
+// UploadPath allows uploads under a URL path prefix,
+// to the users if any
+type UploadPath struct {
+	Prefix string   `yaml:"prefix"`
+	Users  []string `yaml:"users,omitempty"`
+}
+
+// Options returns the upload options
+func (u Upload) Options() (opts upload.Options, err error) {
+	opts.MaxSize = u.MaxSize << 20
+	if u.Conflict != "" {
+		if opts.Conflict, err = upload.ParseConflict(u.Conflict); err != nil {
+			return
+		}
+	}
+	for _, p := range u.Paths {
+		opts.Permissions = append(opts.Permissions, upload.Permission{
+			Prefix: p.Prefix,
+			Users:  p.Users,
+		})
+	}
+	return
+}
+
+// SPA configures the single page application mode
+type SPA struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Root is the URL path of the application (default: "/")
+	Root string `yaml:"root,omitempty"`
+
+	// Fallback is the page served for unknown paths
+	// (default: "index.html" under root)
+	Fallback string `yaml:"fallback,omitempty"`
+
+	// Exclude are URL path prefixes never served the fallback
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
+// ProxyRule forwards requests under a URL path prefix to a backend
+type ProxyRule struct {
+	Prefix string `yaml:"prefix"`
+	Target string `yaml:"target"`
+
+	// Rewrite replaces the prefix in forwarded paths, e.g. "/"
+	Rewrite string `yaml:"rewrite,omitempty"`
+
+	// ChangeOrigin sets the Host header to the target host
+	ChangeOrigin bool `yaml:"changeorigin,omitempty"`
+
+	// Timeout to connect and to wait for response headers
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// PathRule overrides settings for URL paths matching a glob pattern
+type PathRule struct {
+	Match   string            `yaml:"match"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Cache   string            `yaml:"cache,omitempty"`
+}
+
+// Default returns the default settings
+func Default() *Config {
+	return &Config{
+		Listen: []string{":8080"},
+		Modes: Modes{
+			API:         true,
+			VideoPlayer: true,
+			Archive:     true,
+			ArchiveDirs: true,
+			Markdown:    true,
+			SiteFiles:   true,
+		},
+		Compress: Compress{
+			Enabled: true,
+			Brotli:  true,
+		},
+		Dotfiles: string(server.HiddenShow),
+	}
+}
+
+// Load reads the YAML configuration file on top of the default
+// settings. Relative paths in the file are resolved against the
+// directory of the file.
+func Load(filename string) (c *Config, err error) {
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	c = Default()
+	if err = yaml.UnmarshalStrict(b, c); err != nil {
+		err = fmt.Errorf("%s: %s", filename, err)
+		return nil, err
+	}
+
+	base := filepath.Dir(filename)
+	for i := range c.Roots {
+		c.Roots[i].Dir = resolve(base, c.Roots[i].Dir)
+	}
+	c.TLS.Cert = resolve(base, c.TLS.Cert)
+	c.TLS.Key = resolve(base, c.TLS.Key)
+	c.TLS.Cache = resolve(base, c.TLS.Cache)
+	c.Auth.Htpasswd = resolve(base, c.Auth.Htpasswd)
+	if c.Log.Access != "-" {
+		c.Log.Access = resolve(base, c.Log.Access)
+	}
+	c.Share.Key = resolve(base, c.Share.Key)
+	c.Share.State = resolve(base, c.Share.State)
+	for i := range c.Auth.Scopes {
+		c.Auth.Scopes[i].Htpasswd = resolve(base, c.Auth.Scopes[i].Htpasswd)
+	}
+	return
+}
+
+// resolve joins relative path to base
+func resolve(base, name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(base, name)
+}
+
+// Validate checks the settings and returns all the problems found
+func (c *Config) Validate() (errs []error) {
+
+	if len(c.Listen) == 0 {
+		errs = append(errs, fmt.Errorf("listen: no address to listen"))
+	}
+	for _, addr := range c.Listen {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			errs = append(errs, fmt.Errorf("listen: invalid address %#v", addr))
+		}
+	}
+
+	if len(c.Roots) == 0 {
+		errs = append(errs, fmt.Errorf("roots: no directory to serve"))
+	}
+	prefixes := make(map[string]bool)
+	for _, root := range c.Roots {
+		prefix := path.Clean("/" + root.Prefix)
+		if prefixes[prefix] {
+			errs = append(errs, fmt.Errorf("roots: duplicated prefix %#v", prefix))
+		}
+		prefixes[prefix] = true
+		if fi, err := os.Stat(root.Dir); err != nil {
+			errs = append(errs, fmt.Errorf("roots: %s", err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("roots: %#v is not a directory", root.Dir))
+		}
+	}
+
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		errs = append(errs, fmt.Errorf("tls: cert and key must be provided together"))
+	}
+	if c.TLS.Auto && c.TLS.Cert != "" {
+		errs = append(errs, fmt.Errorf("tls: auto cannot be used with cert and key"))
+	}
+	if c.TLS.Redirect != "" {
+		if !c.TLS.Enabled() {
+			errs = append(errs, fmt.Errorf("tls: redirect requires HTTPS to be enabled"))
+		}
+		if _, _, err := net.SplitHostPort(c.TLS.Redirect); err != nil {
+			errs = append(errs, fmt.Errorf("tls: invalid redirect address %#v", c.TLS.Redirect))
+		}
+	}
+
+	if _, err := c.AuthScopes(); err != nil {
+		errs = append(errs, fmt.Errorf("auth: %s", err))
+	}
+	for i, scope := range c.Auth.Scopes {
+		if scope.Prefix == "" {
+			errs = append(errs, fmt.Errorf("auth.scopes[%d]: prefix is required", i))
+		}
+		if scope.Public && (scope.Htpasswd != "" || len(scope.Users) > 0) {
+			errs = append(errs, fmt.Errorf("auth.scopes[%d]: public scope cannot have users", i))
+		}
+	}
+
+	if _, err := c.Log.AccessFormat(); err != nil {
+		errs = append(errs, fmt.Errorf("log: %s", err))
+	}
+	if c.Log.MaxSize < 0 || c.Log.MaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("log: maxsize and maxbackups cannot be negative"))
+	}
+
+	if c.Compress.MinSize < 0 {
+		errs = append(errs, fmt.Errorf("compress: minsize cannot be negative"))
+	}
+	if c.DAV.Prefix != "" && path.Clean("/"+c.DAV.Prefix) == "/" {
+		errs = append(errs, fmt.Errorf("dav: prefix cannot be the root"))
+	}
+	if _, err := c.Upload.Options(); err != nil {
+		errs = append(errs, fmt.Errorf("upload: %s", err))
+	}
+	if c.Upload.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("upload: maxsize cannot be negative"))
+	}
+	if _, err := c.ProxyRules(); err != nil {
+		errs = append(errs, fmt.Errorf("proxy: %s", err))
+	}
+	if _, err := c.Metrics.Access(); err != nil {
+		errs = append(errs, fmt.Errorf("metrics: %s", err))
+	}
+
+	for _, pattern := range c.Hidden {
+		if _, err := glob.Compile(pattern); err != nil {
+			errs = append(errs, fmt.Errorf("hidden: %s", err))
+		}
+	}
+	if _, err := server.ParseHiddenPolicy(c.Dotfiles); err != nil {
+		errs = append(errs, fmt.Errorf("dotfiles: %s", err))
+	}
+	if _, err := CacheControl(c.Cache); err != nil {
+		errs = append(errs, fmt.Errorf("cache: %s", err))
+	}
+	for i, rule := range c.Paths {
+		if _, err := glob.Compile(rule.Match); err != nil {
+			errs = append(errs, fmt.Errorf("paths[%d]: %s", i, err))
+		}
+		if _, err := CacheControl(rule.Cache); err != nil {
+			errs = append(errs, fmt.Errorf("paths[%d].cache: %s", i, err))
+		}
+	}
+	return
+}
+
+// CacheControl converts a cache setting into Cache-Control header value.
+// A duration becomes "public, max-age=<seconds>". Any other value
+// is used as is.
+func CacheControl(value string) (header string, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if value[0] < '0' || value[0] > '9' {
+		header = value
+		return
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return
+	}
+	header = fmt.Sprintf("public, max-age=%d", int64(d/time.Second))
+	return
+}
+
+// HeaderRules returns the header and cache settings
+// as server header rules
+func (c *Config) HeaderRules() (rules []server.HeaderRule, err error) {
+
+	rule, err := headerRule(nil, c.Headers, c.Cache)
+	if err != nil {
+		return
+	}
+	if len(rule.Header) > 0 {
+		rules = append(rules, rule)
+	}
+
+	for _, pathRule := range c.Paths {
+		var pattern *glob.Pattern
+		if pattern, err = glob.Compile(pathRule.Match); err != nil {
+			return
+		}
+		if rule, err = headerRule(pattern, pathRule.Headers, pathRule.Cache); err != nil {
+			return
+		}
+		if len(rule.Header) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return
+}
+
+func headerRule(pattern *glob.Pattern, headers map[string]string, cache string) (rule server.HeaderRule, err error) {
+	rule = server.HeaderRule{
+		Pattern: pattern,
+		Header:  make(http.Header),
+	}
+	for key, value := range headers {
+		rule.Header.Set(key, value)
+	}
+	cacheControl, err := CacheControl(cache)
+	if err != nil {
+		return
+	}
+	if cacheControl != "" {
+		rule.Header.Set("Cache-Control", cacheControl)
+	}
+	return
+}
+
+// AuthScopes returns the authentication settings as scopes,
+// with users loaded from htpasswd files
+func (c *Config) AuthScopes() (scopes []auth.Scope, err error) {
+
+	users, err := loadUsers(c.Auth.Htpasswd, c.Auth.Users)
+	if err != nil {
+		return
+	}
+	if len(users) > 0 {
+		scopes = append(scopes, auth.Scope{
+			Prefix: "/",
+			Realm:  c.Auth.Realm,
+			Users:  users,
+		})
+	}
+
+	for _, s := range c.Auth.Scopes {
+		scope := auth.Scope{
+			Prefix: s.Prefix,
+			Realm:  s.Realm,
+		}
+		if scope.Realm == "" {
+			scope.Realm = c.Auth.Realm
+		}
+		if !s.Public {
+			if scope.Users, err = loadUsers(s.Htpasswd, s.Users); err != nil {
+				return
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+	return
+}
+
+// loadUsers merges users of a htpasswd file with inline users
+func loadUsers(htpasswd string, inline map[string]string) (users auth.Users, err error) {
+	users = make(auth.Users)
+	if htpasswd != "" {
+		if users, err = auth.LoadHtpasswd(htpasswd); err != nil {
+			return
+		}
+	}
+	for user, hash := range inline {
+		users[user] = hash
+	}
+	return
+}
+
+// Sharer returns the Sharer to sign share links with. Without a key
+// file, the key is kept in the user configuration directory and the
+// download counts next to it.
+func (s Share) Sharer() (sharer *share.Sharer, err error) {
+	keyFile := s.Key
+	if keyFile == "" {
+		if keyFile, err = share.DefaultKeyFile(); err != nil {
+			return
+		}
+	}
+	key, err := share.LoadKey(keyFile)
+	if err != nil {
+		return
+	}
+	sharer = share.New(key)
+
+	stateFile := s.State
+	if stateFile == "" && s.Key == "" {
+		stateFile = filepath.Join(filepath.Dir(keyFile), "share-downloads.json")
+	}
+	if stateFile != "" {
+		err = sharer.SetStateFile(stateFile)
+	}
+	return
+}
+
+// AccessFormat returns the format of the access log
+func (l Log) AccessFormat() (accesslog.Format, error) {
+	if l.Format == "" {
+		return accesslog.Combined, nil
+	}
+	return accesslog.ParseFormat(l.Format)
+}
+
+// AccessLog opens the access log. It returns nil if
+// the access log is not enabled.
+func (l Log) AccessLog() (logger *accesslog.Logger, err error) {
+	if l.Access == "" {
+		return
+	}
+	format, err := l.AccessFormat()
+	if err != nil {
+		return
+	}
+	if l.Access == "-" {
+		logger = accesslog.New(os.Stdout, format)
+		return
+	}
+	f, err := accesslog.OpenFile(l.Access, int64(l.MaxSize)<<20, l.MaxBackups)
+	if err != nil {
+		return
+	}
+	logger = accesslog.New(f, format)
+	return
+}
+
+// ProxyRules returns the proxy settings as rules
+func (c *Config) ProxyRules() (rules []proxy.Rule, err error) {
+	for _, r := range c.Proxy {
+		rule := proxy.Rule{
+			Prefix:       r.Prefix,
+			Rewrite:      r.Rewrite,
+			ChangeOrigin: r.ChangeOrigin,
+		}
+		if !strings.HasPrefix(r.Prefix, "/") {
+			err = fmt.Errorf("prefix %#v must start with \"/\"", r.Prefix)
+			return
+		}
+		if rule.Target, err = proxy.ParseTarget(r.Target); err != nil {
+			return
+		}
+		if r.Timeout != "" {
+			if rule.Timeout, err = time.ParseDuration(r.Timeout); err != nil {
+				return
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// HidePatterns returns the compiled hidden patterns
+func (c *Config) HidePatterns() (patterns []*glob.Pattern, err error) {
+	patterns = make([]*glob.Pattern, len(c.Hidden))
+	for i, pattern := range c.Hidden {
+		if patterns[i], err = glob.Compile(pattern); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// IgnoreFiles returns the names of ignore files to honour
+func (c *Config) IgnoreFiles() []string {
+	if c.Gitignore {
+		return []string{ignore.GoserveIgnore, ignore.GitIgnore}
+	}
+	return []string{ignore.GoserveIgnore}
+}
+
+// String returns the settings in YAML format, with passwords redacted
+func (c *Config) String() string {
+	redacted := *c
+	redacted.Auth.Users = redactUsers(c.Auth.Users)
+	if c.Metrics.Token != "" {
+		redacted.Metrics.Token = "<redacted>"
+	}
+	redacted.Auth.Scopes = make([]AuthScope, len(c.Auth.Scopes))
+	for i, scope := range c.Auth.Scopes {
+		scope.Users = redactUsers(scope.Users)
+		redacted.Auth.Scopes[i] = scope
+	}
+	b, err := yaml.Marshal(redacted)
+	if err != nil {
+		return err.Error()
+	}
+	return string(b)
+}
+
+func redactUsers(users map[string]string) map[string]string {
+	if users == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(users))
+	for user := range users {
+		redacted[user] = "<redacted>"
+	}
+	return redacted
+}
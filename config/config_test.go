@@ -0,0 +1,193 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/config"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "goserve.yaml")
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return filename
+}
+
+func TestLoad(t *testing.T) {
+
+	example, err := filepath.Abs("./../_example")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	filename := writeConfig(t, `
+listen: [":9000", "127.0.0.1:9001"]
+roots:
+  - dir: `+example+`
+    prefix: /
+  - dir: tls
+    prefix: /tls
+modes:
+  live: true
+headers:
+  X-Frame-Options: DENY
+cache: 10m
+hidden: [".git", "*.secret"]
+paths:
+  - match: "*.css"
+    cache: 1h
+  - match: "/api/**"
+    cache: no-store
+    headers:
+      Access-Control-Allow-Origin: "*"
+`)
+
+	conf, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "127.0.0.1:9001", conf.Listen[1]; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := filepath.Join(filepath.Dir(filename), "tls"), conf.Roots[1].Dir; want != have {
+		t.Errorf("expected relative dir resolved to %#v, got %#v", want, have)
+	}
+	if !conf.Modes.Live || !conf.Modes.API || !conf.Modes.VideoPlayer {
+		t.Errorf("unexpected modes: %#v", conf.Modes)
+	}
+
+	// the "tls" root does not exist
+	errs := conf.Validate()
+	if want, have := 1, len(errs); want != have {
+		t.Fatalf("expected %d error, got %#v", want, errs)
+	}
+	if !strings.HasPrefix(errs[0].Error(), "roots: ") {
+		t.Errorf("unexpected error: %s", errs[0])
+	}
+
+	rules, err := conf.HeaderRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 3, len(rules); want != have {
+		t.Fatalf("expected %d rules, got %d", want, have)
+	}
+	if want, have := "public, max-age=600", rules[0].Header.Get("Cache-Control"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "DENY", rules[0].Header.Get("X-Frame-Options"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "public, max-age=3600", rules[1].Header.Get("Cache-Control"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if !rules[1].Pattern.Match("/assets/style.css") {
+		t.Errorf("expected rule to match")
+	}
+	if want, have := "no-store", rules[2].Header.Get("Cache-Control"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	filename := writeConfig(t, `
+listen: ["8080"]
+roots:
+  - dir: .
+    prefix: /foo
+  - dir: .
+    prefix: /foo/
+tls:
+  cert: cert.pem
+  redirect: ":80"
+hidden: ["[abc"]
+paths:
+  - match: "*.js"
+    cache: 1x
+`)
+
+	conf, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	messages := make([]string, 0)
+	for _, err := range conf.Validate() {
+		messages = append(messages, err.Error())
+	}
+	for _, expected := range []string{
+		`listen: invalid address "8080"`,
+		`roots: duplicated prefix "/foo"`,
+		`tls: cert and key must be provided together`,
+		`hidden: glob: unterminated character class in "[abc"`,
+		`paths[0].cache: time: unknown unit "x" in duration "1x"`,
+	} {
+		found := false
+		for _, message := range messages {
+			found = found || message == expected
+		}
+		if !found {
+			t.Errorf("expected error %#v in %#v", expected, messages)
+		}
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	filename := writeConfig(t, "listen: [\":8080\"]\nunknown: true\n")
+	if _, err := config.Load(filename); err == nil {
+		t.Errorf("expected error for unknown field")
+	}
+}
+
+func TestAuthScopes(t *testing.T) {
+
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "htpasswd"),
+		[]byte("bob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	filename := filepath.Join(dir, "goserve.yaml")
+	if err := ioutil.WriteFile(filename, []byte(`
+auth:
+  realm: office
+  users:
+    alice: wonderland
+  scopes:
+    - prefix: /team
+      htpasswd: htpasswd
+    - prefix: /public
+      public: true
+`), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	conf, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	scopes, err := conf.AuthScopes()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 3, len(scopes); want != have {
+		t.Fatalf("expected %d scopes, got %d", want, have)
+	}
+	if !scopes[0].Users.Authenticate("alice", "wonderland") || scopes[0].Realm != "office" {
+		t.Errorf("unexpected root scope: %#v", scopes[0])
+	}
+	if !scopes[1].Users.Authenticate("bob", "secret") || scopes[1].Prefix != "/team" {
+		t.Errorf("unexpected team scope: %#v", scopes[1])
+	}
+	if len(scopes[2].Users) != 0 {
+		t.Errorf("expected public scope, got %#v", scopes[2])
+	}
+
+	if strings.Contains(conf.String(), "wonderland") {
+		t.Errorf("password not redacted:\n%s", conf)
+	}
+}
@@ -1,86 +1,620 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-serve/goserve/config"
 	"github.com/go-serve/goserve/server"
+	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/dav"
+	"github.com/go-serve/goserve/server/livereload"
+	"github.com/go-serve/goserve/server/metrics"
+	"github.com/go-serve/goserve/server/proxy"
+	"github.com/go-serve/goserve/server/tlsauto"
+	"github.com/go-serve/goserve/server/vfs"
 )
 
-var port *uint64
-var dir string
-
-func init() {
+// loadConfig parses the command line arguments into settings.
+// Flags and directory arguments override the configuration file.
+func loadConfig(fs *flag.FlagSet, args []string) (conf *config.Config, err error) {
 
 	var envPort uint64 = 8080 // default port
-	var err error
 
 	// parse env for PORT parameter
 	if envPortStr := os.Getenv("PORT"); envPortStr != "" {
 		envPort, err = strconv.ParseUint(envPortStr, 10, 16)
 		if err != nil {
-			log.Fatalf(
+			err = fmt.Errorf(
 				"Cannot parse \"%s\" as PORT. Must be unsigned integer", envPortStr)
+			return
 		}
 	}
 
 	// flags, if any provided, may override the default
-	port = flag.Uint64("port", envPort, "Determine the port to serve")
-	flag.Parse()
+	configFile := fs.String("config", "", "Load settings from the YAML configuration file")
+	port := fs.Uint64("port", envPort, "Determine the port to serve")
+	tlsCert := fs.String("tls-cert", "", "Serve HTTPS with the given certificate file (PEM)")
+	tlsKey := fs.String("tls-key", "", "Private key file (PEM) for -tls-cert")
+	tlsAuto := fs.Bool("tls-auto", false, "Serve HTTPS with a generated certificate for localhost and LAN addresses")
+	tlsCache := fs.String("tls-cache", "", "Directory to cache the generated CA and certificate for -tls-auto")
+	redirectPort := fs.Uint64("tls-redirect-port", 0, "Also listen to this port for HTTP and redirect to HTTPS")
+	live := fs.Bool("live", false, "Reload pages in browser when files in the directory change")
+	shareOn := fs.Bool("share", false, "Serve expiring share links, which bypass authentication, and the API to create them")
+	shareKey := fs.String("share-key", "", "Secret key file to sign share links with")
+	accessLog := fs.String("access-log", "", "Write access log to the file, or \"-\" for standard output")
+	accessLogFormat := fs.String("access-log-format", "", "Access log format: common, combined (default) or json")
+	archiveOn := fs.Bool("archive", true, "Download directories as zip or tar.gz archives with ?download=zip")
+	archiveDirs := fs.Bool("archive-dirs", true, "Browse zip, tar and tar.gz files as directories, e.g. /release.tar.gz/")
+	markdownOn := fs.Bool("markdown", true, "Render Markdown files, and READMEs below listings, as HTML")
+	sourceLinks := fs.Bool("source-links", false, "Link source files in listings to their syntax highlighted ?mode=source view")
+	siteFiles := fs.Bool("site-files", true, "Apply _headers and _redirects files at the root like Netlify")
+	spa := fs.Bool("spa", false, "Serve the fallback page for unknown paths of a single page application")
+	spaFallback := fs.String("spa-fallback", "", "Fallback page for -spa (default \"/index.html\")")
+	spaExclude := fs.String("spa-exclude", "", "Comma separated URL path prefixes never served the fallback page, e.g. /api")
+	compress := fs.Bool("compress", true, "Compress responses with gzip or brotli")
+	metricsOn := fs.Bool("metrics", false, "Serve Prometheus metrics at /_goserve/metrics")
+	metricsToken := fs.String("metrics-token", "", "Bearer token required to read the metrics")
+	metricsAllow := fs.String("metrics-allow", "", "Comma separated IP addresses or CIDR networks allowed to read the metrics")
+	debug := fs.Bool("debug", false, "Log accessed paths and API responses for debugging")
+	davOn := fs.Bool("dav", false, "Serve WebDAV at /_goserve/dav, writable where uploads are allowed")
+	thumbsOn := fs.Bool("thumbs", false, "Serve thumbnails of images at /_goserve/thumb and galleries with ?mode=gallery")
+	thumbsCache := fs.String("thumbs-cache", "", "Directory to cache thumbnails in (default: goserve/thumbs in the user cache directory)")
+	uploadOn := fs.Bool("upload", false, "Accept file uploads with forms, PUT requests and the tus protocol")
+	uploadMaxSize := fs.Int64("upload-max-size", 0, "Maximum size in megabytes of an uploaded file, 0 for no limit")
+	uploadConflict := fs.String("upload-conflict", "", "Policy for uploads to existing files: overwrite, rename or reject (default)")
+	hidden := fs.String("hidden", string(server.HiddenShow), "Policy for dotfiles: show, hide (from listings) or deny")
+	gitignore := fs.Bool("gitignore", false, "Also hide files ignored by .gitignore files, besides .goserveignore")
+	htpasswd := fs.String("htpasswd", "", "Require HTTP Basic authentication with users in the htpasswd file")
+	var proxies proxyFlags
+	fs.Var(&proxies, "proxy", "Forward requests under a path to a backend, e.g. /api=http://localhost:3000 (repeatable)")
+	var users userFlags
+	fs.Var(&users, "auth", "Require HTTP Basic authentication with user:password (repeatable)")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	conf = config.Default()
+	if *configFile != "" {
+		if conf, err = config.Load(*configFile); err != nil {
+			return
+		}
+	}
+	if *configFile == "" {
+		conf.Listen = []string{fmt.Sprintf(":%d", *port)}
+	}
+
+	// override with flags explicitly set
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			conf.Listen = []string{fmt.Sprintf(":%d", *port)}
+		case "tls-cert":
+			conf.TLS.Cert = *tlsCert
+		case "tls-key":
+			conf.TLS.Key = *tlsKey
+		case "tls-auto":
+			conf.TLS.Auto = *tlsAuto
+		case "tls-cache":
+			conf.TLS.Cache = *tlsCache
+		case "tls-redirect-port":
+			conf.TLS.Redirect = fmt.Sprintf(":%d", *redirectPort)
+		case "live":
+			conf.Modes.Live = *live
+		case "share":
+			conf.Share.Enabled = *shareOn
+		case "share-key":
+			conf.Share.Key = *shareKey
+		case "access-log":
+			conf.Log.Access = *accessLog
+		case "access-log-format":
+			conf.Log.Format = *accessLogFormat
+		case "archive":
+			conf.Modes.Archive = *archiveOn
+		case "archive-dirs":
+			conf.Modes.ArchiveDirs = *archiveDirs
+		case "markdown":
+			conf.Modes.Markdown = *markdownOn
+		case "source-links":
+			conf.Modes.SourceLinks = *sourceLinks
+		case "site-files":
+			conf.Modes.SiteFiles = *siteFiles
+		case "spa":
+			conf.SPA.Enabled = *spa
+		case "spa-fallback":
+			conf.SPA.Fallback = *spaFallback
+		case "spa-exclude":
+			conf.SPA.Exclude = strings.Split(*spaExclude, ",")
+		case "compress":
+			conf.Compress.Enabled = *compress
+		case "metrics":
+			conf.Metrics.Enabled = *metricsOn
+		case "metrics-token":
+			conf.Metrics.Token = *metricsToken
+		case "metrics-allow":
+			conf.Metrics.Allow = strings.Split(*metricsAllow, ",")
+		case "debug":
+			conf.Log.Debug = *debug
+		case "dav":
+			conf.DAV.Enabled = *davOn
+		case "thumbs":
+			conf.Thumbs.Enabled = *thumbsOn
+		case "thumbs-cache":
+			conf.Thumbs.Cache = *thumbsCache
+		case "upload":
+			conf.Upload.Enabled = *uploadOn
+		case "upload-max-size":
+			conf.Upload.MaxSize = *uploadMaxSize
+		case "upload-conflict":
+			conf.Upload.Conflict = *uploadConflict
+		case "hidden":
+			conf.Dotfiles = *hidden
+		case "gitignore":
+			conf.Gitignore = *gitignore
+		case "htpasswd":
+			conf.Auth.Htpasswd = *htpasswd
+		case "proxy":
+			for _, rule := range proxies {
+				conf.Proxy = append(conf.Proxy, config.ProxyRule{
+					Prefix: rule.Prefix,
+					Target: rule.Target.String(),
+				})
+			}
+		case "auth":
+			if conf.Auth.Users == nil {
+				conf.Auth.Users = make(map[string]string)
+			}
+			for _, user := range users {
+				parts := strings.SplitN(user, ":", 2)
+				conf.Auth.Users[parts[0]] = parts[1]
+			}
+		}
+	})
 
-	// read directory from remaining argument
+	// read directories from remaining arguments
 	// or use current directory
-	if flag.NArg() == 1 {
-		dir = flag.Arg(0)
-	} else if flag.NArg() > 1 {
-		log.Fatalf("Too many argument. goserve can only serve one directory")
-	} else {
-		// get current dir
-		dir, err = os.Getwd()
-		if err != nil {
-			log.Fatalf("Failed to parse current path: %s", err.Error())
+	if fs.NArg() > 0 {
+		conf.Roots = nil
+	}
+	for i := 0; i < fs.NArg(); i++ {
+		conf.Roots = append(conf.Roots, parseRoot(fs.Arg(i), fs.NArg() == 1))
+	}
+	if len(conf.Roots) == 0 {
+		var dir string
+		if dir, err = os.Getwd(); err != nil {
+			err = fmt.Errorf("Failed to parse current path: %s", err.Error())
+			return
 		}
+		conf.Roots = []config.Root{{Dir: dir, Prefix: "/"}}
 	}
+	return
+}
+
+// userFlags collects repeated "-auth user:password" flags
+type userFlags []string
 
+// String implements flag.Value
+func (users *userFlags) String() string {
+	return strings.Join(*users, ",")
 }
 
-// test if a path is a valid directory
-func validDir(path string) (err error) {
-	var f *os.File
-	var fi os.FileInfo
-	if f, err = os.Open(dir); err != nil {
-		return
+// Set implements flag.Value
+func (users *userFlags) Set(value string) error {
+	if i := strings.Index(value, ":"); i <= 0 {
+		return fmt.Errorf("expected user:password, got %#v", value)
 	}
-	if fi, err = f.Stat(); err != nil {
+	*users = append(*users, value)
+	return nil
+}
+
+// proxyFlags collects repeated "-proxy /prefix=http://host:port" flags
+type proxyFlags []proxy.Rule
+
+// String implements flag.Value
+func (rules *proxyFlags) String() string {
+	values := make([]string, len(*rules))
+	for i, rule := range *rules {
+		values[i] = rule.Prefix + "=" + rule.Target.String()
+	}
+	return strings.Join(values, ",")
+}
+
+// Set implements flag.Value
+func (rules *proxyFlags) Set(value string) error {
+	rule, err := proxy.ParseRule(value)
+	if err != nil {
+		return err
+	}
+	*rules = append(*rules, rule)
+	return nil
+}
+
+// parseRoot parses a directory argument in the form of "dir:/prefix".
+// Without the prefix, a single directory is served at the root and
+// multiple directories are mounted by their base names.
+func parseRoot(arg string, single bool) (root config.Root) {
+	if i := strings.LastIndex(arg, ":/"); i > 0 {
+		root.Dir, root.Prefix = arg[:i], path.Clean(arg[i+1:])
 		return
 	}
-	if !fi.IsDir() {
-		err = fmt.Errorf("Path \"%s\" is not a valid directory", path)
+	root.Dir, root.Prefix = arg, "/"
+	if !single {
+		root.Prefix = "/" + filepath.Base(arg)
+	}
+	return
+}
+
+func fileServer(conf *config.Config, m *metrics.Metrics) (http.Handler, error) {
+
+	var root http.FileSystem
+	if len(conf.Roots) == 1 && path.Clean("/"+conf.Roots[0].Prefix) == "/" {
+		root = vfs.Dir(conf.Roots[0].Dir)
+	} else {
+		table := make([]vfs.Mount, len(conf.Roots))
+		for i, r := range conf.Roots {
+			table[i] = vfs.Mount{Prefix: r.Prefix, FS: vfs.Dir(r.Dir)}
+		}
+		mt, err := vfs.NewMounts(table...)
+		if err != nil {
+			return nil, err
+		}
+		root = mt
+	}
+
+	options := []server.Option{
+		server.WithAPI(conf.Modes.API),
+		server.WithVideoPlayer(conf.Modes.VideoPlayer),
+		server.WithArchive(conf.Modes.Archive),
+		server.WithArchiveDirs(conf.Modes.ArchiveDirs),
+		server.WithMarkdown(conf.Modes.Markdown),
+		server.WithSourceLinks(conf.Modes.SourceLinks),
+		server.WithSiteFiles(conf.Modes.SiteFiles),
+	}
+
+	rules, err := conf.HeaderRules()
+	if err != nil {
+		return nil, err
+	}
+	options = append(options, server.WithHeaders(rules...))
+
+	scopes, err := conf.AuthScopes()
+	if err != nil {
+		return nil, err
+	}
+	if len(scopes) > 0 {
+		options = append(options, server.WithAuth(scopes...))
+	}
+
+	if len(conf.Hidden) > 0 {
+		patterns, err := conf.HidePatterns()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, server.WithHidden(vfs.HidePatterns(patterns...)))
+	}
+
+	policy, err := server.ParseHiddenPolicy(conf.Dotfiles)
+	if err != nil {
+		return nil, err
+	}
+	options = append(options,
+		server.WithHiddenPolicy(policy),
+		server.WithIgnoreFiles(conf.IgnoreFiles()...))
+
+	accessLog, err := conf.Log.AccessLog()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open access log: %s", err)
+	}
+	if accessLog != nil {
+		options = append(options, server.WithAccessLog(accessLog))
+	}
+	if conf.Upload.Enabled {
+		uploadOptions, err := conf.Upload.Options()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, server.WithUpload(uploadOptions))
+	}
+	if conf.Thumbs.Enabled {
+		thumbnailer, err := conf.Thumbs.Thumbnailer()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to setup thumbnails: %s", err)
+		}
+		options = append(options, server.WithThumbnails(thumbnailer))
+	}
+	if conf.DAV.Enabled {
+		prefix := conf.DAV.Prefix
+		if prefix == "" {
+			prefix = dav.DefaultPrefix
+		}
+		options = append(options, server.WithDAV(prefix))
+	}
+	proxyRules, err := conf.ProxyRules()
+	if err != nil {
+		return nil, err
+	}
+	if len(proxyRules) > 0 {
+		options = append(options, server.WithProxy(proxyRules...))
+	}
+	if conf.SPA.Enabled {
+		options = append(options, server.WithSPA(server.SPA{
+			Root:     conf.SPA.Root,
+			Fallback: conf.SPA.Fallback,
+			Exclude:  conf.SPA.Exclude,
+		}))
+	}
+	if conf.Compress.Enabled {
+		options = append(options, server.WithCompression(conf.Compress.Options()))
+	}
+	if m != nil {
+		access, err := conf.Metrics.Access()
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, server.WithMetrics(m, access))
+	}
+	if conf.Log.Debug {
+		options = append(options, server.WithDebugLog(log.New(os.Stderr, "debug: ", log.LstdFlags)))
+	}
+
+	if conf.Share.Enabled {
+		sharer, err := conf.Share.Sharer()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to setup share links: %s", err)
+		}
+		options = append(options, server.WithShare(sharer))
+	}
+
+	if conf.Modes.Live {
+		lr, err := livereload.New("")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to start live reload: %s", err)
+		}
+		for _, r := range conf.Roots {
+			if err := lr.Watch(r.Dir, r.Prefix); err != nil {
+				return nil, fmt.Errorf("Failed to watch %s for live reload: %s", r.Dir, err)
+			}
+		}
+		options = append(options, server.WithLiveReload(lr))
+	}
+	return server.FileServer(root, options...), nil
+}
+
+// tlsConfig returns the TLS configuration from settings,
+// or nil if HTTPS is not enabled
+func tlsConfig(conf config.TLS) (tlsConf *tls.Config, err error) {
+
+	var cert tls.Certificate
+
+	switch {
+	case conf.Cert != "" || conf.Key != "":
+		if cert, err = tls.LoadX509KeyPair(conf.Cert, conf.Key); err != nil {
+			return
+		}
+	case conf.Auto:
+		cacheDir := conf.Cache
+		if cacheDir == "" {
+			if cacheDir, err = tlsauto.DefaultCacheDir(); err != nil {
+				return
+			}
+		}
+		if cert, err = tlsauto.Load(cacheDir, tlsauto.LocalHosts()); err != nil {
+			return
+		}
+		log.Printf("Using generated certificate. Trust %s to avoid browser warning",
+			tlsauto.CACertPath(cacheDir))
+	default:
 		return
 	}
+
+	tlsConf = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
 	return
 }
 
-func fileServer(root string) http.Handler {
-	return server.FileServer(http.Dir(root))
+// configCommand runs the "goserve config" sub-commands
+func configCommand(args []string) int {
+
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintf(os.Stderr, "Usage: goserve config check [-config goserve.yaml] [flags] [dir ...]\n")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("goserve config check", flag.ContinueOnError)
+	conf, err := loadConfig(fs, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if errs := conf.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		}
+		return 1
+	}
+
+	fmt.Printf("# effective settings\n%s", conf)
+	return 0
+}
+
+// shareCommand runs "goserve share", which prints an expiring
+// link to a file or directory served by goserve
+func shareCommand(args []string) int {
+
+	fs := flag.NewFlagSet("goserve share", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: goserve share [flags] <path>\n\n"+
+			"The path is either a file in a served directory or a URL path.\n\n")
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", "", "Load settings from the YAML configuration file")
+	shareKey := fs.String("share-key", "", "Secret key file to sign share links with")
+	ttl := fs.Duration("ttl", api.DefaultShareTTL, "Duration before the link expires")
+	maxDownloads := fs.Int("max-downloads", 0, "Number of downloads allowed (0: unlimited)")
+	password := fs.String("password", "", "Password required to use the link")
+	baseURL := fs.String("base-url", "", "URL of the server (default: from the listen address)")
+
+	// flags may come before or after the path
+	var target string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 || target != "" {
+			break
+		}
+		target, args = fs.Arg(0), fs.Args()[1:]
+	}
+	if target == "" || fs.NArg() > 0 || *ttl <= 0 || *maxDownloads < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	conf := config.Default()
+	if *configFile != "" {
+		var err error
+		if conf, err = config.Load(*configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			return 1
+		}
+	}
+	if *shareKey != "" {
+		conf.Share.Key = *shareKey
+	}
+	if len(conf.Roots) == 0 {
+		dir, _ := os.Getwd()
+		conf.Roots = []config.Root{{Dir: dir, Prefix: "/"}}
+	}
+
+	sharer, err := conf.Share.Sharer()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+	link, err := sharer.Sign(sharePath(conf.Roots, target), *ttl, *maxDownloads, *password)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return 1
+	}
+
+	if *baseURL == "" {
+		*baseURL = defaultBaseURL(conf)
+	}
+	fmt.Println(link.URL(*baseURL))
+	fmt.Fprintf(os.Stderr, "expires: %s\n", link.Expires.Format(time.RFC1123))
+	return 0
+}
+
+// sharePath maps a file in a served directory to its URL path.
+// Any other target is taken as a URL path.
+func sharePath(roots []config.Root, target string) string {
+	abs, err := filepath.Abs(target)
+	if _, statErr := os.Stat(target); err != nil || statErr != nil {
+		return path.Clean("/" + target)
+	}
+	for _, r := range roots {
+		dir, err := filepath.Abs(r.Dir)
+		if err != nil || !vfs.Contains(dir, abs) {
+			continue
+		}
+		rel, _ := filepath.Rel(dir, abs)
+		return path.Join("/", r.Prefix, filepath.ToSlash(rel))
+	}
+	return path.Clean("/" + target)
+}
+
+// defaultBaseURL returns the URL of the first listen address
+func defaultBaseURL(conf *config.Config) string {
+	host, port, _ := net.SplitHostPort(conf.Listen[0])
+	if host == "" {
+		host = "localhost"
+	}
+	if conf.TLS.Enabled() {
+		return "https://" + net.JoinHostPort(host, port)
+	}
+	return "http://" + net.JoinHostPort(host, port)
 }
 
 func main() {
 
-	// check if provided dir is a valid dir
-	if err := validDir(dir); err != nil {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "share" {
+		os.Exit(shareCommand(os.Args[2:]))
+	}
+
+	conf, err := loadConfig(flag.CommandLine, os.Args[1:])
+	if err != nil {
 		log.Fatal(err)
 	}
+	if errs := conf.Validate(); len(errs) > 0 {
+		for _, err := range errs {
+			log.Print(err)
+		}
+		log.Fatal("Invalid settings")
+	}
 
-	// port string for server
-	portStr := fmt.Sprintf(":%d", *port)
+	tlsConf, err := tlsConfig(conf.TLS)
+	if err != nil {
+		log.Fatalf("Failed to setup TLS: %s", err.Error())
+	}
+
+	var m *metrics.Metrics
+	if conf.Metrics.Enabled {
+		m = metrics.New()
+	}
+	handler, err := fileServer(conf, m)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// some logs before starting
-	log.Printf("Listening to port %d", *port)
-	log.Printf("Serving path: %s", dir)
-	log.Fatal(http.ListenAndServe(portStr, fileServer(dir)))
+	for _, r := range conf.Roots {
+		log.Printf("Serving path: %s at %s", r.Dir, r.Prefix)
+	}
+
+	if tlsConf != nil && conf.TLS.Redirect != "" {
+		_, httpsPort, _ := net.SplitHostPort(conf.Listen[0])
+		port, _ := strconv.ParseUint(httpsPort, 10, 16)
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", conf.TLS.Redirect)
+			log.Fatal(http.ListenAndServe(conf.TLS.Redirect, server.RedirectHTTPS(port)))
+		}()
+	}
+
+	errc := make(chan error)
+	for _, addr := range conf.Listen {
+		srv := &http.Server{
+			Addr:      addr,
+			Handler:   handler,
+			TLSConfig: tlsConf,
+		}
+		if m != nil {
+			srv.ConnState = m.ConnState
+		}
+		go func(srv *http.Server) {
+			if tlsConf == nil {
+				log.Printf("Listening to %s", srv.Addr)
+				errc <- srv.ListenAndServe()
+				return
+			}
+			log.Printf("Listening to %s (HTTPS)", srv.Addr)
+			errc <- srv.ListenAndServeTLS("", "")
+		}(srv)
+	}
+	log.Fatal(<-errc)
 }
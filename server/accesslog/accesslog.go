@@ -0,0 +1,196 @@
+// Package accesslog records served requests in Apache common,
+// combined or JSON lines format
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// Format of log lines
+type Format string
+
+// supported formats
+const (
+	Common   Format = "common"
+	Combined Format = "combined"
+	JSON     Format = "json"
+)
+
+// ParseFormat returns the format of the name
+func ParseFormat(name string) (format Format, err error) {
+	switch format = Format(strings.ToLower(name)); format {
+	case Common, Combined, JSON:
+		return
+	}
+	err = fmt.Errorf("unknown access log format %#v", name)
+	return
+}
+
+// Entry is a served request
+type Entry struct {
+	Time      time.Time     `json:"time"`
+	Remote    string        `json:"remote"`
+	User      string        `json:"user,omitempty"`
+	Method    string        `json:"method"`
+	URI       string        `json:"uri"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Bytes     int64         `json:"bytes"`
+	Duration  time.Duration `json:"-"`
+	Referer   string        `json:"referer,omitempty"`
+	UserAgent string        `json:"userAgent,omitempty"`
+}
+
+// Logger writes entries to a writer in a format
+type Logger struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	format Format
+}
+
+// New returns a Logger writing to out
+func New(out io.Writer, format Format) *Logger {
+	return &Logger{
+		out:    out,
+		format: format,
+	}
+}
+
+// Log writes the entry as a line
+func (logger *Logger) Log(entry Entry) error {
+	line := logger.line(entry)
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	_, err := io.WriteString(logger.out, line)
+	return err
+}
+
+func (logger *Logger) line(entry Entry) string {
+
+	if logger.format == JSON {
+		b, _ := json.Marshal(struct {
+			Entry
+			Duration float64 `json:"durationMs"`
+		}{
+			Entry:    entry,
+			Duration: float64(entry.Duration) / float64(time.Millisecond),
+		})
+		return string(b) + "\n"
+	}
+
+	line := fmt.Sprintf("%s - %s [%s] %s %d %s",
+		entry.Remote,
+		dash(entry.User),
+		entry.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(entry.Method+" "+entry.URI+" "+entry.Proto),
+		entry.Status,
+		dash(strconv.FormatInt(entry.Bytes, 10)),
+	)
+	if logger.format == Combined {
+		line += " " + strconv.Quote(dash(entry.Referer)) + " " + strconv.Quote(dash(entry.UserAgent))
+	}
+	return line + "\n"
+}
+
+// dash replaces empty and zero values with "-"
+func dash(value string) string {
+	if value == "" || value == "0" {
+		return "-"
+	}
+	return value
+}
+
+// Middleware generates a middleware that logs every request
+// after it is served
+func (logger *Logger) Middleware() midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			start := time.Now()
+			ctx, user := auth.WithUserRecord(r.Context())
+			rec := &recorder{ResponseWriter: w}
+			entry := Entry{
+				Time:      start,
+				Remote:    r.RemoteAddr,
+				Method:    r.Method,
+				URI:       r.URL.RequestURI(),
+				Proto:     r.Proto,
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			}
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				entry.Remote = host
+			}
+
+			inner.ServeHTTP(rec, r.WithContext(ctx))
+
+			entry.User = user()
+			entry.Status = rec.status
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			entry.Bytes = rec.bytes
+			entry.Duration = time.Since(start)
+			logger.Log(entry)
+		})
+	}
+}
+
+// recorder records the status and size of a response
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *recorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *recorder) Write(b []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return
+}
+
+// Flush implements http.Flusher, if the underlying
+// writer supports it
+func (w *recorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if the underlying
+// writer supports it. Hijacked connections are logged
+// with status 101.
+func (w *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("accesslog: response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
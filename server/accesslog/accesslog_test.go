@@ -0,0 +1,115 @@
+package accesslog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/accesslog"
+	"github.com/go-serve/goserve/server/auth"
+)
+
+func serve(logger *accesslog.Logger, user string) {
+	hello := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	protected := auth.Middleware(func(r *http.Request) string {
+		return r.URL.Path
+	}, auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}})
+
+	r := httptest.NewRequest("GET", "http://example.com/hello?x=1", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	if user != "" {
+		r.SetBasicAuth(user, "secret")
+	}
+	logger.Middleware()(protected(hello)).ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestCombined(t *testing.T) {
+	var buf bytes.Buffer
+	serve(accesslog.New(&buf, accesslog.Combined), "alice")
+	serve(accesslog.New(&buf, accesslog.Combined), "")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if want, have := 2, len(lines); want != have {
+		t.Fatalf("expected %d lines, got %#v", want, lines)
+	}
+	if want, have := `192.0.2.1 - alice [`, lines[0]; !strings.HasPrefix(have, want) {
+		t.Errorf("expected prefix %#v, got %#v", want, have)
+	}
+	if want, have := `] "GET /hello?x=1 HTTP/1.1" 200 5 "-" "test-agent"`, lines[0]; !strings.HasSuffix(have, want) {
+		t.Errorf("expected suffix %#v, got %#v", want, have)
+	}
+	if want, have := `192.0.2.1 - - [`, lines[1]; !strings.HasPrefix(have, want) {
+		t.Errorf("expected prefix %#v, got %#v", want, have)
+	}
+	if want, have := `" 401 `, lines[1]; !strings.Contains(have, want) {
+		t.Errorf("expected %#v in %#v", want, have)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	serve(accesslog.New(&buf, accesslog.JSON), "alice")
+
+	var entry struct {
+		User      string  `json:"user"`
+		URI       string  `json:"uri"`
+		Status    int     `json:"status"`
+		Bytes     int64   `json:"bytes"`
+		UserAgent string  `json:"userAgent"`
+		Duration  float64 `json:"durationMs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "alice", entry.User; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "/hello?x=1", entry.URI; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := int64(5), entry.Bytes; want != have {
+		t.Errorf("expected %d, got %d", want, have)
+	}
+	if want, have := "test-agent", entry.UserAgent; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestFileRotate(t *testing.T) {
+
+	name := filepath.Join(t.TempDir(), "access.log")
+	f, err := accesslog.OpenFile(name, 10, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer f.Close()
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	for suffix, want := range map[string]string{
+		"":   "line 4\n",
+		".1": "line 3\n",
+		".2": "line 2\n",
+	} {
+		b, err := ioutil.ReadFile(name + suffix)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		if have := string(b); want != have {
+			t.Errorf("access.log%s: expected %#v, got %#v", suffix, want, have)
+		}
+	}
+	if _, err := ioutil.ReadFile(name + ".3"); err == nil {
+		t.Errorf("expected only 2 backups")
+	}
+}
@@ -0,0 +1,106 @@
+package accesslog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is a log file rotated by size. When a write would exceed
+// MaxSize, the file is renamed with suffix ".1", older backups are
+// shifted to ".2", ".3" and so on, and a new file is started.
+type File struct {
+	Name string
+
+	// MaxSize in bytes of the file before rotation.
+	// Zero disables rotation.
+	MaxSize int64
+
+	// MaxBackups is the number of rotated files to keep
+	MaxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// OpenFile opens the log file for appending
+func OpenFile(name string, maxSize int64, maxBackups int) (f *File, err error) {
+	f = &File{
+		Name:       name,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	if err = f.open(); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Write implements io.Writer
+func (f *File) Write(b []byte) (n int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.MaxSize > 0 && f.size > 0 && f.size+int64(len(b)) > f.MaxSize {
+		if err = f.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = f.file.Write(b)
+	f.size += int64(n)
+	return
+}
+
+// Close implements io.Closer
+func (f *File) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.Name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, stat.Size()
+	return nil
+}
+
+func (f *File) rotate() (err error) {
+	if err = f.file.Close(); err != nil {
+		return
+	}
+	f.file = nil
+
+	backup := func(i int) string {
+		return fmt.Sprintf("%s.%d", f.Name, i)
+	}
+	if f.MaxBackups > 0 {
+		os.Remove(backup(f.MaxBackups))
+		for i := f.MaxBackups - 1; i > 0; i-- {
+			os.Rename(backup(i), backup(i+1))
+		}
+		err = os.Rename(f.Name, backup(1))
+	} else {
+		err = os.Remove(f.Name)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	return f.open()
+}
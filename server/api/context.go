@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"net/url"
 )
@@ -12,12 +13,18 @@ const (
 	ctxKeyEndpointContext contextKey = iota
 	ctxKeyFS
 	ctxKeyGraphContext
+	ctxKeySharer
+	ctxKeyDebugLog
+	ctxKeyArchive
+	ctxKeyArchiveDirs
+	ctxKeyThumbnails
 )
 
 type endpointContext struct {
 	Sort   string
 	Host   string
 	Scheme string
+	Method string
 	Query  url.Values
 	FS     http.FileSystem
 }
@@ -31,6 +38,7 @@ func withEndpointContext(parent context.Context, r *http.Request) context.Contex
 		Sort:   r.URL.Query().Get("sort"),
 		Host:   r.Host,
 		Scheme: scheme,
+		Method: r.Method,
 		Query:  r.URL.Query(),
 	}
 	return context.WithValue(parent, ctxKeyEndpointContext, epCtx)
@@ -63,3 +71,39 @@ func getGraphContext(ctx context.Context) (graphCtx *graphContext) {
 	graphCtx, _ = ctx.Value(ctxKeyGraphContext).(*graphContext)
 	return
 }
+
+func withDebugLog(parent context.Context, logger *log.Logger) context.Context {
+	return context.WithValue(parent, ctxKeyDebugLog, logger)
+}
+
+func getDebugLog(ctx context.Context) (logger *log.Logger) {
+	logger, _ = ctx.Value(ctxKeyDebugLog).(*log.Logger)
+	return
+}
+
+func withArchive(parent context.Context, enabled bool) context.Context {
+	return context.WithValue(parent, ctxKeyArchive, enabled)
+}
+
+func hasArchive(ctx context.Context) bool {
+	enabled, _ := ctx.Value(ctxKeyArchive).(bool)
+	return enabled
+}
+
+func withArchiveDirs(parent context.Context, enabled bool) context.Context {
+	return context.WithValue(parent, ctxKeyArchiveDirs, enabled)
+}
+
+func hasArchiveDirs(ctx context.Context) bool {
+	enabled, _ := ctx.Value(ctxKeyArchiveDirs).(bool)
+	return enabled
+}
+
+func withThumbnails(parent context.Context, prefix string) context.Context {
+	return context.WithValue(parent, ctxKeyThumbnails, prefix)
+}
+
+func getThumbnails(ctx context.Context) (prefix string) {
+	prefix, _ = ctx.Value(ctxKeyThumbnails).(string)
+	return
+}
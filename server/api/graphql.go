@@ -11,10 +11,13 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
+	linq "github.com/ahmetb/go-linq/v3"
 	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/go-serve/goserve/server/imageinfo"
+	"github.com/go-serve/goserve/server/thumb"
 	"github.com/graphql-go/graphql"
-	linq "gopkg.in/ahmetb/go-linq.v3"
 )
 
 func graphStatFile(ctx context.Context, filepath string) (resp *FileInfo, err error) {
@@ -26,6 +29,9 @@ func graphStatFile(ctx context.Context, filepath string) (resp *FileInfo, err er
 	if os.IsNotExist(err) {
 		err = NewStatError(http.StatusNotFound, filepath)
 		return
+	} else if os.IsPermission(err) {
+		err = NewStatError(http.StatusForbidden, filepath)
+		return
 	} else if err != nil {
 		err = newError(http.StatusBadRequest, err)
 		return
@@ -65,6 +71,20 @@ func graphStatFile(ctx context.Context, filepath string) (resp *FileInfo, err er
 	return
 }
 
+// graphImageInfo reads the image at the path, if it can
+func graphImageInfo(ctx context.Context, filepath string) *imageinfo.Info {
+	f, err := getFilesystem(ctx).Open(filepath)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := imageinfo.Read(f)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
 func hasIndex(fs http.FileSystem, filepath string) bool {
 	fileIndex := path.Join(filepath, "index.html")
 	fi, err := fs.Open(fileIndex)
@@ -80,11 +100,15 @@ func graphListFiles(ctx context.Context, filepath string) (list []*FileInfo, err
 	graphCtx := getGraphContext(ctx)
 	args := graphCtx.Args
 
-	// replace os.Stat with FileSystem read
-	fsEntry, err := fs.Open(filepath)
+	// replace os.Stat with FileSystem read, opening
+	// archives as directories
+	fsEntry, err := fs.Open(dirName(filepath))
 	if os.IsNotExist(err) {
 		err = NewStatError(http.StatusNotFound, filepath)
 		return
+	} else if os.IsPermission(err) {
+		err = NewStatError(http.StatusForbidden, filepath)
+		return
 	} else if err != nil {
 		err = newError(http.StatusBadRequest, err)
 		return
@@ -111,7 +135,7 @@ func graphListFiles(ctx context.Context, filepath string) (list []*FileInfo, err
 
 		var d http.File
 		files := make([]os.FileInfo, 0, 40)
-		if d, err = fs.Open(filepath); err != nil {
+		if d, err = fs.Open(dirName(filepath)); err != nil {
 			log.Printf("Error listing filepath %#v:%s", filepath, err)
 			err = NewStatError(http.StatusInternalServerError, filepath)
 			return
@@ -327,6 +351,98 @@ func getSchema() (graphql.Schema, error) {
 	})
 	fileInfosType := graphql.NewList(fileInfoType)
 
+	// image metadata types
+	gpsType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "GPS",
+		Description: "Location where an image was captured, in degrees and meters",
+		Fields: graphql.Fields{
+			"latitude": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"longitude": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"altitude": &graphql.Field{
+				Type: graphql.Float,
+			},
+		},
+	})
+	exifType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Exif",
+		Description: "EXIF metadata of a photo",
+		Fields: graphql.Fields{
+			"orientation": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"make": &graphql.Field{
+				Type: graphql.String,
+			},
+			"model": &graphql.Field{
+				Type: graphql.String,
+			},
+			"lensModel": &graphql.Field{
+				Type: graphql.String,
+			},
+			"software": &graphql.Field{
+				Type: graphql.String,
+			},
+			"dateTime": &graphql.Field{
+				Type:        graphql.String,
+				Description: "capture time, with the offset from UTC if known",
+			},
+			"exposureTime": &graphql.Field{
+				Type: graphql.String,
+			},
+			"fNumber": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"iso": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"focalLength": &graphql.Field{
+				Type: graphql.Float,
+			},
+			"gps": &graphql.Field{
+				Type: gpsType,
+			},
+		},
+	})
+	imageType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Image",
+		Description: "Dimensions and metadata of an image",
+		Fields: graphql.Fields{
+			"format": &graphql.Field{
+				Type: graphql.String,
+			},
+			"width": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"height": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"colorModel": &graphql.Field{
+				Type: graphql.String,
+			},
+			"exif": &graphql.Field{
+				Type: exifType,
+			},
+		},
+	})
+	fileInfoType.AddFieldConfig("image", &graphql.Field{
+		Type:        imageType,
+		Description: "Dimensions and metadata of a JPEG, PNG or GIF image, read when requested",
+		Resolve: func(p graphql.ResolveParams) (resp interface{}, err error) {
+			src, ok := p.Source.(*FileInfo)
+			if !ok || src.Type != "file" || !imageinfo.IsImage(src.Name) {
+				return
+			}
+			if info := graphImageInfo(p.Context, src.Path); info != nil {
+				resp = info
+			}
+			return
+		},
+	})
+
 	fileInfoType.AddFieldConfig("parent", &graphql.Field{
 		Type: fileInfoType,
 		Resolve: func(p graphql.ResolveParams) (resp interface{}, err error) {
@@ -341,6 +457,26 @@ func getSchema() (graphql.Schema, error) {
 			return
 		},
 	})
+	fileInfoType.AddFieldConfig("thumbnailUrl", &graphql.Field{
+		Type:        graphql.String,
+		Description: "URL of the thumbnail of an image, if served",
+		Args: graphql.FieldConfigArgument{
+			"width": &graphql.ArgumentConfig{
+				Type:        graphql.Int,
+				Description: "int, width of the thumbnail in pixels, rounded up to 64, 128, 256, 512 or 1024",
+			},
+		},
+		Resolve: func(p graphql.ResolveParams) (resp interface{}, err error) {
+			src, ok := p.Source.(*FileInfo)
+			prefix := getThumbnails(p.Context)
+			if !ok || prefix == "" || src.Type != "file" || !thumb.IsImage(src.Name) {
+				return
+			}
+			width, _ := p.Args["width"].(int)
+			resp = thumb.URL(prefix, src.Path, thumb.Width(width))
+			return
+		},
+	})
 	fileInfoType.AddFieldConfig("children", &graphql.Field{
 		Type: fileInfosType,
 		Args: graphql.FieldConfigArgument{
@@ -435,8 +571,79 @@ func getSchema() (graphql.Schema, error) {
 			},
 		},
 	}
+
+	// Share link type
+	shareType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Share",
+		Description: "An expiring signed link to a file or directory",
+		Fields: graphql.Fields{
+			"url": &graphql.Field{
+				Type: graphql.String,
+			},
+			"path": &graphql.Field{
+				Type: graphql.String,
+			},
+			"expires": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (resp interface{}, err error) {
+					if src, ok := p.Source.(*ShareInfo); ok {
+						resp = src.Expires.Format(time.RFC3339)
+					}
+					return
+				},
+			},
+			"maxDownloads": &graphql.Field{
+				Type: graphql.Int,
+			},
+			"hasPassword": &graphql.Field{
+				Type: graphql.Boolean,
+			},
+		},
+	})
+
+	// Root Mutation Schema
+	rootMutation := graphql.ObjectConfig{
+		Name: "RootMutation",
+		Fields: graphql.Fields{
+			"createShare": &graphql.Field{
+				Type:        shareType,
+				Description: "Create an expiring signed link to a file or a directory",
+				Args: graphql.FieldConfigArgument{
+					"path": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+					"ttl": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "duration of the link, e.g. \"2h\" (default: \"24h\")",
+					},
+					"maxDownloads": &graphql.ArgumentConfig{
+						Type:        graphql.Int,
+						Description: "number of downloads allowed, unlimited if not set",
+					},
+					"password": &graphql.ArgumentConfig{
+						Type:        graphql.String,
+						Description: "password required to use the link",
+					},
+				},
+				Resolve: func(p graphql.ResolveParams) (resp interface{}, err error) {
+					if epCtx := getEndpointContext(p.Context); epCtx == nil || epCtx.Method != "POST" {
+						err = fmt.Errorf("createShare requires POST request")
+						return
+					}
+					req := ShareRequest{Path: p.Args["path"].(string)}
+					req.TTL, _ = p.Args["ttl"].(string)
+					req.MaxDownloads, _ = p.Args["maxDownloads"].(int)
+					req.Password, _ = p.Args["password"].(string)
+					resp, err = createShare(p.Context, req)
+					return
+				},
+			},
+		},
+	}
+
 	schemaConfig := graphql.SchemaConfig{
-		Query: graphql.NewObject(rootQuery),
+		Query:    graphql.NewObject(rootQuery),
+		Mutation: graphql.NewObject(rootMutation),
 	}
 
 	return graphql.NewSchema(schemaConfig)
@@ -465,7 +672,7 @@ func deccodeGraphRequest(ctx context.Context, r *http.Request) (req interface{},
 
 		// get variables
 		if variables := r.URL.Query().Get("variables"); variables != "" {
-			err = json.Unmarshal([]byte(variables), vreq.Variables)
+			err = json.Unmarshal([]byte(variables), &vreq.Variables)
 		}
 		if err != nil {
 			err = newInputError(fmt.Errorf("error decoding 'variables' in GET request"))
@@ -552,8 +759,12 @@ func graphEndpoint(ctx context.Context, req interface{}) (resp interface{}, err
 // GraphQLHandler returns http.Handler for the
 // graphql endpoint
 func GraphQLHandler() http.Handler {
+	return graphQLHandler(graphEndpoint)
+}
+
+func graphQLHandler(endpoint func(ctx context.Context, req interface{}) (resp interface{}, err error)) http.Handler {
 	return httptransport.NewServer(
-		graphEndpoint,
+		endpoint,
 		deccodeGraphRequest,
 		encodeGraphResponse,
 		httptransport.ServerErrorEncoder(encodeGraphErrorResponse),
@@ -7,10 +7,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"strings"
 	"time"
 
-	"github.com/go-midway/midway"
+	"github.com/go-serve/goserve/server/imageinfo"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/share"
+	"github.com/go-serve/goserve/server/vfs"
 )
 
 // Link contains a HATEOAS hypermedia reference URL
@@ -37,22 +41,27 @@ type FileStat struct {
 	Path  string
 	Size  int64
 	MTime time.Time
+
+	// Image is the dimensions and metadata of images
+	Image *imageinfo.Info
 }
 
 // MarshalJSON implements encoding/json.Marshaler
 func (file FileStat) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Type  string    `json:"type"`
-		Name  string    `json:"name"`
-		Path  string    `json:"path"`
-		Size  int64     `json:"size"`
-		MTime time.Time `json:"mtime"`
+		Type  string          `json:"type"`
+		Name  string          `json:"name"`
+		Path  string          `json:"path"`
+		Size  int64           `json:"size"`
+		MTime time.Time       `json:"mtime"`
+		Image *imageinfo.Info `json:"image,omitempty"`
 	}{
 		Type:  "file",
 		Name:  file.Name,
 		Path:  file.Path,
 		Size:  file.Size,
 		MTime: file.MTime,
+		Image: file.Image,
 	})
 }
 
@@ -121,56 +130,93 @@ func (err StatError) MarshalJSON() ([]byte, error) {
 	})
 }
 
-func statsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err error) {
+// cleanPath normalizes a request path into a slash separated path
+// relative to the served root. Parent references ("..") can never
+// climb above the root.
+func cleanPath(p string) string {
+	return strings.TrimLeft(path.Clean("/"+p), "/")
+}
 
-	path := req.(string)
+// fsError maps errors from the file system into StatError
+// with proper status code
+func fsError(err error, path string) error {
+	switch {
+	case os.IsNotExist(err):
+		return NewStatError(http.StatusNotFound, path)
+	case os.IsPermission(err):
+		return NewStatError(http.StatusForbidden, path)
+	}
+	return err
+}
+
+// openStat opens the given path on the file system and
+// returns the file with its stat
+func openStat(fs http.FileSystem, path string) (f http.File, stat os.FileInfo, err error) {
+	return openStatName(fs, path, "/"+path)
+}
 
-	// TODO: rewrite with FileSystem
-	stat, err := os.Stat(path)
+// openDirStat opens the given path as a directory, so archives
+// are opened as directories by file systems of vfs.Archives
+func openDirStat(fs http.FileSystem, path string) (f http.File, stat os.FileInfo, err error) {
+	return openStatName(fs, path, dirName(path))
+}
+
+// dirName returns the name to open the path as a directory
+func dirName(path string) string {
+	return strings.TrimRight("/"+path, "/") + "/"
+}
 
-	// if file not found
-	if os.IsNotExist(err) {
-		err = NewStatError(http.StatusNotFound, path)
+func openStatName(fs http.FileSystem, path, name string) (f http.File, stat os.FileInfo, err error) {
+	if fs == nil {
+		err = NewStatError(http.StatusInternalServerError, path)
 		return
 	}
+	if f, err = fs.Open(name); err != nil {
+		err = fsError(err, path)
+		return
+	}
+	if stat, err = f.Stat(); err != nil {
+		f.Close()
+		f, err = nil, fsError(err, path)
+	}
+	return
+}
+
+func statsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err error) {
 
-	// permission problem
+	path := cleanPath(req.(string))
+
+	file, stat, err := openStat(getFilesystem(ctx), path)
 	if err != nil {
-		perr, _ := err.(*os.PathError)
-		if perr.Err.Error() == os.ErrPermission.Error() {
-			err = NewStatError(http.StatusForbidden, path)
-		}
 		return
 	}
+	defer file.Close()
+
+	name := stat.Name()
+	if path == "" {
+		name = "/"
+	}
 
 	// for files
 	if stat.Mode().IsRegular() {
-
-		// test permission
-		var file *os.File
-		file, err = os.OpenFile(path, os.O_RDONLY, 0444)
-		if err != nil {
-			perr, _ := err.(*os.PathError)
-			if perr.Err.Error() == os.ErrPermission.Error() {
-				err = NewStatError(http.StatusForbidden, path)
-			}
-			return
-		}
-		file.Close() // close immediately
-
-		stats = FileStat{
-			Name:  stat.Name(),
+		fileStat := FileStat{
+			Name:  name,
 			Path:  path,
 			Size:  stat.Size(),
 			MTime: stat.ModTime(),
 		}
+		if imageinfo.IsImage(name) {
+			// images that cannot be read are still files
+			fileStat.Image, _ = imageinfo.Read(file)
+		}
+		stats = fileStat
 		return
 	}
 
 	// for directories
 	if stat.Mode().IsDir() {
 		stats = DirStat{
-			Name:  stat.Name(),
+			Name:  name,
 			Path:  path,
 			MTime: stat.ModTime(),
 		}
@@ -181,45 +227,27 @@ func statsEndpoint(ctx context.Context, req interface{}) (stats interface{}, err
 }
 
 func listEndpoint(ctx context.Context, req interface{}) (resp interface{}, err error) {
-	path := req.(string)
-	if path == "" {
-		path = "."
-	}
 
-	// TODO: build the absolute file / dir path for stat and open
-	stat, err := os.Stat(path)
+	path := cleanPath(req.(string))
 
-	// if file not found
-	if os.IsNotExist(err) {
-		err = NewStatError(http.StatusNotFound, path)
+	d, stat, err := openDirStat(getFilesystem(ctx), path)
+	if err != nil {
 		return
 	}
+	defer d.Close()
 
-	// permission problem
-	if err != nil {
-		perr, _ := err.(*os.PathError)
-		if perr.Err.Error() == os.ErrPermission.Error() {
-			err = NewStatError(http.StatusForbidden, path)
-		}
-		return
+	if path == "" {
+		path = "."
 	}
 
 	// for directories
 	if stat.Mode().IsDir() {
 
-		var d *os.File
-		files := make([]os.FileInfo, 0, 40)
-		// TODO: use FileSystem for file access
-		if d, err = os.Open(path); err != nil {
-			log.Printf("Error listing path %#v:%s", path, err)
-			err = NewStatError(http.StatusInternalServerError, path)
-			return
-		}
-		defer d.Close()
-
+		var files []os.FileInfo
 		files, err = d.Readdir(0)
 		if err != nil {
 			log.Printf("Error listing path %#v:%s", path, err)
+			err = fsError(err, path)
 			return
 		}
 
@@ -244,43 +272,57 @@ func listEndpoint(ctx context.Context, req interface{}) (resp interface{}, err e
 			}
 
 			if item.Mode().IsRegular() {
+				links := []Link{
+					{
+						Rel:  "self",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
+					},
+					{
+						Rel:  "stat",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
+					},
+				}
+				if hasArchiveDirs(ctx) && vfs.IsArchive(item.Name()) {
+					links = append(links, Link{
+						Rel:  "list",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/lists/" + itemPath,
+					})
+				}
 				list[i] = FileInfo{
 					Name:  item.Name(),
 					Type:  "file",
 					Path:  itemPath,
 					Size:  item.Size(),
 					MTime: item.ModTime(),
-					Links: []Link{
-						{
-							Rel:  "self",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
-						},
-						{
-							Rel:  "stat",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
-						},
-					},
+					Links: links,
 				}
 			} else if item.IsDir() {
+				links := []Link{
+					{
+						Rel:  "self",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
+					},
+					{
+						Rel:  "stat",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
+					},
+					{
+						Rel:  "list",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/lists/" + itemPath,
+					},
+				}
+				if hasArchive(ctx) {
+					links = append(links, Link{
+						Rel:  "archive",
+						Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath + "?download=zip",
+					})
+				}
 				list[i] = FileInfo{
 					Name:  item.Name(),
 					Type:  "directory",
 					Path:  itemPath,
 					MTime: item.ModTime(),
-					Links: []Link{
-						{
-							Rel:  "self",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/" + itemPath,
-						},
-						{
-							Rel:  "stat",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/stats/" + itemPath,
-						},
-						{
-							Rel:  "list",
-							Href: epCtx.Scheme + "://" + epCtx.Host + "/_goserve/api/lists/" + itemPath,
-						},
-					},
+					Links: links,
 				}
 			} else {
 				list[i] = FileInfo{
@@ -316,12 +358,8 @@ func listEndpoint(ctx context.Context, req interface{}) (resp interface{}, err e
 func handleEndpoint(endpoint func(ctx context.Context, req interface{}) (resp interface{}, err error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		ctx := context.Background()
-
 		// prepare context
-		if r != nil {
-			ctx = withEndpointContext(ctx, r)
-		}
+		ctx := withEndpointContext(r.Context(), r)
 
 		// handle path request
 		resp, err := endpoint(ctx, r.URL.Path)
@@ -357,13 +395,83 @@ func handleEndpoint(endpoint func(ctx context.Context, req interface{}) (resp in
 		jsonw := json.NewEncoder(w)
 		jsonw.Encode(resp)
 
-		log.Printf("resp: %#v", resp)
+		if logger := getDebugLog(ctx); logger != nil {
+			logger.Printf("resp: %#v", resp)
+		}
+	}
+}
+
+// Option configures the API served by ServeAPI
+type Option func(*apiConfig)
+
+type apiConfig struct {
+	sharer    *share.Sharer
+	debugLog  *log.Logger
+	graphHook func(err error)
+	archive   bool
+
+	// archiveDirs lists archives as directories
+	archiveDirs bool
+
+	// thumbnails is the URL path of thumbnails, if served
+	thumbnails string
+}
+
+// WithSharer enables creating share links with the Sharer
+// at the "shares" endpoint and the createShare mutation
+func WithSharer(sharer *share.Sharer) Option {
+	return func(c *apiConfig) {
+		c.sharer = sharer
+	}
+}
+
+// WithDebugLog logs the responses of REST endpoints to the logger
+func WithDebugLog(logger *log.Logger) Option {
+	return func(c *apiConfig) {
+		c.debugLog = logger
+	}
+}
+
+// WithArchive links directories to their zip archives, served
+// with "?download=zip", with the rel "archive"
+func WithArchive() Option {
+	return func(c *apiConfig) {
+		c.archive = true
+	}
+}
+
+// WithArchiveDirs links archive files to their listings, for
+// file systems browsing archives as directories with vfs.Archives
+func WithArchiveDirs() Option {
+	return func(c *apiConfig) {
+		c.archiveDirs = true
+	}
+}
+
+// WithThumbnails resolves the thumbnailUrl of images in GraphQL
+// queries to thumbnails served under the URL path prefix
+func WithThumbnails(prefix string) Option {
+	return func(c *apiConfig) {
+		c.thumbnails = prefix
+	}
+}
+
+// WithGraphQLHook calls hook after each GraphQL query
+// is executed, with its error if any
+func WithGraphQLHook(hook func(err error)) Option {
+	return func(c *apiConfig) {
+		c.graphHook = hook
 	}
 }
 
 // ServeAPI generates a middleware to serve API for file / directory information
 // query
-func ServeAPI(path string, root http.FileSystem) midway.Middleware {
+func ServeAPI(path string, root http.FileSystem, options ...Option) midway.Middleware {
+
+	var conf apiConfig
+	for _, option := range options {
+		option(&conf)
+	}
 
 	path = strings.TrimRight(path, "/") // strip trailing slash
 	pathWithSlash := path + "/"
@@ -373,6 +481,13 @@ func ServeAPI(path string, root http.FileSystem) midway.Middleware {
 	handleStats := handleEndpoint(statsEndpoint)
 	handleList := handleEndpoint(listEndpoint)
 	handleGraphQL := GraphQLHandler()
+	if conf.graphHook != nil {
+		handleGraphQL = graphQLHandler(func(ctx context.Context, req interface{}) (resp interface{}, err error) {
+			resp, err = graphEndpoint(ctx, req)
+			conf.graphHook(err)
+			return
+		})
+	}
 
 	return func(inner http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -384,12 +499,24 @@ func ServeAPI(path string, root http.FileSystem) midway.Middleware {
 			}
 			if r.URL.Path == path+"/graphql" {
 				graphCtx := withFilesystem(withEndpointContext(r.Context(), r), root)
+				graphCtx = withSharer(graphCtx, conf.sharer)
+				graphCtx = withThumbnails(graphCtx, conf.thumbnails)
 				handleGraphQL.ServeHTTP(w, r.WithContext(graphCtx))
 				return
 			}
 			if strings.HasPrefix(r.URL.Path, pathWithSlash) {
+				ctx := withSharer(withFilesystem(r.Context(), root), conf.sharer)
+				ctx = withArchive(withDebugLog(ctx, conf.debugLog), conf.archive)
+				ctx = withArchiveDirs(ctx, conf.archiveDirs)
+				r = r.WithContext(ctx)
 				r.URL.Path = strings.TrimRight(r.URL.Path[pathLen:], "/") // strip base path
 
+				// create share links
+				if r.URL.Path == "shares" {
+					handleShares(w, r)
+					return
+				}
+
 				// stats of file / directory
 				if strings.HasPrefix(r.URL.Path, "stats/") {
 					r.URL.Path = r.URL.Path[6:]
@@ -0,0 +1,214 @@
+package api_test
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/imageinfo"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func serveAPI(root http.FileSystem, path string, options ...api.Option) *httptest.ResponseRecorder {
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	h := api.ServeAPI("/_goserve/api", root, options...)(notFound)
+
+	r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestStatsEndpoint(t *testing.T) {
+
+	root := http.Dir("./../../_example")
+
+	w := serveAPI(root, "/_goserve/api/stats/folder1/foo1")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	var stat struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+		Path string `json:"path"`
+		Size int64  `json:"size"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&stat); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "file", stat.Type; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	if want, have := "foo1", stat.Name; want != have {
+		t.Errorf("expected name %#v, got %#v", want, have)
+	}
+	if want, have := "folder1/foo1", stat.Path; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := int64(5), stat.Size; want != have {
+		t.Errorf("expected size %d, got %d", want, have)
+	}
+
+	w = serveAPI(root, "/_goserve/api/stats/folder1")
+	if err := json.NewDecoder(w.Body).Decode(&stat); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "directory", stat.Type; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+
+	w = serveAPI(root, "/_goserve/api/stats/folder1/not-exists")
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestListEndpoint(t *testing.T) {
+
+	root := http.Dir("./../../_example")
+
+	var resp struct {
+		Items []api.FileInfo `json:"items"`
+	}
+
+	w := serveAPI(root, "/_goserve/api/lists/folder1?sort=name")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 2, len(resp.Items); want != have {
+		t.Fatalf("expected %d items, got %d", want, have)
+	}
+	if want, have := "folder1/foo1", resp.Items[0].Path; want != have {
+		t.Errorf("expected path %#v, got %#v", want, have)
+	}
+	if want, have := "http://example.com/_goserve/api/stats/folder1/foo1", resp.Items[0].Links[1].Href; want != have {
+		t.Errorf("expected stat link %#v, got %#v", want, have)
+	}
+
+	// listing the root
+	w = serveAPI(root, "/_goserve/api/lists?sort=name")
+	resp.Items = nil
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "folder1, index.html", itemNames(resp.Items); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	// archive links of directories
+	w = serveAPI(root, "/_goserve/api/lists?sort=name", api.WithArchive())
+	resp.Items = nil
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 4, len(resp.Items[0].Links); want != have {
+		t.Fatalf("expected %d links, got %d", want, have)
+	}
+	if want, have := (api.Link{Rel: "archive", Href: "http://example.com/folder1?download=zip"}), resp.Items[0].Links[3]; want != have {
+		t.Errorf("expected archive link %#v, got %#v", want, have)
+	}
+
+	// parent references should never climb above root
+	w = serveAPI(root, "/_goserve/api/lists/../../folder1")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// not a directory
+	w = serveAPI(root, "/_goserve/api/lists/index.html")
+	if want, have := http.StatusBadRequest, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestEndpointSymlinkEscape(t *testing.T) {
+
+	outside, err := filepath.Abs("./../../_example/folder1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("cannot create symlink: %s", err.Error())
+	}
+
+	root := vfs.Dir(dir)
+	for _, path := range []string{
+		"/_goserve/api/stats/escape",
+		"/_goserve/api/stats/escape/foo1",
+		"/_goserve/api/lists/escape",
+	} {
+		if want, have := http.StatusForbidden, serveAPI(root, path).Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", path, want, have)
+		}
+	}
+}
+
+func itemNames(items []api.FileInfo) string {
+	names := ""
+	for i, item := range items {
+		if i > 0 {
+			names += ", "
+		}
+		names += item.Name
+	}
+	return names
+}
+
+func TestStatsEndpointImage(t *testing.T) {
+
+	root := t.TempDir()
+	f, _ := os.Create(filepath.Join(root, "a.png"))
+	png.Encode(f, image.NewGray(image.Rect(0, 0, 30, 20)))
+	f.Close()
+	ioutil.WriteFile(filepath.Join(root, "broken.png"), []byte("not an image"), 0644)
+
+	var stat struct {
+		Type  string          `json:"type"`
+		Image *imageinfo.Info `json:"image"`
+	}
+	w := serveAPI(vfs.Dir(root), "/_goserve/api/stats/a.png")
+	if err := json.NewDecoder(w.Body).Decode(&stat); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if stat.Image == nil {
+		t.Fatalf("expected image metadata")
+	}
+	if want, have := (imageinfo.Info{Format: "png", Width: 30, Height: 20, ColorModel: "gray"}), *stat.Image; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	stat.Image = nil
+	w = serveAPI(vfs.Dir(root), "/_goserve/api/stats/broken.png")
+	if err := json.NewDecoder(w.Body).Decode(&stat); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "file", stat.Type; want != have {
+		t.Errorf("expected type %#v, got %#v", want, have)
+	}
+	if stat.Image != nil {
+		t.Errorf("unexpected image metadata %#v", stat.Image)
+	}
+
+	// resolved in GraphQL when requested
+	query := `{list(path:"/",sort:"name"){name image{width height colorModel exif{orientation}}}}`
+	w = serveAPI(vfs.Dir(root), "/_goserve/api/graphql?query="+url.QueryEscape(query))
+	want := `{"data":{"list":[{"image":{"colorModel":"gray","exif":null,"height":20,"width":30},"name":"a.png"},{"image":null,"name":"broken.png"}]}}`
+	if have := strings.TrimSpace(w.Body.String()); want != have {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, have)
+	}
+}
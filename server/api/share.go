@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-serve/goserve/server/share"
+)
+
+// DefaultShareTTL is the lifetime of share links
+// created without a TTL
+const DefaultShareTTL = 24 * time.Hour
+
+// ShareRequest is the input to create a share link
+type ShareRequest struct {
+	Path         string `json:"path"`
+	TTL          string `json:"ttl,omitempty"`
+	MaxDownloads int    `json:"maxDownloads,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+// ShareInfo describes a created share link
+type ShareInfo struct {
+	URL          string    `json:"url"`
+	Path         string    `json:"path"`
+	Expires      time.Time `json:"expires"`
+	MaxDownloads int       `json:"maxDownloads,omitempty"`
+	HasPassword  bool      `json:"hasPassword"`
+}
+
+// createShare signs a share link for an existing path
+func createShare(ctx context.Context, req ShareRequest) (resp *ShareInfo, err error) {
+
+	sharer := getSharer(ctx)
+	if sharer == nil {
+		err = NewStatError(http.StatusNotFound, req.Path)
+		return
+	}
+
+	ttl := DefaultShareTTL
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			err = NewStatError(http.StatusBadRequest, req.Path)
+			return
+		}
+	}
+	if req.MaxDownloads < 0 {
+		err = NewStatError(http.StatusBadRequest, req.Path)
+		return
+	}
+
+	p := cleanPath(req.Path)
+	file, _, err := openStat(getFilesystem(ctx), p)
+	if err != nil {
+		return
+	}
+	file.Close()
+
+	link, err := sharer.Sign("/"+p, ttl, req.MaxDownloads, req.Password)
+	if err != nil {
+		return
+	}
+
+	epCtx := getEndpointContext(ctx)
+	resp = &ShareInfo{
+		URL:          link.URL(epCtx.Scheme + "://" + epCtx.Host),
+		Path:         link.Path,
+		Expires:      link.Expires,
+		MaxDownloads: link.MaxDownloads,
+		HasPassword:  link.HasPassword(),
+	}
+	return
+}
+
+// handleShares creates share links from JSON POST requests.
+// JSON content type is required so that a cross site form
+// cannot create links with the credentials of a visitor.
+func handleShares(w http.ResponseWriter, r *http.Request) {
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Method != "POST" || contentType != "application/json" {
+		statusCode := http.StatusMethodNotAllowed
+		if r.Method == "POST" {
+			statusCode = http.StatusUnsupportedMediaType
+		}
+		w.Header().Set("Allow", "POST")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(struct {
+			Code    int    `json:"code"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}{
+			Code:    statusCode,
+			Status:  "error",
+			Message: "expects JSON in POST request",
+		})
+		return
+	}
+
+	var req ShareRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	handleEndpoint(func(ctx context.Context, _ interface{}) (resp interface{}, _ error) {
+		if err != nil {
+			return nil, NewStatError(http.StatusBadRequest, req.Path)
+		}
+		return createShare(ctx, req)
+	})(w, r)
+}
+
+// withSharer stores the Sharer to create share links with
+func withSharer(parent context.Context, sharer *share.Sharer) context.Context {
+	return context.WithValue(parent, ctxKeySharer, sharer)
+}
+
+func getSharer(ctx context.Context) (sharer *share.Sharer) {
+	sharer, _ = ctx.Value(ctxKeySharer).(*share.Sharer)
+	return
+}
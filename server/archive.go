@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/server/archive"
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// ServeArchive generates a middleware that streams directories as zip
+// or tar.gz archives for "?download=zip" and "?download=tar.gz".
+// POST requests select files in the directory with "path" form values,
+// or a JSON body of {"paths": [...]}. Files in the scopes the request
+// is not authorized for are left out.
+func ServeArchive(root http.FileSystem, scopes ...auth.Scope) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			download := r.URL.Query().Get("download")
+			if r.Method == http.MethodPost && download == "" && isForm(r) {
+				download = r.PostFormValue("download")
+			}
+			if download == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodPost) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			// keep the trailing slash, which opens
+			// archives as directories
+			dir := path.Clean("/" + r.URL.Path)
+			if dir != "/" && strings.HasSuffix(r.URL.Path, "/") {
+				dir += "/"
+			}
+			d, err := root.Open(dir)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			stat, err := d.Stat()
+			d.Close()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			} else if !stat.IsDir() {
+				// plain downloads of files
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			format, err := archive.ParseFormat(download)
+			if err != nil {
+				serveError(w, r, root, http.StatusBadRequest)
+				return
+			}
+
+			var names []string
+			if r.Method == http.MethodPost {
+				if names, err = archiveSelection(r); err != nil || len(names) == 0 {
+					serveError(w, r, root, http.StatusBadRequest)
+					return
+				}
+			}
+
+			name := path.Base(dir)
+			if name == "/" {
+				name = "download"
+			}
+			w.Header().Set("Content-Type", format.ContentType())
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+				"filename": name + "." + string(format),
+			}))
+			w.Header().Set("Cache-Control", "no-store")
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			var include archive.Filter
+			if len(scopes) > 0 {
+				include = auth.Authorizer(r, scopes...)
+			}
+			if err := archive.Write(w, format, root, dir, names, name, include); err != nil {
+				// the response has started, so the client
+				// is left with a truncated archive
+				log.Printf("Error archiving path %#v: %s", dir, err)
+			}
+		})
+	}
+}
+
+// archiveSelection returns the paths selected by a POST request
+func archiveSelection(r *http.Request) (names []string, err error) {
+	if isJSON(r) {
+		var selection struct {
+			Paths []string `json:"paths"`
+		}
+		err = json.NewDecoder(r.Body).Decode(&selection)
+		names = selection.Paths
+		return
+	}
+	if !isForm(r) {
+		return
+	}
+	if err = r.ParseForm(); err == nil {
+		names = r.PostForm["path"]
+	}
+	return
+}
+
+// isForm reports if the request body is an URL encoded form
+func isForm(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/x-www-form-urlencoded"
+}
+
+// isJSON reports if the request body is JSON
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
@@ -0,0 +1,289 @@
+// Package archive streams files of a goserve root as zip or
+// tar.gz archives, without temporary files
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Format is the format of an archive
+type Format string
+
+// archive formats
+const (
+	Zip   Format = "zip"
+	TarGz Format = "tar.gz"
+)
+
+// ParseFormat parses "zip" or "tar.gz"
+func ParseFormat(s string) (format Format, err error) {
+	switch format = Format(s); format {
+	case Zip, TarGz:
+		return
+	case "tgz":
+		return TarGz, nil
+	}
+	return "", fmt.Errorf("unknown archive format %#v, expected zip or tar.gz", s)
+}
+
+// ContentType returns the MIME type of the format
+func (format Format) ContentType() string {
+	if format == TarGz {
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+// Filter reports if the file or directory at the path, relative
+// to the root of the file system, is included in archives
+type Filter func(name string) bool
+
+// Write writes an archive of the named files and directories to w.
+// Names are relative to the directory dir of the root, and stored
+// under the prefix in the archive. Directories are added with their
+// content. Symbolic links are not followed, and files that cannot be
+// opened, such as hidden ones, are left out.
+func Write(w io.Writer, format Format, root http.FileSystem, dir string, names []string, prefix string, include Filter) (err error) {
+
+	var aw archiveWriter
+	switch format {
+	case Zip:
+		aw = newZipWriter(w)
+	case TarGz:
+		aw = newTarGzWriter(w)
+	default:
+		return fmt.Errorf("archive: unknown format %#v", format)
+	}
+
+	if include == nil {
+		include = func(string) bool { return true }
+	}
+	walker := &walker{root: root, aw: aw, include: include}
+
+	dir = path.Clean("/" + dir)
+	for _, name := range Selection(names) {
+		p := path.Join(dir, name)
+		if name == "." {
+			// with the trailing slash, archives of vfs.Archives
+			// open as directories too
+			p = strings.TrimSuffix(p, "/") + "/"
+		}
+		if err = walker.add(p, path.Join(prefix, name)); err != nil {
+			aw.Close()
+			return
+		}
+	}
+	return aw.Close()
+}
+
+// Selection cleans the names of selected files relative to a
+// directory, so they cannot point outside of it, and drops the
+// names contained in other selected directories. An empty
+// selection, or one of ".", selects the whole directory.
+func Selection(names []string) []string {
+
+	cleaned := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if name == "" {
+			return []string{"."}
+		}
+		cleaned = append(cleaned, name)
+	}
+	if len(cleaned) == 0 {
+		return []string{"."}
+	}
+
+	// parents sort before their content, though not always
+	// right before it, e.g. "a", "a-b", "a/c"
+	sort.Strings(cleaned)
+	selection := cleaned[:0]
+	selected := make(map[string]bool, len(cleaned))
+	for _, name := range cleaned {
+		if !selected[name] && !hasSelectedParent(selected, name) {
+			selected[name] = true
+			selection = append(selection, name)
+		}
+	}
+	return selection
+}
+
+// hasSelectedParent reports if a parent directory of the name is selected
+func hasSelectedParent(selected map[string]bool, name string) bool {
+	for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+		if name = name[:i]; selected[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// walker adds files and directories of the root to an archive
+type walker struct {
+	root    http.FileSystem
+	aw      archiveWriter
+	include Filter
+}
+
+// add adds the file or directory at the path of the root to the
+// archive as name. Unreadable files are left out.
+func (wk *walker) add(p, name string) error {
+
+	if !wk.include(p) {
+		return nil
+	}
+	f, err := wk.root.Open(p)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+
+	switch {
+	case stat.Mode().IsRegular():
+		return wk.aw.File(name, stat, f)
+	case !stat.IsDir():
+		return nil
+	}
+
+	if name != "." {
+		if err := wk.aw.Dir(name, stat); err != nil {
+			return err
+		}
+	}
+	entries, err := f.Readdir(0)
+	if err != nil {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	for _, entry := range entries {
+		// symbolic links may loop
+		if entry.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		if err := wk.add(path.Join(p, entry.Name()), path.Join(name, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveWriter writes the entries of an archive
+type archiveWriter interface {
+	Dir(name string, stat os.FileInfo) error
+	File(name string, stat os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func newZipWriter(w io.Writer) *zipWriter {
+	return &zipWriter{zw: zip.NewWriter(w)}
+}
+
+// Dir implements archiveWriter
+func (w *zipWriter) Dir(name string, stat os.FileInfo) error {
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	_, err = w.zw.CreateHeader(header)
+	return err
+}
+
+// File implements archiveWriter
+func (w *zipWriter) File(name string, stat os.FileInfo, r io.Reader) error {
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+	fw, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, r)
+	return err
+}
+
+// Close implements archiveWriter
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+type tarGzWriter struct {
+	gw *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarGzWriter(w io.Writer) *tarGzWriter {
+	gw := gzip.NewWriter(w)
+	return &tarGzWriter{gw: gw, tw: tar.NewWriter(gw)}
+}
+
+// Dir implements archiveWriter
+func (w *tarGzWriter) Dir(name string, stat os.FileInfo) error {
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name + "/"
+	return w.tw.WriteHeader(header)
+}
+
+// File implements archiveWriter
+func (w *tarGzWriter) File(name string, stat os.FileInfo, r io.Reader) error {
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if err = w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	// the size in the header must match, even if
+	// the file changes while it is written
+	n, err := io.Copy(w.tw, io.LimitReader(r, stat.Size()))
+	if err == nil && n < stat.Size() {
+		_, err = io.CopyN(w.tw, zeros{}, stat.Size()-n)
+	}
+	return err
+}
+
+// Close implements archiveWriter
+func (w *tarGzWriter) Close() error {
+	err := w.tw.Close()
+	if gerr := w.gw.Close(); err == nil {
+		err = gerr
+	}
+	return err
+}
+
+// zeros reads zero bytes endlessly
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
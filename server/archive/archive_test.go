@@ -0,0 +1,148 @@
+package archive_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/archive"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func testRoot(t *testing.T) string {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "docs", "sub"), 0755)
+	os.Mkdir(filepath.Join(root, "private"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("hello"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "sub", "b.txt"), []byte("world"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", ".env"), []byte("SECRET=1"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "private", "c.txt"), []byte("private"), 0644)
+	os.Symlink("..", filepath.Join(root, "docs", "loop"))
+	return root
+}
+
+// zipContent returns the entries of a zip archive with their content
+func zipContent(t *testing.T, b []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	entries := make([]string, 0, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		content, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		entries = append(entries, f.Name+"="+string(content))
+	}
+	return strings.Join(entries, ", ")
+}
+
+// tarGzContent returns the entries of a tar.gz archive with their content
+func tarGzContent(t *testing.T, b []byte) string {
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	tr := tar.NewReader(gr)
+	var entries []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		content, _ := ioutil.ReadAll(tr)
+		entries = append(entries, header.Name+"="+string(content))
+	}
+	return strings.Join(entries, ", ")
+}
+
+func TestWrite(t *testing.T) {
+
+	root := vfs.Hide(vfs.Dir(testRoot(t)), vfs.IsDotfile)
+
+	var buf bytes.Buffer
+	if err := archive.Write(&buf, archive.Zip, root, "/docs", nil, "docs", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "docs/=, docs/a.txt=hello, docs/sub/=, docs/sub/b.txt=world", zipContent(t, buf.Bytes()); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	buf.Reset()
+	if err := archive.Write(&buf, archive.TarGz, root, "/docs", nil, "docs", nil); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "docs/=, docs/a.txt=hello, docs/sub/=, docs/sub/b.txt=world", tarGzContent(t, buf.Bytes()); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+}
+
+func TestWriteSelection(t *testing.T) {
+
+	root := vfs.Hide(vfs.Dir(testRoot(t)), vfs.IsDotfile)
+	include := func(name string) bool {
+		return name != "/private"
+	}
+
+	var buf bytes.Buffer
+	names := []string{"docs/sub", "../private", "docs/.env", "docs/sub/b.txt", "missing"}
+	if err := archive.Write(&buf, archive.Zip, root, "/", names, "", include); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "docs/sub/=, docs/sub/b.txt=world", zipContent(t, buf.Bytes()); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+}
+
+func TestSelection(t *testing.T) {
+	tests := []struct {
+		names []string
+		want  string
+	}{
+		{nil, "."},
+		{[]string{"a", "."}, "."},
+		{[]string{"b", "a/c", "a", "../a/d"}, "a, b"},
+		{[]string{"ab", "a"}, "a, ab"},
+		{[]string{"a/c", "a-b", "a"}, "a, a-b"},
+		{[]string{"a/b/c", "a.b", "a/b", "a/b-c/d", "a/b/c/d"}, "a.b, a/b, a/b-c/d"},
+	}
+	for _, test := range tests {
+		if want, have := test.want, strings.Join(archive.Selection(test.names), ", "); want != have {
+			t.Errorf("%#v: expected %#v, got %#v", test.names, want, have)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want archive.Format
+		ok   bool
+	}{
+		{"zip", archive.Zip, true},
+		{"tar.gz", archive.TarGz, true},
+		{"tgz", archive.TarGz, true},
+		{"rar", "", false},
+	}
+	for _, test := range tests {
+		format, err := archive.ParseFormat(test.in)
+		if want, have := test.want, format; want != have {
+			t.Errorf("%#v: expected %#v, got %#v", test.in, want, have)
+		}
+		if want, have := test.ok, err == nil; want != have {
+			t.Errorf("%#v: expected ok %v, got %v", test.in, want, have)
+		}
+	}
+}
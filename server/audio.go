@@ -0,0 +1,300 @@
+package server
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/playlist"
+	"github.com/go-serve/goserve/server/share"
+)
+
+// playlistDownload downloads directories as M3U playlists
+// with "?download=m3u8"
+const playlistDownload = "m3u8"
+
+// audioTypes are the media types of audio files, by extension
+var audioTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".opus": "audio/ogg; codecs=opus",
+}
+
+var tplAudio *template.Template
+
+func init() {
+
+	fs := assets.FileSystem()
+	fh, err := fs.Open("/html/audio.html")
+	if err != nil {
+		log.Print("Failed to load template")
+		panic(err)
+	}
+
+	b, err := ioutil.ReadAll(fh)
+	if err != nil {
+		log.Print("Failed to read template file")
+		panic(err)
+	}
+
+	tplAudio, err = template.New("audio.html").Parse(string(b))
+	if err != nil {
+		log.Print("Failed to parse audio.html into template")
+		panic(err)
+	}
+
+	// side-effect: add extension to mime types
+	// unknown to the system
+	for ext, typ := range audioTypes {
+		if mime.TypeByExtension(ext) == "" {
+			mime.AddExtensionType(ext, typ)
+		}
+	}
+}
+
+type audioTrack struct {
+	Name    string
+	Path    string
+	Page    string
+	Current bool
+}
+
+// ServeAudio displays audio files in an HTML player for
+// "?mode=audioplayer", queued with the other audio files of
+// their directory in the sort order of the listing
+func ServeAudio(root http.FileSystem) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if !wantsAudio(r) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			file, err := root.Open(r.URL.Path)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			stat, err := file.Stat()
+			file.Close()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			} else if !stat.Mode().IsRegular() || !isAudio(stat.Name()) {
+				serveError(w, r, root, http.StatusNotFound)
+				return
+			}
+
+			// queue the audio files of the directory, or
+			// the file alone if they cannot be listed
+			p := path.Clean("/" + r.URL.Path)
+			sort := r.URL.Query().Get("sort")
+			files := []os.FileInfo{stat}
+			if siblings, err := readSorted(root, path.Dir(p), sort); err == nil {
+				files = audioFiles(siblings)
+			}
+			queue := make([]audioTrack, 0, len(files))
+			for _, file := range files {
+				track := path.Join(path.Dir(p), file.Name())
+				queue = append(queue, audioTrack{
+					Name:    file.Name(),
+					Path:    (&url.URL{Path: track}).String(),
+					Page:    (&url.URL{Path: track, RawQuery: audioQuery(sort)}).String(),
+					Current: track == p,
+				})
+			}
+
+			back := "./"
+			if sort != "" {
+				back += "?" + url.Values{"sort": {sort}}.Encode()
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if r.Method == http.MethodHead {
+				return
+			}
+			err = tplAudio.Execute(w, map[string]interface{}{
+				"Name":        stat.Name(),
+				"Path":        (&url.URL{Path: p}).String(),
+				"Back":        back,
+				"Queue":       queue,
+				"Stylesheets": stylesheets,
+			})
+			if err != nil {
+				log.Printf("error executing template audio.html: %s", err.Error())
+			}
+		})
+	}
+}
+
+// ServePlaylist generates a middleware that downloads the audio and
+// video files of directories as an extended M3U playlist, with absolute
+// URLs, for "?download=m3u8". Playlists downloaded through a share link
+// carry its token in their URLs, for players to fetch the files.
+func ServePlaylist(root http.FileSystem) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if !wantsPlaylist(r) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			dir := path.Clean("/" + r.URL.Path)
+			d, err := root.Open(dir)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			stat, err := d.Stat()
+			d.Close()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			} else if !stat.IsDir() {
+				// plain downloads of files
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			files, err := readSorted(root, dir, r.URL.Query().Get("sort"))
+			if err != nil {
+				log.Printf("Error listing path %#v:%s", dir, err)
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			var entries []playlist.Entry
+			for _, file := range playlistFiles(files) {
+				entries = append(entries, playlist.Entry{
+					Title: strings.TrimSuffix(file.Name(), path.Ext(file.Name())),
+					URL:   absoluteURL(r, path.Join(dir, file.Name())),
+				})
+			}
+
+			name := path.Base(dir)
+			if name == "/" {
+				name = "playlist"
+			}
+			w.Header().Set("Content-Type", playlist.ContentType)
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+				"filename": name + "." + playlistDownload,
+			}))
+			w.Header().Set("Cache-Control", "no-store")
+			if r.Method == http.MethodHead {
+				return
+			}
+			if err := playlist.Write(w, name, entries); err != nil {
+				log.Printf("Error writing playlist of %#v: %s", dir, err)
+			}
+		})
+	}
+}
+
+// wantsAudio reports if the request is for the audio player
+func wantsAudio(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.URL.Query().Get("mode") == "audioplayer"
+}
+
+// wantsPlaylist reports if the request is for a playlist
+func wantsPlaylist(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.URL.Query().Get("download") == playlistDownload
+}
+
+// audioQuery returns the query of the audio player
+// keeping the sort order of the listing
+func audioQuery(sort string) string {
+	q := url.Values{"mode": {"audioplayer"}}
+	if sort != "" {
+		q.Set("sort", sort)
+	}
+	return q.Encode()
+}
+
+// isAudio reports if the file name has the extension of
+// an audio file played by the audio player
+func isAudio(name string) bool {
+	_, ok := audioTypes[strings.ToLower(path.Ext(name))]
+	return ok
+}
+
+// audioFiles returns the audio files of the files listed
+func audioFiles(files []os.FileInfo) (audio []os.FileInfo) {
+	for _, file := range files {
+		if file.Mode().IsRegular() && isAudio(file.Name()) {
+			audio = append(audio, file)
+		}
+	}
+	return
+}
+
+// playlistFiles returns the audio and video files of the files listed
+func playlistFiles(files []os.FileInfo) (media []os.FileInfo) {
+	for _, file := range files {
+		if file.Mode().IsRegular() && (isAudio(file.Name()) || isVideo(file.Name())) {
+			media = append(media, file)
+		}
+	}
+	return
+}
+
+// readSorted lists the directory in the sort order of listings
+func readSorted(root http.FileSystem, dir, sort string) ([]os.FileInfo, error) {
+	d, err := root.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	files, err := d.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+	if sort == "" {
+		sort = "-mtime"
+	}
+	api.QuerySort(sort, files)
+	return files, nil
+}
+
+// absoluteURL returns the absolute URL of the path p on the host
+// of the request, with the token of the share link it was served
+// through
+func absoluteURL(r *http.Request, p string) string {
+	u := &url.URL{Scheme: requestScheme(r), Host: r.Host, Path: p}
+	if token := shareToken(r); token != "" {
+		u.RawQuery = url.Values{share.QueryParam: {token}}.Encode()
+	}
+	return u.String()
+}
+
+// requestScheme returns the scheme of the request as sent by the
+// client, which is given in the X-Forwarded-Proto header by reverse
+// proxies terminating TLS
+func requestScheme(r *http.Request) string {
+	proto := strings.SplitN(r.Header.Get("X-Forwarded-Proto"), ",", 2)[0]
+	switch proto = strings.ToLower(strings.TrimSpace(proto)); proto {
+	case "http", "https":
+		return proto
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,150 @@
+// Package auth protects goserve with HTTP Basic authentication
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// DefaultRealm is the realm presented to browsers if a scope has none
+const DefaultRealm = "goserve"
+
+// AnyPath is returned by a PathFunc for requests that may access any
+// path, such as GraphQL queries. Such requests must be authorized
+// for every protected scope.
+const AnyPath = "*"
+
+// Scope protects a URL path prefix with a set of users.
+// A scope without users is public.
+type Scope struct {
+	Prefix string
+	Realm  string
+	Users  Users
+}
+
+// PathFunc returns the URL path of the file a request accesses
+type PathFunc func(r *http.Request) string
+
+type contextKey int
+
+const (
+	ctxKeyUser contextKey = iota
+	ctxKeyUserRecord
+)
+
+// User returns the authenticated user name of the request context,
+// or an empty string for anonymous requests
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(ctxKeyUser).(string)
+	return user
+}
+
+// WithUserRecord returns a context in which Middleware records the
+// authenticated user. It lets outer middlewares, such as access logs,
+// learn the user after the request is served.
+func WithUserRecord(parent context.Context) (ctx context.Context, user func() string) {
+	record := new(string)
+	ctx = context.WithValue(parent, ctxKeyUserRecord, record)
+	user = func() string {
+		return *record
+	}
+	return
+}
+
+// Middleware generates a middleware that requires requests to be
+// authenticated by the users of the scope with the longest prefix
+// matching the accessed path. Paths outside of all scopes are public.
+func Middleware(pathOf PathFunc, scopes ...Scope) midway.Middleware {
+
+	scopes = normalize(scopes)
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			var required []Scope
+			if p := pathOf(r); p == AnyPath {
+				required = scopes
+			} else if scope, ok := match(scopes, p); ok {
+				required = []Scope{scope}
+			}
+
+			user, password, hasAuth := r.BasicAuth()
+			authenticated := false
+			for _, scope := range required {
+				if len(scope.Users) == 0 {
+					continue
+				}
+				if !hasAuth || !scope.Users.Authenticate(user, password) {
+					w.Header().Set("WWW-Authenticate",
+						fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", scope.Realm))
+					http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+					return
+				}
+				authenticated = true
+			}
+
+			if authenticated {
+				if record, ok := r.Context().Value(ctxKeyUserRecord).(*string); ok {
+					*record = user
+				}
+				r = r.WithContext(context.WithValue(r.Context(), ctxKeyUser, user))
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// match finds the scope with the longest prefix of the path
+func match(scopes []Scope, p string) (scope Scope, ok bool) {
+	p = path.Clean("/" + p)
+	for _, scope = range scopes {
+		if scope.Prefix == "/" || p == scope.Prefix || strings.HasPrefix(p, scope.Prefix+"/") {
+			return scope, true
+		}
+	}
+	return Scope{}, false
+}
+
+// Authorizer returns a function that reports if the credentials of
+// the request are authorized for a URL path by the scopes. It serves
+// requests accessing many paths, such as archive downloads. Results
+// are cached by scope, as checking passwords is slow.
+func Authorizer(r *http.Request, scopes ...Scope) func(p string) bool {
+	scopes = normalize(scopes)
+	user, password, hasAuth := r.BasicAuth()
+	authorized := make(map[string]bool)
+	return func(p string) bool {
+		scope, ok := match(scopes, p)
+		if !ok || len(scope.Users) == 0 {
+			return true
+		}
+		ok, cached := authorized[scope.Prefix]
+		if !cached {
+			ok = hasAuth && scope.Users.Authenticate(user, password)
+			authorized[scope.Prefix] = ok
+		}
+		return ok
+	}
+}
+
+// normalize returns a copy of the scopes with clean prefixes and
+// default realms, sorted by the longest prefix first
+func normalize(scopes []Scope) []Scope {
+	scopes = append([]Scope(nil), scopes...)
+	for i := range scopes {
+		scopes[i].Prefix = path.Clean("/" + scopes[i].Prefix)
+		if scopes[i].Realm == "" {
+			scopes[i].Realm = DefaultRealm
+		}
+	}
+	sort.SliceStable(scopes, func(i, j int) bool {
+		return len(scopes[i].Prefix) > len(scopes[j].Prefix)
+	})
+	return scopes
+}
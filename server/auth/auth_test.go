@@ -0,0 +1,144 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/auth"
+)
+
+// htpasswd entries for password "secret"
+const htpasswd = `
+# generated by htpasswd
+alice:$2y$05$B6ygRGeazkT99.VwLuh5BOi/h1.NZkZN5STaTJdgzL4oEhGj0SrKy
+bob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+`
+
+func TestParseHtpasswd(t *testing.T) {
+
+	users, err := auth.ParseHtpasswd(strings.NewReader(htpasswd))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	tests := []struct {
+		user     string
+		password string
+		ok       bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "wrong", false},
+		{"bob", "secret", true},
+		{"bob", "wrong", false},
+		{"carol", "secret", false},
+	}
+	for _, test := range tests {
+		if want, have := test.ok, users.Authenticate(test.user, test.password); want != have {
+			t.Errorf("%s:%s expected %v, got %v", test.user, test.password, want, have)
+		}
+	}
+
+	for _, entry := range []string{
+		"carol:$apr1$abc$def",                  // MD5
+		"carol:$1$abc$def",                     // MD5 crypt
+		"carol:rqXexS6ZhobKA",                  // crypt DES
+		"carol:$5$salt$Gcm6FsVtF/Qa77ZKD.iwsJ", // SHA-256 crypt
+		"carol:$6$salt$IxDD3jeSOb5eB1CX5LBsqZ", // SHA-512 crypt
+		"carol:secret",                         // plain text
+	} {
+		if _, err := auth.ParseHtpasswd(strings.NewReader(entry + "\n")); err == nil {
+			t.Errorf("%s: expected error for unsupported hash", entry)
+		}
+	}
+	if _, err := auth.ParseHtpasswd(strings.NewReader("malformed\n")); err == nil {
+		t.Errorf("expected error for malformed entry")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	users, _ := auth.ParseHtpasswd(strings.NewReader(htpasswd))
+	h := auth.Middleware(
+		func(r *http.Request) string {
+			if r.URL.Path == "/graphql" {
+				return auth.AnyPath
+			}
+			return r.URL.Path
+		},
+		auth.Scope{Prefix: "/", Users: auth.Users{"carol": "plain"}},
+		auth.Scope{Prefix: "/private", Realm: "private", Users: users},
+		auth.Scope{Prefix: "/public"},
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(auth.User(r.Context())))
+	}))
+
+	tests := []struct {
+		path     string
+		user     string
+		password string
+		code     int
+		body     string
+	}{
+		{"/", "", "", http.StatusUnauthorized, ""},
+		{"/", "carol", "plain", http.StatusOK, "carol"},
+		{"/", "alice", "secret", http.StatusUnauthorized, ""},
+		{"/private/file", "alice", "secret", http.StatusOK, "alice"},
+		{"/private/file", "carol", "plain", http.StatusUnauthorized, ""},
+		{"/privatefile", "carol", "plain", http.StatusOK, "carol"},
+		{"/public/file", "", "", http.StatusOK, ""},
+		{"/graphql", "carol", "plain", http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.path, nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, test.password)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s as %#v: expected status %d, got %d", test.path, test.user, want, have)
+			continue
+		}
+		if test.code == http.StatusOK && w.Body.String() != test.body {
+			t.Errorf("%s as %#v: expected user %#v, got %#v", test.path, test.user, test.body, w.Body.String())
+		}
+	}
+
+	// realm of the matching scope
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/private/", nil))
+	if want, have := `Basic realm="private", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+
+	scopes := []auth.Scope{
+		{Prefix: "/", Users: auth.Users{"carol": "plain"}},
+		{Prefix: "/team", Users: auth.Users{"dave": "plain"}},
+		{Prefix: "/team/public"},
+	}
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.SetBasicAuth("carol", "plain")
+	authorized := auth.Authorizer(r, scopes...)
+
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{"/", true},
+		{"/docs/a.txt", true},
+		{"/team", false},
+		{"/team/a.txt", false},
+		{"/team/public/a.txt", true},
+		{"/teamwork", true},
+	}
+	for _, test := range tests {
+		if want, have := test.ok, authorized(test.path); want != have {
+			t.Errorf("%s: expected %v, got %v", test.path, want, have)
+		}
+	}
+}
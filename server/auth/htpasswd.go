@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Users maps user names to password hashes
+//
+// Supported hash formats are bcrypt ("$2y$...", as generated by
+// "htpasswd -B") and SHA-1 ("{SHA}...", as generated by "htpasswd -s").
+// Any other value is compared as a plain text password, so users
+// of htpasswd files are restricted to these formats.
+type Users map[string]string
+
+// ParseHtpasswd parses users from an Apache htpasswd file
+func ParseHtpasswd(r io.Reader) (users Users, err error) {
+	users = make(Users)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			err = fmt.Errorf("htpasswd: malformed entry on line %d", lineNo)
+			return
+		}
+		if !isHash(parts[1]) {
+			err = fmt.Errorf("htpasswd: unsupported hash for user %#v on line %d, use bcrypt (-B) or SHA (-s)",
+				parts[0], lineNo)
+			return
+		}
+		users[parts[0]] = parts[1]
+	}
+	err = scanner.Err()
+	return
+}
+
+// LoadHtpasswd reads users from an Apache htpasswd file
+func LoadHtpasswd(filename string) (users Users, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if users, err = ParseHtpasswd(f); err != nil {
+		err = fmt.Errorf("%s: %s", filename, err)
+	}
+	return
+}
+
+// Authenticate reports if the password matches the user's hash
+func (users Users) Authenticate(user, password string) bool {
+	hash, ok := users[user]
+	if !ok {
+		return false
+	}
+	return checkPassword(hash, password)
+}
+
+// isBcrypt reports if the hash is in bcrypt format
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
+
+// isHash reports if the hash is in a supported format
+func isHash(hash string) bool {
+	return isBcrypt(hash) || strings.HasPrefix(hash, "{SHA}")
+}
+
+func checkPassword(hash, password string) bool {
+	switch {
+	case isBcrypt(hash):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		encoded := base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash[5:]), []byte(encoded)) == 1
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+}
@@ -0,0 +1,287 @@
+// Package compress negotiates gzip and brotli compression of
+// responses, and serves precompressed sibling files
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// DefaultMinSize is the default size threshold in bytes.
+// Smaller responses are not worth compressing.
+const DefaultMinSize = 1024
+
+// supported content encodings
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+)
+
+// Options of dynamic compression
+type Options struct {
+	// MinSize of responses to compress. Defaults to DefaultMinSize.
+	MinSize int
+
+	// NoBrotli disables brotli compression
+	NoBrotli bool
+}
+
+// encodings returns the supported encodings in order of preference
+func (opts Options) encodings() []string {
+	if opts.NoBrotli {
+		return []string{Gzip}
+	}
+	return []string{Brotli, Gzip}
+}
+
+// Compressible reports if responses of the content type
+// benefit from compression
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json",
+		"application/javascript",
+		"application/x-javascript",
+		"application/xml",
+		"application/wasm",
+		"image/bmp",
+		"font/ttf",
+		"font/otf":
+		return true
+	}
+	return false
+}
+
+// Negotiate returns the most preferred encoding accepted by the
+// Accept-Encoding header, or an empty string if none is accepted
+func Negotiate(acceptEncoding string, encodings ...string) string {
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := accepted[encoding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// Middleware generates a middleware that compresses responses of
+// compressible content types with the encoding accepted by client.
+// Range requests are served uncompressed.
+func Middleware(opts Options) midway.Middleware {
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultMinSize
+	}
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "HEAD" || r.Header.Get("Range") != "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{
+				ResponseWriter: w,
+				encoding:       Negotiate(r.Header.Get("Accept-Encoding"), opts.encodings()...),
+				minSize:        opts.MinSize,
+			}
+			defer cw.finish()
+			inner.ServeHTTP(cw, r)
+		})
+	}
+}
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// compressWriter buffers the beginning of a response to decide
+// if it should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	hijack  bool
+	enc     io.WriteCloser
+	gz      *gzip.Writer
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status != 0 || w.hijack {
+		return
+	}
+	w.status = status
+	if status != http.StatusOK {
+		w.decide()
+	}
+}
+
+// Write implements http.ResponseWriter
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) >= w.minSize {
+			if err := w.decide(); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the header, with compression if the response is
+// eligible, and the buffered beginning of the body
+func (w *compressWriter) decide() (err error) {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	header := w.Header()
+	if w.status == http.StatusOK && header.Get("Content-Encoding") == "" {
+		if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		if Compressible(header.Get("Content-Type")) {
+			header.Add("Vary", "Accept-Encoding")
+			if w.encoding != "" && len(w.buf) >= w.minSize {
+				w.start()
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		buf := w.buf
+		w.buf = nil
+		if w.enc != nil {
+			_, err = w.enc.Write(buf)
+		} else {
+			_, err = w.ResponseWriter.Write(buf)
+		}
+	}
+	return
+}
+
+// start compressing the response
+func (w *compressWriter) start() {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	header.Del("Accept-Ranges")
+	if etag := header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("Etag", "W/"+etag)
+	}
+	switch w.encoding {
+	case Brotli:
+		w.enc = brotli.NewWriterLevel(w.ResponseWriter, brotli.DefaultCompression)
+	case Gzip:
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+		w.enc = w.gz
+	}
+}
+
+// finish writes the rest of the response
+func (w *compressWriter) finish() {
+	if w.hijack {
+		return
+	}
+	if !w.decided && (w.status != 0 || len(w.buf) > 0) {
+		w.decide()
+	}
+	if w.enc != nil {
+		w.enc.Close()
+		w.enc = nil
+	}
+	if w.gz != nil {
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// Flush implements http.Flusher, if the underlying
+// writer supports it
+func (w *compressWriter) Flush() {
+	if w.hijack {
+		return
+	}
+	w.decide()
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if the underlying
+// writer supports it
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("compress: response writer does not support hijacking")
+	}
+	w.hijack = true
+	return hijacker.Hijack()
+}
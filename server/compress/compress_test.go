@@ -0,0 +1,161 @@
+package compress_test
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/go-serve/goserve/server/compress"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"gzip, deflate", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"br;q=0.5, gzip", "gzip"},
+		{"br;q=0, gzip;q=0", ""},
+		{"*", "br"},
+		{"identity", ""},
+	}
+	for _, test := range tests {
+		if have := compress.Negotiate(test.accept, compress.Brotli, compress.Gzip); test.want != have {
+			t.Errorf("%#v: expected %#v, got %#v", test.accept, test.want, have)
+		}
+	}
+}
+
+func serve(h http.Handler, path, accept string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	r.Header.Set("Accept-Encoding", accept)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+
+	text := strings.Repeat("hello world ", 200)
+	h := compress.Middleware(compress.Options{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			w.Write([]byte("hello"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(text))
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte(text))
+		}
+	}))
+
+	// gzip
+	w := serve(h, "/text", "gzip")
+	if want, have := "gzip", w.Header().Get("Content-Encoding"); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "Accept-Encoding", w.Header().Get("Vary"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if b, _ := ioutil.ReadAll(gz); text != string(b) {
+		t.Errorf("unexpected body: %#v", string(b))
+	}
+
+	// brotli
+	w = serve(h, "/text", "gzip, br")
+	if want, have := "br", w.Header().Get("Content-Encoding"); want != have {
+		t.Fatalf("expected %#v, got %#v", want, have)
+	}
+	if b, _ := ioutil.ReadAll(brotli.NewReader(w.Body)); text != string(b) {
+		t.Errorf("unexpected body: %#v", string(b))
+	}
+
+	// not compressed
+	for _, test := range []struct {
+		path   string
+		accept string
+		header []string
+	}{
+		{"/small", "gzip", nil},
+		{"/image", "gzip", nil},
+		{"/text", "", nil},
+		{"/text", "gzip", []string{"Range", "bytes=0-10"}},
+	} {
+		w := serve(h, test.path, test.accept, test.header...)
+		if have := w.Header().Get("Content-Encoding"); have != "" {
+			t.Errorf("%s %v: expected no compression, got %#v", test.path, test.header, have)
+		}
+	}
+	if want, have := "hello", serve(h, "/small", "gzip").Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.js":    "console.log('hello')",
+		"app.js.br": "brotli",
+		"app.js.gz": "gzip",
+		"other.js":  "other",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	root := http.Dir(dir)
+	h := compress.Precompressed(root, compress.Options{})(http.FileServer(root))
+
+	tests := []struct {
+		path     string
+		accept   string
+		header   []string
+		encoding string
+		body     string
+	}{
+		{"/app.js", "gzip, br", nil, "br", "brotli"},
+		{"/app.js", "gzip", nil, "gzip", "gzip"},
+		{"/app.js", "", nil, "", "console.log('hello')"},
+		{"/app.js", "gzip", []string{"Range", "bytes=0-6"}, "", "console"},
+		{"/other.js", "gzip", nil, "", "other"},
+	}
+	for _, test := range tests {
+		w := serve(h, test.path, test.accept, test.header...)
+		if want, have := test.encoding, w.Header().Get("Content-Encoding"); want != have {
+			t.Errorf("%s %#v: expected encoding %#v, got %#v", test.path, test.accept, want, have)
+		}
+		if want, have := test.body, w.Body.String(); want != have {
+			t.Errorf("%s %#v: expected body %#v, got %#v", test.path, test.accept, want, have)
+		}
+	}
+
+	w := serve(h, "/app.js", "gzip")
+	if want, have := "application/javascript", w.Header().Get("Content-Type"); !strings.HasPrefix(have, "text/javascript") && !strings.HasPrefix(have, want) {
+		t.Errorf("unexpected content type %#v", have)
+	}
+	if want, have := "Accept-Encoding", w.Header().Get("Vary"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if have := w.Header().Get("Accept-Ranges"); have != "" {
+		t.Errorf("expected no Accept-Ranges, got %#v", have)
+	}
+}
@@ -0,0 +1,109 @@
+package compress
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// extensions of precompressed files by encoding
+var extensions = map[string]string{
+	Brotli: ".br",
+	Gzip:   ".gz",
+}
+
+// Precompressed generates a middleware that serves "name.br" or
+// "name.gz" in place of the requested file "name", if the sibling
+// exists and its encoding is accepted by client. Range requests are
+// served by the inner handler with the uncompressed file.
+func Precompressed(root http.FileSystem, opts Options) midway.Middleware {
+	encodings := opts.encodings()
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			p := r.URL.Path
+			if (r.Method != "GET" && r.Method != "HEAD") || strings.HasSuffix(p, "/") ||
+				r.URL.Query().Get("mode") != "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			original, err := openRegular(root, p)
+			if err != nil {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			original.Close()
+
+			var siblings []string
+			for _, encoding := range encodings {
+				if f, err := openRegular(root, p+extensions[encoding]); err == nil {
+					f.Close()
+					siblings = append(siblings, encoding)
+				}
+			}
+			if len(siblings) == 0 {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			encoding := Negotiate(r.Header.Get("Accept-Encoding"), siblings...)
+			if encoding == "" || r.Header.Get("Range") != "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			f, err := openRegular(root, p+extensions[encoding])
+			if err != nil {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			defer f.Close()
+			stat, err := f.Stat()
+			if err != nil {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			contentType := mime.TypeByExtension(path.Ext(p))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Encoding", encoding)
+			http.ServeContent(noRangesWriter{w}, r, p, stat.ModTime(), f)
+		})
+	}
+}
+
+// noRangesWriter removes the Accept-Ranges header set by
+// http.ServeContent, as ranges are only served uncompressed
+type noRangesWriter struct {
+	http.ResponseWriter
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w noRangesWriter) WriteHeader(status int) {
+	w.Header().Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// openRegular opens the named file if it is a regular file
+func openRegular(root http.FileSystem, name string) (f http.File, err error) {
+	if f, err = root.Open(name); err != nil {
+		return
+	}
+	stat, err := f.Stat()
+	if err == nil && !stat.Mode().IsRegular() {
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		f.Close()
+		f = nil
+	}
+	return
+}
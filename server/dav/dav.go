@@ -0,0 +1,303 @@
+// Package dav serves a goserve root as a WebDAV server, so it
+// can be mounted in file managers and davfs2
+package dav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
+	"golang.org/x/net/webdav"
+)
+
+// DefaultPrefix is the default URL path of the WebDAV server
+const DefaultPrefix = "/_goserve/dav"
+
+// ErrTooLarge is returned for writes beyond the maximum size
+var ErrTooLarge = errors.New("dav: file too large")
+
+// Options configures the WebDAV server
+type Options struct {
+	// Prefix is the URL path of the server, defaults to DefaultPrefix
+	Prefix string
+
+	// Writable reports if the user of the context may modify
+	// the path. The server is read-only if nil.
+	Writable func(ctx context.Context, name string) bool
+
+	// MaxSize in bytes of a written file, 0 for no limit
+	MaxSize int64
+
+	// Conflict policy for writes to existing files, defaults to
+	// upload.Reject. Rename applies as Reject, as clients expect
+	// files at the paths they write.
+	Conflict upload.Conflict
+}
+
+// Middleware generates a middleware that serves the root under the
+// prefix with WebDAV. Writes require a root implementing vfs.Native.
+func Middleware(root http.FileSystem, opts Options) midway.Middleware {
+
+	if opts.Prefix == "" {
+		opts.Prefix = DefaultPrefix
+	}
+	opts.Prefix = strings.TrimRight(path.Clean("/"+opts.Prefix), "/")
+	fs := &fileSystem{root: root, opts: opts}
+	handler := &webdav.Handler{
+		Prefix:     opts.Prefix,
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if r.URL.Path != opts.Prefix && !strings.HasPrefix(r.URL.Path, opts.Prefix+"/") {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			// refuse writes early with proper status code,
+			// as webdav.Handler reports some of them as 404
+			if code := fs.check(r); code != 0 {
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// check returns the status code to refuse a request
+// modifying the file system with, or 0 to proceed
+func (fs *fileSystem) check(r *http.Request) int {
+
+	var modified []string
+	name := strings.TrimPrefix(r.URL.Path, fs.opts.Prefix)
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete, "MKCOL", "PROPPATCH":
+		modified = []string{name}
+	case "MOVE", "COPY":
+		u, err := url.Parse(r.Header.Get("Destination"))
+		if err != nil || (u.Host != "" && u.Host != r.Host) {
+			return http.StatusBadGateway
+		}
+		if !strings.HasPrefix(u.Path, fs.opts.Prefix+"/") {
+			return http.StatusForbidden
+		}
+		modified = []string{strings.TrimPrefix(u.Path, fs.opts.Prefix)}
+		if r.Method == "MOVE" {
+			modified = append(modified, name)
+		}
+	default:
+		return 0
+	}
+
+	if r.Header.Get("Origin") != "" {
+		if u, err := url.Parse(r.Header.Get("Origin")); err != nil || u.Host != r.Host {
+			return http.StatusForbidden
+		}
+	}
+	for _, name := range modified {
+		if !fs.writable(r.Context(), name) {
+			return http.StatusForbidden
+		}
+	}
+	if r.Method == http.MethodPut && fs.opts.MaxSize > 0 && r.ContentLength > fs.opts.MaxSize {
+		return http.StatusRequestEntityTooLarge
+	}
+	if fs.opts.Conflict != upload.Overwrite {
+		switch r.Method {
+		case http.MethodPut:
+			if fs.exists(name) {
+				return http.StatusConflict
+			}
+		case "MOVE", "COPY":
+			// webdav.Handler refuses existing destinations
+			// with 412 Precondition Failed
+			r.Header.Set("Overwrite", "F")
+		}
+	}
+	return 0
+}
+
+// fileSystem implements webdav.FileSystem on top of the served
+// root, so hidden files stay hidden
+type fileSystem struct {
+	root http.FileSystem
+	opts Options
+}
+
+func (fs *fileSystem) writable(ctx context.Context, name string) bool {
+	name = path.Clean("/" + name)
+	return fs.opts.Writable != nil && name != "/" && fs.opts.Writable(ctx, name)
+}
+
+// exists reports if the writable path name exists
+func (fs *fileSystem) exists(name string) bool {
+	native, err := vfs.NativePath(fs.root, name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Lstat(native)
+	return err == nil
+}
+
+// native returns the native path of the writable path name
+func (fs *fileSystem) native(ctx context.Context, name string) (string, error) {
+	if !fs.writable(ctx, name) {
+		return "", &os.PathError{Op: "write", Path: name, Err: os.ErrPermission}
+	}
+	return vfs.NativePath(fs.root, name)
+}
+
+// Mkdir implements webdav.FileSystem
+func (fs *fileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	native, err := fs.native(ctx, name)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(native, perm)
+}
+
+// OpenFile implements webdav.FileSystem
+func (fs *fileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) == 0 {
+		f, err := fs.root.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return readOnlyFile{f}, nil
+	}
+
+	native, err := fs.native(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if flag&os.O_TRUNC == 0 {
+		return os.OpenFile(native, flag, perm)
+	}
+
+	// replace the content atomically when closed
+	if stat, err := os.Stat(filepath.Dir(native)); err != nil {
+		return nil, err
+	} else if !stat.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if flag&os.O_EXCL != 0 || fs.opts.Conflict != upload.Overwrite {
+		if _, err := os.Lstat(native); err == nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(native), upload.TempPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: tmp, target: native, maxSize: fs.opts.MaxSize}, nil
+}
+
+// RemoveAll implements webdav.FileSystem
+func (fs *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	native, err := fs.native(ctx, name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(native)
+}
+
+// Rename implements webdav.FileSystem
+func (fs *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldNative, err := fs.native(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	newNative, err := fs.native(ctx, newName)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldNative, newNative)
+}
+
+// Stat implements webdav.FileSystem
+func (fs *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	f, err := fs.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+// readOnlyFile is a file of the served root opened for reading
+type readOnlyFile struct {
+	http.File
+}
+
+// Write implements io.Writer
+func (f readOnlyFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+// atomicFile writes to a temporary file, which replaces
+// the target file once closed
+type atomicFile struct {
+	*os.File
+	target  string
+	size    int64
+	maxSize int64
+
+	// err is the first error of writes
+	err error
+}
+
+// Write implements io.Writer
+func (f *atomicFile) Write(p []byte) (n int, err error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	if f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize {
+		f.err = ErrTooLarge
+		return 0, f.err
+	}
+	n, err = f.File.Write(p)
+	f.size += int64(n)
+	f.err = err
+	return
+}
+
+// ReadFrom implements io.ReaderFrom, hiding the one of *os.File
+// so io.Copy writes through Write and the size limit applies
+func (f *atomicFile) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{f}, r)
+}
+
+// Close implements io.Closer
+func (f *atomicFile) Close() error {
+	err := f.File.Sync()
+	if cerr := f.File.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = f.err
+	}
+	if err == nil {
+		if err = os.Chmod(f.File.Name(), 0644); err == nil {
+			err = os.Rename(f.File.Name(), f.target)
+		}
+	}
+	if err != nil {
+		os.Remove(f.File.Name())
+	}
+	return err
+}
@@ -0,0 +1,207 @@
+package dav_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/dav"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
+	"github.com/studio-b12/gowebdav"
+)
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+func readDirNames(t *testing.T, c *gowebdav.Client, name string) string {
+	list, err := c.ReadDir(name)
+	if err != nil {
+		t.Fatalf("readdir %s: unexpected error: %s", name, err.Error())
+	}
+	names := make([]string, len(list))
+	for i, item := range list {
+		names[i] = item.Name()
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func TestDAV(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("hello"), 0644)
+	ioutil.WriteFile(filepath.Join(root, ".env"), []byte("SECRET=1"), 0644)
+
+	ts := httptest.NewServer(dav.Middleware(vfs.Hide(vfs.Dir(root), vfs.IsDotfile), dav.Options{
+		Writable: func(ctx context.Context, name string) bool {
+			return !strings.HasPrefix(name, "/readonly")
+		},
+		MaxSize: 10,
+	})(http.HandlerFunc(notFound)))
+	defer ts.Close()
+
+	c := gowebdav.NewClient(ts.URL+dav.DefaultPrefix, "", "")
+
+	// PROPFIND
+	if want, have := "docs", readDirNames(t, c, "/"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// MKCOL, PUT
+	if err := c.Mkdir("/new", 0755); err != nil {
+		t.Errorf("mkdir: unexpected error: %s", err.Error())
+	}
+	if err := c.Write("/new/b.txt", []byte("world"), 0644); err != nil {
+		t.Errorf("write: unexpected error: %s", err.Error())
+	}
+	if err := c.Write("/new/large.txt", []byte("12345678901"), 0644); err == nil {
+		t.Errorf("write: expected error for large file")
+	}
+	if want, have := "b.txt", readDirNames(t, c, "/new"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// COPY, MOVE
+	if err := c.Copy("/docs/a.txt", "/new/c.txt", false); err != nil {
+		t.Errorf("copy: unexpected error: %s", err.Error())
+	}
+	if err := c.Rename("/new/b.txt", "/docs/b.txt", false); err != nil {
+		t.Errorf("move: unexpected error: %s", err.Error())
+	}
+	if want, have := "a.txt, b.txt", readDirNames(t, c, "/docs"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if b, err := c.Read("/new/c.txt"); err != nil || string(b) != "hello" {
+		t.Errorf("read: unexpected %#v, %v", string(b), err)
+	}
+
+	// DELETE
+	if err := c.Remove("/new"); err != nil {
+		t.Errorf("remove: unexpected error: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(root, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected directory removed, got %#v", err)
+	}
+
+	// LOCK and UNLOCK
+	r, _ := http.NewRequest("LOCK", ts.URL+dav.DefaultPrefix+"/docs/a.txt", strings.NewReader(
+		`<?xml version="1.0" encoding="utf-8"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype></D:lockinfo>`))
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatalf("lock: unexpected error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if want, have := http.StatusOK, resp.StatusCode; want != have {
+		t.Errorf("lock: expected status %d, got %d", want, have)
+	}
+	if err := c.Write("/docs/a.txt", []byte("locked"), 0644); err == nil {
+		t.Errorf("write: expected error for locked file")
+	}
+	r, _ = http.NewRequest("UNLOCK", ts.URL+dav.DefaultPrefix+"/docs/a.txt", nil)
+	r.Header.Set("Lock-Token", resp.Header.Get("Lock-Token"))
+	if resp, err = http.DefaultClient.Do(r); err != nil {
+		t.Fatalf("unlock: unexpected error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if want, have := http.StatusNoContent, resp.StatusCode; want != have {
+		t.Errorf("unlock: expected status %d, got %d", want, have)
+	}
+
+	// hidden and read-only paths
+	if _, err := c.Read("/.env"); err == nil {
+		t.Errorf("read: expected error for hidden file")
+	}
+	if err := c.Write("/.env", []byte("x"), 0644); err == nil {
+		t.Errorf("write: expected error for hidden file")
+	}
+	if err := c.Write("/readonly/x.txt", []byte("x"), 0644); err == nil {
+		t.Errorf("write: expected error for read-only path")
+	}
+}
+
+func TestDAVConflict(t *testing.T) {
+
+	tests := []struct {
+		conflict upload.Conflict
+		put      int
+		copy     int
+		content  string
+	}{
+		{upload.Reject, http.StatusConflict, http.StatusPreconditionFailed, "first"},
+		{upload.Rename, http.StatusConflict, http.StatusPreconditionFailed, "first"},
+		{upload.Overwrite, http.StatusCreated, http.StatusNoContent, "third"},
+	}
+	for _, test := range tests {
+		root := t.TempDir()
+		ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("first"), 0644)
+		ioutil.WriteFile(filepath.Join(root, "b.txt"), []byte("third"), 0644)
+
+		ts := httptest.NewServer(dav.Middleware(vfs.Dir(root), dav.Options{
+			Writable: func(ctx context.Context, name string) bool { return true },
+			Conflict: test.conflict,
+		})(http.HandlerFunc(notFound)))
+
+		serve := func(method, path string, headers map[string]string, body string) int {
+			r, _ := http.NewRequest(method, ts.URL+dav.DefaultPrefix+path, strings.NewReader(body))
+			for key, value := range headers {
+				r.Header.Set(key, value)
+			}
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", method, err.Error())
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		if want, have := test.put, serve("PUT", "/a.txt", nil, "second"); want != have {
+			t.Errorf("%s: PUT: expected status %d, got %d", test.conflict, want, have)
+		}
+		copy := map[string]string{"Destination": ts.URL + dav.DefaultPrefix + "/a.txt"}
+		if want, have := test.copy, serve("COPY", "/b.txt", copy, ""); want != have {
+			t.Errorf("%s: COPY: expected status %d, got %d", test.conflict, want, have)
+		}
+		if b, _ := ioutil.ReadFile(filepath.Join(root, "a.txt")); test.content != string(b) {
+			t.Errorf("%s: expected %#v, got %#v", test.conflict, test.content, string(b))
+		}
+		if want, have := http.StatusCreated, serve("PUT", "/c.txt", nil, "new"); want != have {
+			t.Errorf("%s: PUT: expected status %d, got %d", test.conflict, want, have)
+		}
+		ts.Close()
+	}
+}
+
+func TestDAVReadOnly(t *testing.T) {
+
+	root := t.TempDir()
+	ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+
+	ts := httptest.NewServer(dav.Middleware(vfs.Dir(root), dav.Options{})(http.HandlerFunc(notFound)))
+	defer ts.Close()
+	c := gowebdav.NewClient(ts.URL+dav.DefaultPrefix, "", "")
+
+	if b, err := c.Read("/a.txt"); err != nil || string(b) != "hello" {
+		t.Errorf("read: unexpected %#v, %v", string(b), err)
+	}
+	if err := c.Write("/b.txt", []byte("x"), 0644); err == nil {
+		t.Errorf("write: expected error")
+	}
+	if err := c.Remove("/a.txt"); err == nil {
+		t.Errorf("remove: expected error")
+	}
+	if err := c.Mkdir("/dir", 0755); err == nil {
+		t.Errorf("mkdir: expected error")
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/go-serve/goserve/assets"
+)
+
+var tplError *template.Template
+
+func init() {
+
+	fs := assets.FileSystem()
+	fh, err := fs.Open("/html/error.html")
+	if err != nil {
+		log.Print("Failed to load template")
+		panic(err)
+	}
+
+	b, err := ioutil.ReadAll(fh)
+	if err != nil {
+		log.Print("Failed to read template file")
+		panic(err)
+	}
+
+	tplError, err = template.New("error.html").Parse(string(b))
+	if err != nil {
+		log.Print("Failed to parse error.html into template")
+		panic(err)
+	}
+}
+
+// errorStatus maps file system errors to HTTP status code
+func errorStatus(err error) int {
+	switch {
+	case os.IsNotExist(err), errors.Is(err, syscall.ENOTDIR):
+		// including paths inside of files
+		return http.StatusNotFound
+	case os.IsPermission(err):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// wantsJSON determines if the client of a request expects
+// error responses in JSON rather than HTML page
+func wantsJSON(r *http.Request) bool {
+	if hasPathPrefix(r.URL.Path, "/_goserve/api") {
+		return true
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/html":
+			return false
+		}
+	}
+	return false
+}
+
+// serveError writes an error response with the status code.
+//
+// API clients receive a JSON document. Browsers receive the page
+// named after the status code (e.g. "/404.html") in root, or the
+// embedded error page if root has no such file.
+func serveError(w http.ResponseWriter, r *http.Request, root http.FileSystem, code int) {
+
+	// remove headers meant for the response that failed
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Encoding")
+	w.Header().Del("Etag")
+	w.Header().Del("Last-Modified")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if r.Method == http.MethodHead {
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Code    int    `json:"code"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		}{
+			Code:    code,
+			Status:  "error",
+			Message: http.StatusText(code),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	// custom error page in the served directory
+	if root != nil {
+		if page, err := root.Open(fmt.Sprintf("/%d.html", code)); err == nil {
+			defer page.Close()
+			if stat, err := page.Stat(); err == nil && stat.Mode().IsRegular() {
+				w.WriteHeader(code)
+				if r.Method != http.MethodHead {
+					io.Copy(w, page)
+				}
+				return
+			}
+		}
+	}
+
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	err := tplError.Execute(w, map[string]interface{}{
+		"Code":        code,
+		"Status":      http.StatusText(code),
+		"Path":        r.URL.Path,
+		"Stylesheets": stylesheets,
+	})
+	if err != nil {
+		log.Printf("error executing template error.html: %s", err.Error())
+	}
+}
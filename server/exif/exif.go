@@ -0,0 +1,341 @@
+// Package exif reads EXIF metadata of JPEG images
+package exif
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoExif is returned for images without EXIF metadata
+var ErrNoExif = errors.New("exif: no EXIF metadata")
+
+// Exif is the EXIF metadata of an image
+type Exif struct {
+	// Orientation is the transformation to display the image
+	// upright, from 1 (none) to 8, as defined by EXIF
+	Orientation int `json:"orientation"`
+
+	// camera
+	Make      string `json:"make,omitempty"`
+	Model     string `json:"model,omitempty"`
+	LensModel string `json:"lensModel,omitempty"`
+	Software  string `json:"software,omitempty"`
+
+	// DateTime is the capture time, e.g. "2021-07-14T18:30:05",
+	// with the offset from UTC if known, e.g. "2021-07-14T18:30:05+02:00"
+	DateTime string `json:"dateTime,omitempty"`
+
+	// exposure
+	ExposureTime string  `json:"exposureTime,omitempty"`
+	FNumber      float64 `json:"fNumber,omitempty"`
+	ISO          int     `json:"iso,omitempty"`
+	FocalLength  float64 `json:"focalLength,omitempty"`
+
+	GPS *GPS `json:"gps,omitempty"`
+}
+
+// GPS is the location where an image was captured
+type GPS struct {
+	// Latitude and Longitude in degrees, negative
+	// to the south and to the west
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// Altitude in meters above the sea level
+	Altitude float64 `json:"altitude,omitempty"`
+}
+
+// tags of IFD0
+const (
+	tagMake        = 0x010F
+	tagModel       = 0x0110
+	tagOrientation = 0x0112
+	tagSoftware    = 0x0131
+	tagDateTime    = 0x0132
+	tagExifIFD     = 0x8769
+	tagGPSIFD      = 0x8825
+)
+
+// tags of the Exif IFD
+const (
+	tagExposureTime       = 0x829A
+	tagFNumber            = 0x829D
+	tagISO                = 0x8827
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagFocalLength        = 0x920A
+	tagLensModel          = 0xA434
+)
+
+// tags of the GPS IFD
+const (
+	tagLatitudeRef  = 0x0001
+	tagLatitude     = 0x0002
+	tagLongitudeRef = 0x0003
+	tagLongitude    = 0x0004
+	tagAltitudeRef  = 0x0005
+	tagAltitude     = 0x0006
+)
+
+// Decode reads the EXIF metadata of a JPEG image
+func Decode(r io.Reader) (*Exif, error) {
+	data, err := app1(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	t, err := newTIFF(data)
+	if err != nil {
+		return nil, err
+	}
+	ifd0, err := t.ifd(t.first)
+	if err != nil {
+		return nil, err
+	}
+	x := &Exif{
+		Orientation: 1,
+		Make:        t.string(ifd0[tagMake]),
+		Model:       t.string(ifd0[tagModel]),
+		Software:    t.string(ifd0[tagSoftware]),
+		DateTime:    dateTime(t.string(ifd0[tagDateTime]), ""),
+	}
+	if o, ok := t.uint(ifd0[tagOrientation]); ok && o >= 1 && o <= 8 {
+		x.Orientation = int(o)
+	}
+
+	// sub-directories are optional
+	if offset, ok := t.uint(ifd0[tagExifIFD]); ok {
+		if sub, err := t.ifd(offset); err == nil {
+			if original := dateTime(t.string(sub[tagDateTimeOriginal]), t.string(sub[tagOffsetTimeOriginal])); original != "" {
+				x.DateTime = original
+			}
+			x.LensModel = t.string(sub[tagLensModel])
+			x.ExposureTime = exposure(t.rationals(sub[tagExposureTime]))
+			x.FNumber = firstValue(t.rationals(sub[tagFNumber]))
+			x.FocalLength = firstValue(t.rationals(sub[tagFocalLength]))
+			if iso, ok := t.uint(sub[tagISO]); ok {
+				x.ISO = int(iso)
+			}
+		}
+	}
+	if offset, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		if sub, err := t.ifd(offset); err == nil {
+			x.GPS = t.gps(sub)
+		}
+	}
+	return x, nil
+}
+
+// gps reads the location in the GPS IFD, if any
+func (t *tiff) gps(ifd map[uint16]field) *GPS {
+	lat, lon := t.rationals(ifd[tagLatitude]), t.rationals(ifd[tagLongitude])
+	if len(lat) != 3 || len(lon) != 3 {
+		return nil
+	}
+	g := &GPS{
+		Latitude:  lat[0] + lat[1]/60 + lat[2]/3600,
+		Longitude: lon[0] + lon[1]/60 + lon[2]/3600,
+		Altitude:  firstValue(t.rationals(ifd[tagAltitude])),
+	}
+	if t.string(ifd[tagLatitudeRef]) == "S" {
+		g.Latitude = -g.Latitude
+	}
+	if t.string(ifd[tagLongitudeRef]) == "W" {
+		g.Longitude = -g.Longitude
+	}
+	if ref, _ := t.uint(ifd[tagAltitudeRef]); ref == 1 {
+		g.Altitude = -g.Altitude
+	}
+	return g
+}
+
+// dateTime formats an EXIF date and time, "2006:01:02 15:04:05",
+// with the offset, "+07:00", if any
+func dateTime(s, offset string) string {
+	tm, err := time.Parse("2006:01:02 15:04:05", s)
+	if err != nil {
+		return ""
+	}
+	if _, err := time.Parse("-07:00", offset); err != nil {
+		offset = ""
+	}
+	return tm.Format("2006-01-02T15:04:05") + offset
+}
+
+// exposure formats an exposure time in seconds,
+// as a fraction under a second, e.g. "1/250"
+func exposure(r []float64) string {
+	switch {
+	case len(r) == 0 || r[0] <= 0:
+		return ""
+	case r[0] < 1:
+		return "1/" + strconv.FormatFloat(math.Round(1/r[0]), 'f', -1, 64)
+	}
+	return strconv.FormatFloat(r[0], 'f', -1, 64)
+}
+
+func firstValue(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[0]
+}
+
+// app1 returns the TIFF data in the Exif APP1 segment of a JPEG
+func app1(r *bufio.Reader) ([]byte, error) {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil || marker != [2]byte{0xFF, 0xD8} {
+		return nil, errors.New("exif: not a JPEG image")
+	}
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		if marker[0] != 0xFF {
+			return nil, errors.New("exif: invalid JPEG marker")
+		}
+		switch marker[1] {
+		case 0xFF:
+			// padding
+			r.UnreadByte()
+			continue
+		case 0xD8, 0x01, 0xD0, 0xD1, 0xD2, 0xD3, 0xD4, 0xD5, 0xD6, 0xD7:
+			// markers without a segment
+			continue
+		case 0xDA, 0xD9:
+			// image data starts before any EXIF
+			return nil, ErrNoExif
+		}
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil || size < 2 {
+			return nil, ErrNoExif
+		}
+		segment := make([]byte, size-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, ErrNoExif
+		}
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+// field is an entry of an image file directory
+type field struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiff reads the structure of TIFF data
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+	first uint32
+}
+
+func newTIFF(data []byte) (*tiff, error) {
+	if len(data) < 8 {
+		return nil, errors.New("exif: invalid TIFF header")
+	}
+	t := &tiff{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errors.New("exif: invalid TIFF byte order")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, errors.New("exif: invalid TIFF header")
+	}
+	t.first = t.order.Uint32(data[4:])
+	return t, nil
+}
+
+// sizes of the field types, by type
+var typeSizes = [...]uint32{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8}
+
+// ifd reads the fields of the image file directory at the offset
+func (t *tiff) ifd(offset uint32) (map[uint16]field, error) {
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return nil, errors.New("exif: invalid IFD offset")
+	}
+	n := uint32(t.order.Uint16(t.data[offset:]))
+	start := offset + 2
+	if uint64(start)+uint64(n)*12 > uint64(len(t.data)) {
+		return nil, errors.New("exif: invalid IFD size")
+	}
+	fields := make(map[uint16]field, n)
+	for i := uint32(0); i < n; i++ {
+		entry := t.data[start+i*12 : start+i*12+12]
+		f := field{
+			typ:   t.order.Uint16(entry[2:]),
+			count: t.order.Uint32(entry[4:]),
+		}
+		if f.typ == 0 || int(f.typ) >= len(typeSizes) {
+			continue
+		}
+		size := uint64(typeSizes[f.typ]) * uint64(f.count)
+		if size <= 4 {
+			f.value = entry[8 : 8+size]
+		} else {
+			at := uint64(t.order.Uint32(entry[8:]))
+			if at+size > uint64(len(t.data)) {
+				continue
+			}
+			f.value = t.data[at : at+size]
+		}
+		fields[t.order.Uint16(entry)] = f
+	}
+	return fields, nil
+}
+
+// string returns the value of an ASCII field
+func (t *tiff) string(f field) string {
+	if f.typ != 2 {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(f.value), "\x00"))
+}
+
+// rationals returns the values of a RATIONAL or SRATIONAL field
+func (t *tiff) rationals(f field) (values []float64) {
+	if f.typ != 5 && f.typ != 10 {
+		return nil
+	}
+	for i := uint32(0); i < f.count; i++ {
+		num, den := t.order.Uint32(f.value[i*8:]), t.order.Uint32(f.value[i*8+4:])
+		if den == 0 {
+			return nil
+		}
+		if f.typ == 10 {
+			values = append(values, float64(int32(num))/float64(int32(den)))
+		} else {
+			values = append(values, float64(num)/float64(den))
+		}
+	}
+	return
+}
+
+// uint returns the first value of a BYTE, SHORT or LONG field
+func (t *tiff) uint(f field) (uint32, bool) {
+	switch {
+	case f.count == 0:
+		return 0, false
+	case f.typ == 1:
+		return uint32(f.value[0]), true
+	case f.typ == 3:
+		return uint32(t.order.Uint16(f.value)), true
+	case f.typ == 4:
+		return t.order.Uint32(f.value), true
+	}
+	return 0, false
+}
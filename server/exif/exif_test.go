@@ -0,0 +1,204 @@
+package exif_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"math"
+	"testing"
+
+	"github.com/go-serve/goserve/server/exif"
+)
+
+// entry is a field of an image file directory to write
+type entry struct {
+	tag    uint16
+	typ    uint16
+	count  uint32
+	values interface{}
+}
+
+func ascii(tag uint16, s string) entry {
+	return entry{tag, 2, uint32(len(s) + 1), []byte(s + "\x00")}
+}
+
+func short(tag uint16, v uint16) entry {
+	return entry{tag, 3, 1, []uint16{v, 0}}
+}
+
+func rational(tag uint16, v ...uint32) entry {
+	return entry{tag, 5, uint32(len(v) / 2), v}
+}
+
+// tiffData writes the image file directories one after the other.
+// Entries of type 4 (LONG) with a nil value point to the next one.
+func tiffData(order binary.ByteOrder, ifds ...[]entry) []byte {
+	var header, body bytes.Buffer
+	header.WriteString(map[bool]string{true: "MM", false: "II"}[order == binary.BigEndian])
+	binary.Write(&header, order, uint16(42))
+	binary.Write(&header, order, uint32(8))
+
+	offset := uint32(8)
+	for _, ifd := range ifds {
+		// values over 4 bytes follow the entries
+		var data bytes.Buffer
+		for _, e := range ifd {
+			var v bytes.Buffer
+			if e.values != nil {
+				binary.Write(&v, order, e.values)
+			}
+			if v.Len() > 4 {
+				data.Write(v.Bytes())
+			}
+		}
+		size := 2 + 12*uint32(len(ifd)) + 4
+		next := offset + size + uint32(data.Len())
+		dataAt := offset + size
+
+		binary.Write(&body, order, uint16(len(ifd)))
+		for _, e := range ifd {
+			binary.Write(&body, order, []uint16{e.tag, e.typ})
+			binary.Write(&body, order, e.count)
+			var v bytes.Buffer
+			if e.values == nil {
+				binary.Write(&v, order, next)
+			} else {
+				binary.Write(&v, order, e.values)
+			}
+			if v.Len() <= 4 {
+				body.Write(append(v.Bytes(), make([]byte, 4-v.Len())...))
+			} else {
+				binary.Write(&body, order, dataAt)
+				dataAt += uint32(v.Len())
+			}
+		}
+		binary.Write(&body, order, uint32(0))
+		body.Write(data.Bytes())
+		offset = next
+	}
+	return append(header.Bytes(), body.Bytes()...)
+}
+
+// withExif returns a JPEG image with the EXIF TIFF data
+func withExif(t *testing.T, tiff []byte) []byte {
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var out bytes.Buffer
+	out.Write(img.Bytes()[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+6+len(tiff)))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff)
+	out.Write(img.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		tiff := tiffData(order, []entry{short(0x0112, 6)})
+		x, err := exif.Decode(bytes.NewReader(withExif(t, tiff)))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", order, err.Error())
+		}
+		if want, have := 6, x.Orientation; want != have {
+			t.Errorf("%s: expected orientation %d, got %d", order, want, have)
+		}
+	}
+
+	// invalid orientation
+	x, err := exif.Decode(bytes.NewReader(withExif(t, tiffData(binary.BigEndian, []entry{short(0x0112, 12)}))))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 1, x.Orientation; want != have {
+		t.Errorf("expected orientation %d, got %d", want, have)
+	}
+
+	// without EXIF
+	var img bytes.Buffer
+	jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil)
+	if _, err := exif.Decode(&img); err != exif.ErrNoExif {
+		t.Errorf("expected ErrNoExif, got %#v", err)
+	}
+	if _, err := exif.Decode(bytes.NewReader([]byte("\x89PNG\r\n"))); err == nil {
+		t.Errorf("expected error for PNG")
+	}
+}
+
+func TestDecodeCamera(t *testing.T) {
+	tiff := tiffData(binary.LittleEndian,
+		[]entry{
+			ascii(0x010F, "Canon"),
+			ascii(0x0110, "Canon EOS 5D"),
+			short(0x0112, 1),
+			ascii(0x0132, "2021:07:15 10:00:00"),
+			{0x8769, 4, 1, nil},
+		},
+		[]entry{
+			rational(0x829A, 1, 250),
+			rational(0x829D, 28, 10),
+			short(0x8827, 400),
+			ascii(0x9003, "2021:07:14 18:30:05"),
+			ascii(0x9011, "+02:00"),
+			rational(0x920A, 50, 1),
+			ascii(0xA434, "EF 50mm  "),
+		},
+	)
+	x, err := exif.Decode(bytes.NewReader(withExif(t, tiff)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	want := exif.Exif{
+		Orientation:  1,
+		Make:         "Canon",
+		Model:        "Canon EOS 5D",
+		LensModel:    "EF 50mm",
+		DateTime:     "2021-07-14T18:30:05+02:00",
+		ExposureTime: "1/250",
+		FNumber:      2.8,
+		ISO:          400,
+		FocalLength:  50,
+	}
+	if have := *x; want != have {
+		t.Errorf("expected:\n%#v\ngot:\n%#v", want, have)
+	}
+}
+
+func TestDecodeGPS(t *testing.T) {
+	tiff := tiffData(binary.BigEndian,
+		[]entry{
+			ascii(0x0132, "2021:07:15 10:00:00"),
+			{0x8825, 4, 1, nil},
+		},
+		[]entry{
+			ascii(0x0001, "S"),
+			rational(0x0002, 33, 1, 51, 1, 3096, 100),
+			ascii(0x0003, "E"),
+			rational(0x0004, 151, 1, 12, 1, 3600, 100),
+			{0x0005, 1, 1, []byte{1}},
+			rational(0x0006, 12, 1),
+		},
+	)
+	x, err := exif.Decode(bytes.NewReader(withExif(t, tiff)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "2021-07-15T10:00:00", x.DateTime; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if x.GPS == nil {
+		t.Fatalf("expected GPS")
+	}
+	if want, have := -33.8586, x.GPS.Latitude; math.Abs(want-have) > 1e-4 {
+		t.Errorf("expected latitude %f, got %f", want, have)
+	}
+	if want, have := 151.21, x.GPS.Longitude; math.Abs(want-have) > 1e-4 {
+		t.Errorf("expected longitude %f, got %f", want, have)
+	}
+	if want, have := -12.0, x.GPS.Altitude; want != have {
+		t.Errorf("expected altitude %f, got %f", want, have)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/thumb"
+)
+
+const thumbPath = "/_goserve/thumb"
+
+var tplGallery *template.Template
+
+func init() {
+
+	fs := assets.FileSystem()
+	fh, err := fs.Open("/html/gallery.html")
+	if err != nil {
+		log.Print("Failed to load template")
+		panic(err)
+	}
+
+	b, err := ioutil.ReadAll(fh)
+	if err != nil {
+		log.Print("Failed to read template file")
+		panic(err)
+	}
+
+	tplGallery, err = template.New("gallery.html").Parse(string(b))
+	if err != nil {
+		log.Print("Failed to parse gallery.html into template")
+		panic(err)
+	}
+}
+
+type galleryImage struct {
+	Name    string
+	Path    string
+	Thumb   string
+	Thumb2x string
+}
+
+// ServeGallery displays the images of directories as a grid
+// of thumbnails for "?mode=gallery"
+func ServeGallery(root http.FileSystem) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if !wantsGallery(r) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			dir, err := root.Open(r.URL.Path)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			defer dir.Close()
+			stat, err := dir.Stat()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			} else if !stat.IsDir() {
+				serveError(w, r, root, http.StatusNotFound)
+				return
+			}
+			files, err := dir.Readdir(0)
+			if err != nil {
+				log.Printf("Error listing path %#v:%s", r.URL.Path, err)
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+
+			// sort as the listing
+			s := r.URL.Query().Get("sort")
+			if s == "" {
+				s = "-mtime"
+			}
+			api.QuerySort(s, files)
+
+			base := path.Clean("/" + r.URL.Path)
+			images := make([]galleryImage, 0, len(files))
+			for _, file := range galleryFiles(files) {
+				p := path.Join(base, file.Name())
+				images = append(images, galleryImage{
+					Name:    file.Name(),
+					Path:    (&url.URL{Path: p}).String(),
+					Thumb:   thumb.URL(thumbPath, p, thumb.DefaultWidth),
+					Thumb2x: thumb.URL(thumbPath, p, 2*thumb.DefaultWidth),
+				})
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if r.Method == http.MethodHead {
+				return
+			}
+			err = tplGallery.Execute(w, map[string]interface{}{
+				"Base":        base,
+				"Images":      images,
+				"Stylesheets": stylesheets,
+			})
+			if err != nil {
+				log.Printf("error executing template gallery.html: %s", err.Error())
+			}
+		})
+	}
+}
+
+// wantsGallery reports if the request is for the gallery view
+func wantsGallery(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.URL.Query().Get("mode") == "gallery"
+}
+
+// galleryFiles returns the images of the files listed
+func galleryFiles(files []os.FileInfo) (images []os.FileInfo) {
+	for _, file := range files {
+		if file.Mode().IsRegular() && thumb.IsImage(file.Name()) {
+			images = append(images, file)
+		}
+	}
+	return
+}
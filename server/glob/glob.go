@@ -0,0 +1,108 @@
+// Package glob matches slash separated URL paths against glob patterns.
+//
+// Patterns support "*" (any sequence of characters except "/"), "?" (any
+// single character except "/"), character classes like "[a-z]" or "[!0-9]"
+// and "**" (any sequence of characters including "/").
+//
+// A pattern that contains a slash is matched against the full path,
+// which always starts with a slash. A pattern without slash is matched
+// against the base name of the path.
+package glob
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled glob pattern
+type Pattern struct {
+	pattern  string
+	anchored bool
+	re       *regexp.Regexp
+}
+
+// Compile parses a glob pattern
+func Compile(pattern string) (p *Pattern, err error) {
+
+	if pattern == "" {
+		err = fmt.Errorf("glob: empty pattern")
+		return
+	}
+
+	p = &Pattern{pattern: pattern}
+	expr := pattern
+	if strings.Contains(pattern, "/") {
+		p.anchored = true
+		if !strings.HasPrefix(expr, "/") {
+			expr = "/" + expr
+		}
+	}
+
+	var buf strings.Builder
+	buf.WriteString("^")
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch c {
+		case '*':
+			if i+1 < len(expr) && expr[i+1] == '*' {
+				i++
+				if i+1 < len(expr) && expr[i+1] == '/' {
+					// "**/" matches zero or more directories
+					i++
+					buf.WriteString("(?:.*/)?")
+					continue
+				}
+				buf.WriteString(".*")
+				continue
+			}
+			buf.WriteString("[^/]*")
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(expr[i+1:], ']')
+			if end < 0 {
+				err = fmt.Errorf("glob: unterminated character class in %#v", pattern)
+				return nil, err
+			}
+			class := expr[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i += end + 1
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	buf.WriteString("$")
+
+	if p.re, err = regexp.Compile(buf.String()); err != nil {
+		err = fmt.Errorf("glob: invalid pattern %#v: %s", pattern, err)
+		return nil, err
+	}
+	return
+}
+
+// MustCompile is like Compile but panics if the pattern is invalid
+func MustCompile(pattern string) *Pattern {
+	p, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Match reports whether the URL path matches the pattern
+func (p *Pattern) Match(name string) bool {
+	if p.anchored {
+		return p.re.MatchString(path.Clean("/" + name))
+	}
+	return p.re.MatchString(path.Base(name))
+}
+
+// String returns the source pattern
+func (p *Pattern) String() string {
+	return p.pattern
+}
@@ -0,0 +1,50 @@
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/go-serve/goserve/server/glob"
+)
+
+func TestMatch(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.css", "/style.css", true},
+		{"*.css", "/assets/css/style.css", true},
+		{"*.css", "/style.css.map", false},
+		{".git", "/foo/.git", true},
+		{"foo?.txt", "/foo1.txt", true},
+		{"foo[0-9].txt", "/foo1.txt", true},
+		{"foo[!0-9].txt", "/foo1.txt", false},
+		{"/assets/*", "/assets/app.js", true},
+		{"/assets/*", "/assets/js/app.js", false},
+		{"/assets/**", "/assets/js/app.js", true},
+		{"assets/**/*.js", "/assets/app.js", true},
+		{"assets/**/*.js", "/assets/js/lib/app.js", true},
+		{"assets/**/*.js", "/others/assets/app.js", false},
+		{"/docs/**", "/docs", false},
+		{"/*.html", "/index.html", true},
+		{"/*.html", "/foo/index.html", false},
+	}
+
+	for _, test := range tests {
+		p, err := glob.Compile(test.pattern)
+		if err != nil {
+			t.Errorf("%#v: unexpected error: %s", test.pattern, err.Error())
+			continue
+		}
+		if want, have := test.match, p.Match(test.name); want != have {
+			t.Errorf("%#v matching %#v: expected %v, got %v", test.pattern, test.name, want, have)
+		}
+	}
+
+	for _, pattern := range []string{"", "foo[bar"} {
+		if _, err := glob.Compile(pattern); err == nil {
+			t.Errorf("%#v: expected error", pattern)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-serve/goserve/server/glob"
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// HeaderRule sets response headers for requests
+// with URL path matching the pattern
+type HeaderRule struct {
+	// Pattern to match the URL path. Nil matches all paths.
+	Pattern *glob.Pattern
+
+	// Header to set on response
+	Header http.Header
+}
+
+// ServeHeaders generates a middleware that sets the response headers
+// of all matching rules. Later rules override earlier ones.
+func ServeHeaders(rules []HeaderRule) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, rule := range rules {
+				if rule.Pattern != nil && !rule.Pattern.Match(r.URL.Path) {
+					continue
+				}
+				for key, values := range rule.Header {
+					w.Header()[http.CanonicalHeaderKey(key)] = values
+				}
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/go-serve/goserve/server/ignore"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+// HiddenPolicy determines how dotfiles, files and directories with
+// a name starting with ".", are served
+type HiddenPolicy string
+
+// hidden policies
+const (
+	// HiddenShow lists and serves dotfiles like other files
+	HiddenShow HiddenPolicy = "show"
+
+	// HiddenHide leaves dotfiles out of listings, but serves
+	// them to requests of their exact path
+	HiddenHide HiddenPolicy = "hide"
+
+	// HiddenDeny leaves dotfiles out of listings and refuses
+	// requests to them with 403 Forbidden
+	HiddenDeny HiddenPolicy = "deny"
+)
+
+// wellKnown is the directory of well-known URIs (RFC 8615), e.g. for
+// ACME challenges, served whatever the hidden policy
+const wellKnown = "/.well-known"
+
+// ParseHiddenPolicy parses "show", "hide" or "deny"
+func ParseHiddenPolicy(s string) (policy HiddenPolicy, err error) {
+	switch policy = HiddenPolicy(s); policy {
+	case HiddenShow, HiddenHide, HiddenDeny:
+		return
+	}
+	return "", fmt.Errorf("unknown hidden policy %#v, expected show, hide or deny", s)
+}
+
+// hide wraps the root with the ignore files, hidden patterns
+// and hidden policy of the file server. Ignore files are read from
+// the first root hidden, the files served, as the members of their
+// archives are hidden again by their path in it.
+func (fs *fileServer) hide(root http.FileSystem) http.FileSystem {
+
+	if len(fs.ignoreFiles) > 0 {
+		if fs.ignore == nil {
+			fs.ignore = ignore.New(root, fs.ignoreFiles...)
+		}
+		root = vfs.Hide(root, fs.ignore.Ignored)
+	}
+	if fs.hidden != nil {
+		root = vfs.Hide(root, fs.hidden)
+	}
+	if fs.upload != nil {
+		root = vfs.Hide(root, upload.IsTemp)
+	}
+
+	switch fs.hiddenPolicy {
+	case HiddenHide:
+		root = vfs.Unlist(root, isDotfile)
+	case HiddenDeny:
+		root = vfs.Deny(root, isDotfile)
+	}
+	return root
+}
+
+// isDotfile is vfs.IsDotfile, but for the well-known directory
+func isDotfile(name string, isDir bool) bool {
+	return vfs.IsDotfile(name, isDir) && path.Clean("/"+name) != wellKnown
+}
@@ -0,0 +1,241 @@
+// Package ignore hides files listed in ignore files with the
+// syntax of .gitignore, such as .goserveignore
+package ignore
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-serve/goserve/server/glob"
+)
+
+// names of the ignore files
+const (
+	GoserveIgnore = ".goserveignore"
+	GitIgnore     = ".gitignore"
+)
+
+// ReloadInterval is the minimum interval between
+// checks of an ignore file for changes
+var ReloadInterval = time.Second
+
+// Rule is a line of an ignore file
+type Rule struct {
+	pattern *glob.Pattern
+	negate  bool
+	dirOnly bool
+}
+
+// Match reports if the rule matches the slash separated path
+func (rule Rule) Match(name string, isDir bool) bool {
+	if rule.dirOnly && !isDir {
+		return false
+	}
+	return rule.pattern.Match(name)
+}
+
+// String returns the glob pattern of the rule
+func (rule Rule) String() string {
+	return rule.pattern.String()
+}
+
+// Parse reads the rules of an ignore file in the directory dir.
+// Lines with invalid patterns are skipped and the first error
+// is returned.
+func Parse(dir string, r io.Reader) (rules []Rule, err error) {
+
+	dir = path.Clean("/" + dir)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		rule, ok, perr := parseLine(dir, scanner.Text())
+		if perr != nil {
+			if err == nil {
+				err = perr
+			}
+			continue
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	if serr := scanner.Err(); serr != nil && err == nil {
+		err = serr
+	}
+	return
+}
+
+// parseLine parses a line of ignore file into a rule. Blank
+// lines and comments are reported as not ok.
+func parseLine(dir, line string) (rule Rule, ok bool, err error) {
+
+	// trailing spaces are ignored unless escaped with backslash
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	line = strings.Replace(line, `\ `, " ", -1)
+	if line == "" {
+		return
+	}
+
+	// a slash at the beginning or middle anchors the pattern to
+	// the directory of the ignore file, otherwise it matches
+	// at any level below
+	pattern := strings.TrimPrefix(line, "/")
+	if !strings.Contains(line, "/") {
+		pattern = "**/" + pattern
+	}
+	if rule.pattern, err = glob.Compile(path.Join(dir, pattern)); err != nil {
+		return
+	}
+	ok = true
+	return
+}
+
+// Matcher hides paths ignored by the ignore files in their
+// parent directories, reloaded when the files change.
+// Rules of deeper ignore files take precedence, and the
+// last matching rule of a file wins.
+type Matcher struct {
+	root  http.FileSystem
+	names []string
+
+	mutex sync.Mutex
+	dirs  map[string]*ignoreFiles
+}
+
+// ignoreFiles are the parsed ignore files of a directory
+type ignoreFiles struct {
+	checked time.Time
+	stamps  []stamp
+	rules   []Rule
+}
+
+// stamp identifies a version of a file.
+// The zero value is a missing file.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New returns a Matcher of the ignore files with the given
+// names (e.g. GoserveIgnore) in the file system
+func New(root http.FileSystem, names ...string) *Matcher {
+	return &Matcher{
+		root:  root,
+		names: names,
+		dirs:  make(map[string]*ignoreFiles),
+	}
+}
+
+// Ignored reports if the slash separated path is ignored.
+// It implements vfs.HideFunc.
+func (m *Matcher) Ignored(name string, isDir bool) bool {
+
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return false
+	}
+
+	// collect rules from the top most directory down
+	var dirs []string
+	for dir := path.Dir(name); ; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "/" {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, rule := range m.rules(dirs[i]) {
+			if rule.Match(name, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// rules returns the rules of ignore files in the directory
+func (m *Matcher) rules(dir string) []Rule {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	files, ok := m.dirs[dir]
+	if !ok {
+		files = &ignoreFiles{stamps: make([]stamp, len(m.names))}
+		m.dirs[dir] = files
+	} else if time.Since(files.checked) < ReloadInterval {
+		return files.rules
+	}
+	files.checked = time.Now()
+
+	// reload all files of the directory if any changed
+	stamps := make([]stamp, len(m.names))
+	changed := !ok
+	for i, name := range m.names {
+		stamps[i] = m.stamp(path.Join(dir, name))
+		changed = changed || stamps[i] != files.stamps[i]
+	}
+	if !changed {
+		return files.rules
+	}
+
+	files.stamps = stamps
+	files.rules = nil
+	for i, name := range m.names {
+		if stamps[i] == (stamp{}) {
+			continue
+		}
+		files.rules = append(files.rules, m.load(dir, name)...)
+	}
+	return files.rules
+}
+
+// stamp returns the stamp of the regular file at the path
+func (m *Matcher) stamp(name string) (s stamp) {
+	f, err := m.root.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if stat, err := f.Stat(); err == nil && stat.Mode().IsRegular() {
+		s = stamp{stat.ModTime(), stat.Size()}
+	}
+	return
+}
+
+// load parses the ignore file of the name in directory dir
+func (m *Matcher) load(dir, name string) []Rule {
+	f, err := m.root.Open(path.Join(dir, name))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	rules, err := Parse(dir, f)
+	if err != nil {
+		log.Printf("Error in %s: %s", path.Join(dir, name), err)
+	}
+	return rules
+}
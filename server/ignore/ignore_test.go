@@ -0,0 +1,104 @@
+package ignore_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/ignore"
+)
+
+func TestParse(t *testing.T) {
+
+	rules, err := ignore.Parse("/sub", strings.NewReader(`
+# comment
+*.log
+!keep.log
+/build
+docs/*.tmp
+cache/
+\#hash
+[
+`))
+	if err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+	if want, have := 6, len(rules); want != have {
+		t.Fatalf("expected %d rules, got %d", want, have)
+	}
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		matches []bool
+	}{
+		{"/sub/app.log", false, []bool{true, false, false, false, false, false}},
+		{"/sub/a/b/keep.log", false, []bool{true, true, false, false, false, false}},
+		{"/app.log", false, []bool{false, false, false, false, false, false}},
+		{"/sub/build", true, []bool{false, false, true, false, false, false}},
+		{"/sub/a/build", true, []bool{false, false, false, false, false, false}},
+		{"/sub/docs/x.tmp", false, []bool{false, false, false, true, false, false}},
+		{"/sub/a/cache", true, []bool{false, false, false, false, true, false}},
+		{"/sub/a/cache", false, []bool{false, false, false, false, false, false}},
+		{"/sub/#hash", false, []bool{false, false, false, false, false, true}},
+	}
+	for _, test := range tests {
+		for i, rule := range rules {
+			if want, have := test.matches[i], rule.Match(test.name, test.isDir); want != have {
+				t.Errorf("%s: rule %s: expected %v, got %v", test.name, rule, want, have)
+			}
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "goserve-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		".goserveignore":     "node_modules/\n*.secret\n",
+		"sub/.goserveignore": "!public.secret\n",
+		"sub/.gitignore":     "dist\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	m := ignore.New(http.Dir(root), ignore.GoserveIgnore)
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"/node_modules", true, true},
+		{"/sub/node_modules", true, true},
+		{"/node_modules", false, false},
+		{"/a.secret", false, true},
+		{"/sub/a.secret", false, true},
+		{"/sub/public.secret", false, false},
+		{"/public.secret", false, true},
+		{"/sub/dist", true, false},
+		{"/", true, false},
+	}
+	for _, test := range tests {
+		if want, have := test.ignored, m.Ignored(test.name, test.isDir); want != have {
+			t.Errorf("%s: expected %v, got %v", test.name, want, have)
+		}
+	}
+
+	m = ignore.New(http.Dir(root), ignore.GoserveIgnore, ignore.GitIgnore)
+	if !m.Ignored("/sub/dist", true) {
+		t.Errorf("expected /sub/dist to be ignored with .gitignore")
+	}
+}
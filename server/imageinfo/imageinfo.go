@@ -0,0 +1,86 @@
+// Package imageinfo reads the dimensions and metadata of images
+package imageinfo
+
+import (
+	"image"
+	"image/color"
+	_ "image/gif"  // decoder of GIF images
+	_ "image/jpeg" // decoder of JPEG images
+	_ "image/png"  // decoder of PNG images
+	"io"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/server/exif"
+)
+
+// Info is the dimensions and metadata of an image
+type Info struct {
+	// Format is "jpeg", "png" or "gif"
+	Format string `json:"format"`
+
+	// Width and Height in pixels, as stored: photos
+	// may be displayed rotated by their orientation
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// ColorModel is "rgb", "rgba", "gray", "ycbcr", "cmyk",
+	// "paletted" or "other"
+	ColorModel string `json:"colorModel"`
+
+	// Exif is the EXIF metadata of JPEG images, if any
+	Exif *exif.Exif `json:"exif,omitempty"`
+}
+
+// IsImage reports if the file name has the extension
+// of an image format read by the package
+func IsImage(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// Read reads the dimensions and metadata of an image, without
+// decoding its pixels
+func Read(r io.ReadSeeker) (*Info, error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{
+		Format:     format,
+		Width:      config.Width,
+		Height:     config.Height,
+		ColorModel: colorModel(config.ColorModel),
+	}
+	if format == "jpeg" {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if x, err := exif.Decode(r); err == nil {
+			info.Exif = x
+		}
+	}
+	return info, nil
+}
+
+func colorModel(m color.Model) string {
+	if _, ok := m.(color.Palette); ok {
+		return "paletted"
+	}
+	switch m {
+	case color.RGBAModel, color.RGBA64Model:
+		return "rgb"
+	case color.NRGBAModel, color.NRGBA64Model, color.AlphaModel, color.Alpha16Model:
+		return "rgba"
+	case color.GrayModel, color.Gray16Model:
+		return "gray"
+	case color.YCbCrModel, color.NYCbCrAModel:
+		return "ycbcr"
+	case color.CMYKModel:
+		return "cmyk"
+	}
+	return "other"
+}
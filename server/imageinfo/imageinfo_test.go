@@ -0,0 +1,80 @@
+package imageinfo_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/go-serve/goserve/server/imageinfo"
+)
+
+func TestRead(t *testing.T) {
+
+	rect := image.Rect(0, 0, 30, 20)
+	encode := map[string]func() []byte{
+		"png rgba 30x20": func() []byte {
+			var b bytes.Buffer
+			img := image.NewNRGBA(rect)
+			img.Set(0, 0, color.NRGBA{0, 0, 0, 128})
+			png.Encode(&b, img)
+			return b.Bytes()
+		},
+		"png gray 30x20": func() []byte {
+			var b bytes.Buffer
+			png.Encode(&b, image.NewGray(rect))
+			return b.Bytes()
+		},
+		"gif paletted 30x20": func() []byte {
+			var b bytes.Buffer
+			gif.Encode(&b, image.NewPaletted(rect, palette.Plan9), nil)
+			return b.Bytes()
+		},
+		"jpeg ycbcr 30x20": func() []byte {
+			var b bytes.Buffer
+			jpeg.Encode(&b, image.NewRGBA(rect), nil)
+			return b.Bytes()
+		},
+	}
+	for want, content := range encode {
+		info, err := imageinfo.Read(bytes.NewReader(content()))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", want, err.Error())
+		}
+		have := fmt.Sprintf("%s %s %dx%d", info.Format, info.ColorModel, info.Width, info.Height)
+		if want != have {
+			t.Errorf("expected %#v, got %#v", want, have)
+		}
+		if info.Exif != nil {
+			t.Errorf("%s: unexpected EXIF %#v", want, info.Exif)
+		}
+	}
+
+	if _, err := imageinfo.Read(bytes.NewReader([]byte("not an image"))); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestIsImage(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"a.jpg", true},
+		{"b.JPEG", true},
+		{"c.png", true},
+		{"d.gif", true},
+		{"e.webp", false},
+		{"jpg", false},
+	}
+	for _, test := range tests {
+		if want, have := test.ok, imageinfo.IsImage(test.name); want != have {
+			t.Errorf("%s: expected %v, got %v", test.name, want, have)
+		}
+	}
+}
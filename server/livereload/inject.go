@@ -0,0 +1,161 @@
+package livereload
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// clientScript connects to the event stream and reloads the page,
+// or only the stylesheets if nothing else is changed
+const clientScript = `(function () {
+  if (!window.EventSource) return;
+  var source = new EventSource(%q);
+  source.addEventListener('change', function (e) {
+    var ev = JSON.parse(e.data);
+    if (ev.type !== 'css') {
+      window.location.reload();
+      return;
+    }
+    var links = document.querySelectorAll('link[rel="stylesheet"]');
+    Array.prototype.forEach.call(links, function (link) {
+      var url = new URL(link.href, window.location.href);
+      if (url.origin !== window.location.origin) return;
+      url.searchParams.set('_goserve_reload', Date.now());
+      var next = link.cloneNode();
+      next.href = url.toString();
+      next.onload = function () { link.parentNode && link.parentNode.removeChild(link); };
+      link.parentNode.insertBefore(next, link.nextSibling);
+    });
+  });
+})();
+`
+
+// Middleware serves the event stream and the client script under
+// the given path, and injects the client script into HTML responses
+// of the inner handler
+func (lr *Reloader) Middleware(path string) midway.Middleware {
+
+	path = strings.TrimRight(path, "/")
+	eventsPath := path + "/events"
+	scriptPath := path + "/client.js"
+	script := fmt.Sprintf(clientScript, eventsPath)
+	tag := []byte(`<script src="` + scriptPath + `"></script>`)
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			switch r.URL.Path {
+			case eventsPath:
+				lr.ServeEvents(w, r)
+				return
+			case scriptPath:
+				w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+				w.Header().Set("Cache-Control", "no-cache")
+				fmt.Fprint(w, script)
+				return
+			}
+
+			if r.Method != "GET" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			iw := &injectWriter{ResponseWriter: w, tag: tag}
+			inner.ServeHTTP(iw, r)
+			iw.finish()
+		})
+	}
+}
+
+// injectWriter buffers full page HTML responses
+// and inserts the script tag before </body>
+type injectWriter struct {
+	http.ResponseWriter
+	tag         []byte
+	wroteHeader bool
+	inject      bool
+	buf         bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *injectWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code == http.StatusOK &&
+		strings.HasPrefix(h.Get("Content-Type"), "text/html") &&
+		h.Get("Content-Encoding") == "" {
+		w.inject = true
+		h.Del("Content-Length")
+		h.Del("Etag") // the body differs from the file on disk
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter
+func (w *injectWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.inject {
+		return w.buf.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Hijack implements http.Hijacker, if the underlying
+// writer supports it
+func (w *injectWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("livereload: response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// Flush implements http.Flusher, if the underlying writer supports
+// it. The buffered HTML is written up to the last "</body>", or up to
+// the bytes that may start one, to inject the script tag when finished.
+func (w *injectWriter) Flush() {
+	if w.inject {
+		body := w.buf.Bytes()
+		keep := bytes.LastIndex(bytes.ToLower(body), []byte("</body>"))
+		if keep < 0 {
+			keep = len(body) - len("</body>") + 1
+		}
+		if keep > 0 {
+			w.ResponseWriter.Write(body[:keep])
+			w.buf.Next(keep)
+		}
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish writes the buffered body with the script tag injected
+func (w *injectWriter) finish() {
+	if !w.inject {
+		return
+	}
+	body := w.buf.Bytes()
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		w.ResponseWriter.Write(body[:i])
+		w.ResponseWriter.Write(w.tag)
+		w.ResponseWriter.Write(body[i:])
+		return
+	}
+	w.ResponseWriter.Write(body)
+	w.ResponseWriter.Write(w.tag)
+}
@@ -0,0 +1,265 @@
+// Package livereload watches a directory for changes and notifies
+// browsers, through server-sent events, to reload the page or
+// hot-swap stylesheets.
+package livereload
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounce is the time to wait for further changes before notifying
+// browsers. Editors and build tools often write several files at once.
+const debounce = 100 * time.Millisecond
+
+// heartbeat is the interval to send a comment line to keep
+// event stream connections alive through proxies
+const heartbeat = 30 * time.Second
+
+// skipDirs are directories that are not watched
+var skipDirs = map[string]bool{
+	".git":         true,
+	".hg":          true,
+	".svn":         true,
+	"node_modules": true,
+}
+
+// Event is the change notification sent to browsers
+type Event struct {
+	// Type is "css" if only stylesheets are changed,
+	// or "reload" for any other changes
+	Type string `json:"type"`
+
+	// Paths are the changed URL paths relative to the served root
+	Paths []string `json:"paths"`
+}
+
+// Reloader watches directories and broadcasts changes to
+// all connected browsers
+type Reloader struct {
+	watcher *fsnotify.Watcher
+	roots   []root
+
+	mutex   sync.Mutex
+	clients map[chan Event]struct{}
+	pending map[string]struct{}
+	timer   *time.Timer
+}
+
+// root is a watched directory served under a URL path prefix
+type root struct {
+	dir    string
+	prefix string
+}
+
+// New creates a Reloader that recursively watches dir, which is
+// served at the root URL path. If dir is empty, nothing is watched
+// until Watch is called.
+func New(dir string) (lr *Reloader, err error) {
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+
+	lr = &Reloader{
+		watcher: watcher,
+		clients: make(map[chan Event]struct{}),
+		pending: make(map[string]struct{}),
+	}
+	if dir != "" {
+		if err = lr.Watch(dir, "/"); err != nil {
+			watcher.Close()
+			lr = nil
+			return
+		}
+	}
+
+	go lr.run()
+	return
+}
+
+// Watch recursively watches another directory, which
+// is served under the given URL path prefix
+func (lr *Reloader) Watch(dir, prefix string) (err error) {
+	if dir, err = filepath.Abs(dir); err != nil {
+		return
+	}
+	if err = lr.watchTree(dir); err != nil {
+		return
+	}
+	lr.mutex.Lock()
+	lr.roots = append(lr.roots, root{dir: dir, prefix: path.Clean("/" + prefix)})
+	lr.mutex.Unlock()
+	return
+}
+
+// urlPath maps a native path to its URL path
+func (lr *Reloader) urlPath(name string) (urlPath string, ok bool) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	matched := -1
+	for _, r := range lr.roots {
+		rel, err := filepath.Rel(r.dir, name)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if len(r.dir) > matched {
+			matched = len(r.dir)
+			urlPath = path.Join(r.prefix, filepath.ToSlash(rel))
+			ok = true
+		}
+	}
+	return
+}
+
+// Close stops watching the directory
+func (lr *Reloader) Close() error {
+	return lr.watcher.Close()
+}
+
+// watchTree adds watches to dir and all its sub-directories
+func (lr *Reloader) watchTree(dir string) error {
+	return filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// skip unreadable entries instead of failing the whole walk
+			if fi != nil && fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.IsDir() {
+			return nil
+		}
+		if name != dir && skipDirs[fi.Name()] {
+			return filepath.SkipDir
+		}
+		return lr.watcher.Add(name)
+	})
+}
+
+func (lr *Reloader) run() {
+	for {
+		select {
+		case ev, ok := <-lr.watcher.Events:
+			if !ok {
+				return
+			}
+			if ev.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() && !skipDirs[fi.Name()] {
+					if err := lr.watchTree(ev.Name); err != nil {
+						log.Printf("livereload: failed to watch %#v: %s", ev.Name, err)
+					}
+				}
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if urlPath, ok := lr.urlPath(ev.Name); ok {
+				lr.Notify(urlPath)
+			}
+		case err, ok := <-lr.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("livereload: watcher error: %s", err)
+		}
+	}
+}
+
+// Notify queues changed URL paths to be broadcasted to browsers
+func (lr *Reloader) Notify(paths ...string) {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+	for _, p := range paths {
+		lr.pending[p] = struct{}{}
+	}
+	if lr.timer == nil {
+		lr.timer = time.AfterFunc(debounce, lr.flush)
+	}
+}
+
+// flush broadcasts all pending changes
+func (lr *Reloader) flush() {
+	lr.mutex.Lock()
+	defer lr.mutex.Unlock()
+
+	ev := Event{Type: "css"}
+	for p := range lr.pending {
+		ev.Paths = append(ev.Paths, p)
+		if strings.ToLower(path.Ext(p)) != ".css" {
+			ev.Type = "reload"
+		}
+	}
+	lr.pending = make(map[string]struct{})
+	lr.timer = nil
+
+	for client := range lr.clients {
+		select {
+		case client <- ev:
+		default:
+			// client is slow; it already has a pending event
+		}
+	}
+}
+
+func (lr *Reloader) subscribe() chan Event {
+	client := make(chan Event, 1)
+	lr.mutex.Lock()
+	lr.clients[client] = struct{}{}
+	lr.mutex.Unlock()
+	return client
+}
+
+func (lr *Reloader) unsubscribe(client chan Event) {
+	lr.mutex.Lock()
+	delete(lr.clients, client)
+	lr.mutex.Unlock()
+}
+
+// ServeEvents streams change events to the browser
+// as server-sent events
+func (lr *Reloader) ServeEvents(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := lr.subscribe()
+	defer lr.unsubscribe(client)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, "retry: 1000\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case ev := <-client:
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "event: change\ndata: %s\n\n", b)
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package livereload_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/livereload"
+)
+
+func newReloader(t *testing.T) (lr *livereload.Reloader, dir string) {
+	dir = t.TempDir()
+	lr, err := livereload.New(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	t.Cleanup(func() { lr.Close() })
+	return
+}
+
+func TestMiddlewareInject(t *testing.T) {
+
+	lr, _ := newReloader(t)
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Length", "38")
+			w.Write([]byte("<html><body>Hello</body></html>"))
+		case "/style.css":
+			w.Header().Set("Content-Type", "text/css")
+			w.Write([]byte("body { color: red }"))
+		}
+	})
+	h := lr.Middleware("/_goserve/livereload")(page)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/index.html", nil))
+	if want, have := `<html><body>Hello<script src="/_goserve/livereload/client.js"></script></body></html>`, w.Body.String(); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	if have := w.Header().Get("Content-Length"); have != "" {
+		t.Errorf("expected Content-Length to be removed, got %#v", have)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/style.css", nil))
+	if want, have := "body { color: red }", w.Body.String(); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/_goserve/livereload/client.js", nil))
+	if !strings.Contains(w.Body.String(), `new EventSource("/_goserve/livereload/events")`) {
+		t.Errorf("unexpected client script: %s", w.Body.String())
+	}
+}
+
+func TestMiddlewareFlush(t *testing.T) {
+
+	lr, _ := newReloader(t)
+	w := httptest.NewRecorder()
+	var flushed []string
+	page := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		for _, chunk := range []string{"<html><body>", "<p>streamed</p></bo", "dy></html>"} {
+			rw.Write([]byte(chunk))
+			rw.(http.Flusher).Flush()
+			flushed = append(flushed, w.Body.String())
+		}
+	})
+	lr.Middleware("/_goserve/livereload")(page).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	// the end of the body is kept to inject the script
+	if want, have := "<html>|<html><body><p>streamed</|<html><body><p>streamed</p>", strings.Join(flushed, "|"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	if !w.Flushed {
+		t.Errorf("expected the response to be flushed")
+	}
+	if want, have := `<html><body><p>streamed</p><script src="/_goserve/livereload/client.js"></script></body></html>`, w.Body.String(); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+}
+
+func TestMiddlewareEvents(t *testing.T) {
+
+	lr, dir := newReloader(t)
+	srv := httptest.NewServer(lr.Middleware("/_goserve/livereload")(http.NotFoundHandler()))
+	defer srv.Close()
+
+	// cancel the stream before closing the server
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequest("GET", srv.URL+"/_goserve/livereload/events", nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if want, have := "text/event-stream", resp.Header.Get("Content-Type"); want != have {
+		t.Fatalf("expected content type %#v, got %#v", want, have)
+	}
+
+	events := make(chan livereload.Event)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+				var ev livereload.Event
+				json.Unmarshal([]byte(line[6:]), &ev)
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	receive := func() livereload.Event {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for event")
+		}
+		return livereload.Event{}
+	}
+
+	// wait for the subscription before making changes
+	time.Sleep(100 * time.Millisecond)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	ev := receive()
+	if want, have := "css", ev.Type; want != have {
+		t.Errorf("expected event type %#v, got %#v", want, have)
+	}
+	if len(ev.Paths) != 1 || ev.Paths[0] != "/style.css" {
+		t.Errorf("unexpected paths: %#v", ev.Paths)
+	}
+
+	// events of the previous write may still be on their way
+	lr.Notify("/index.html", "/style.css")
+	for ev = receive(); ev.Type == "css"; ev = receive() {
+	}
+	if want, have := "reload", ev.Type; want != have {
+		t.Errorf("expected event type %#v, got %#v", want, have)
+	}
+}
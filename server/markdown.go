@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/markdown"
+	"github.com/go-serve/goserve/server/midway"
+)
+
+var tplMarkdown *template.Template
+
+func init() {
+
+	fs := assets.FileSystem()
+	fh, err := fs.Open("/html/markdown.html")
+	if err != nil {
+		log.Print("Failed to load template")
+		panic(err)
+	}
+
+	b, err := ioutil.ReadAll(fh)
+	if err != nil {
+		log.Print("Failed to read template file")
+		panic(err)
+	}
+
+	tplMarkdown, err = template.New("markdown.html").Parse(string(b))
+	if err != nil {
+		log.Print("Failed to parse markdown.html into template")
+		panic(err)
+	}
+}
+
+// ServeMarkdown renders Markdown files as HTML pages for
+// "?mode=markdown", and by default for ".md" files requested
+// by browsers. Use "?mode=raw" for the source.
+func ServeMarkdown(root http.FileSystem) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if r.URL.Query().Get("mode") == "" && markdown.IsMarkdown(r.URL.Path) {
+				w.Header().Add("Vary", "Accept")
+			}
+			if !wantsMarkdown(r) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			file, err := root.Open(r.URL.Path)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			defer file.Close()
+			stat, err := file.Stat()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			if !stat.Mode().IsRegular() || stat.Size() > markdown.MaxSize {
+				// directories and large files are served as they are
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			content, err := renderMarkdown(file, path.Dir(path.Clean("/"+r.URL.Path)))
+			if err != nil {
+				log.Printf("Error rendering %#v: %s", r.URL.Path, err)
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if r.Method == http.MethodHead {
+				return
+			}
+			err = tplMarkdown.Execute(w, map[string]interface{}{
+				"Name":        stat.Name(),
+				"Content":     template.HTML(content),
+				"Stylesheets": stylesheets,
+			})
+			if err != nil {
+				log.Printf("error executing template markdown.html: %s", err.Error())
+			}
+		})
+	}
+}
+
+// wantsMarkdown reports if the request is for a rendered Markdown
+// page, explicitly or by a browser requesting a Markdown file
+func wantsMarkdown(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	switch r.URL.Query().Get("mode") {
+	case "markdown":
+		return true
+	case "":
+		return markdown.IsMarkdown(r.URL.Path) && strings.Contains(r.Header.Get("Accept"), "text/html")
+	}
+	return false
+}
+
+// renderMarkdown renders the Markdown file with relative
+// links resolved against the URL path base
+func renderMarkdown(file http.File, base string) (string, error) {
+	source, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	var content bytes.Buffer
+	if err := markdown.Render(&content, source, base); err != nil {
+		return "", err
+	}
+	return content.String(), nil
+}
+
+// readme returns the rendered README of the directory
+// in the files listed, if any
+func (fs *fileServer) readme(dir string, files []os.FileInfo) string {
+	for _, name := range markdown.Readmes {
+		for _, file := range files {
+			if file.Name() != name || !file.Mode().IsRegular() || file.Size() > markdown.MaxSize {
+				continue
+			}
+			f, err := fs.root.Open(path.Join(dir, name))
+			if err != nil {
+				return ""
+			}
+			defer f.Close()
+			content, err := renderMarkdown(f, dir)
+			if err != nil {
+				log.Printf("Error rendering %#v: %s", path.Join(dir, name), err)
+			}
+			return content
+		}
+	}
+	return ""
+}
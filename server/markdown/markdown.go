@@ -0,0 +1,106 @@
+// Package markdown renders Markdown files as GitHub flavoured HTML
+package markdown
+
+import (
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// MaxSize is the size in bytes of the largest file to render
+const MaxSize = 4 << 20
+
+// Readmes are the names of README files shown below directory
+// listings, in the order of preference
+var Readmes = []string{"README.md", "readme.md", "Readme.md", "README.markdown"}
+
+// IsMarkdown reports if the file name has the extension of Markdown
+func IsMarkdown(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown", ".mdown", ".mkd":
+		return true
+	}
+	return false
+}
+
+// baseKey is the parser context key of the base URL path
+var baseKey = parser.NewContextKey()
+
+var md = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle("github"),
+			highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+		),
+	),
+	goldmark.WithParserOptions(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(transformer{}, 100)),
+	),
+)
+
+// Render converts the Markdown source to HTML. Raw HTML in the source
+// is left out. Relative links and images are resolved against the URL
+// path base, the directory of the Markdown file.
+func Render(w io.Writer, source []byte, base string) error {
+	ctx := parser.NewContext()
+	ctx.Set(baseKey, base)
+	return md.Convert(source, w, parser.WithContext(ctx))
+}
+
+// transformer rewrites relative links and adds anchors to headings
+type transformer struct{}
+
+// Transform implements parser.ASTTransformer
+func (transformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	base, _ := pc.Get(baseKey).(string)
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = resolve(base, n.Destination)
+		case *ast.Image:
+			n.Destination = resolve(base, n.Destination)
+		case *ast.Heading:
+			if id, ok := n.AttributeString("id"); ok {
+				anchor := ast.NewLink()
+				anchor.Destination = append([]byte("#"), id.([]byte)...)
+				anchor.SetAttributeString("class", []byte("anchor"))
+				anchor.AppendChild(anchor, ast.NewString([]byte("#")))
+				n.InsertBefore(n, n.FirstChild(), anchor)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// resolve resolves the relative URL against the URL path base
+func resolve(base string, dest []byte) []byte {
+	s := string(dest)
+	if s == "" || strings.HasPrefix(s, "/") || strings.HasPrefix(s, "#") {
+		return dest
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return dest
+	}
+	resolved := path.Join("/", base, u.Path)
+	if strings.HasSuffix(u.Path, "/") && resolved != "/" {
+		resolved += "/"
+	}
+	u.Path = resolved
+	return []byte(u.String())
+}
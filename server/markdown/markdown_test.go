@@ -0,0 +1,59 @@
+package markdown_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/markdown"
+)
+
+func render(t *testing.T, source, base string) string {
+	var buf bytes.Buffer
+	if err := markdown.Render(&buf, []byte(source), base); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return buf.String()
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"# Hello World", `<h1 id="hello-world"><a href="#hello-world" class="anchor">#</a>Hello World</h1>`},
+		{"| a | b |\n|---|---|\n| 1 | 2 |", `<td>1</td>`},
+		{"- [x] done", `<input checked="" disabled="" type="checkbox"> done`},
+		{"~~gone~~", `<del>gone</del>`},
+		{"```go\nfunc main() {}\n```", `<span style="color:#000;font-weight:bold">func</span>`},
+		{"[doc](docs/a.md)", `<a href="/repo/docs/a.md">doc</a>`},
+		{"[up](../b.md#usage)", `<a href="/b.md#usage">up</a>`},
+		{"[dir](docs/)", `<a href="/repo/docs/">dir</a>`},
+		{"![logo](img/logo%20big.png)", `<img src="/repo/img/logo%20big.png" alt="logo">`},
+		{"[abs](/x) [web](https://example.com/y) [top](#top)", `<a href="/x">abs</a> <a href="https://example.com/y">web</a> <a href="#top">top</a>`},
+		{"<script>alert(1)</script>", `<!-- raw HTML omitted -->`},
+		{"[x](javascript:alert(1))", `<a href="">x</a>`},
+	}
+	for _, test := range tests {
+		if have := render(t, test.source, "/repo"); !strings.Contains(have, test.want) {
+			t.Errorf("%#v:\nexpected: %s\ngot:      %s", test.source, test.want, have)
+		}
+	}
+}
+
+func TestIsMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"README.md", true},
+		{"notes.MARKDOWN", true},
+		{"main.go", false},
+		{"md", false},
+	}
+	for _, test := range tests {
+		if want, have := test.ok, markdown.IsMarkdown(test.name); want != have {
+			t.Errorf("%s: expected %v, got %v", test.name, want, have)
+		}
+	}
+}
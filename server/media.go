@@ -14,8 +14,9 @@ import (
 	"regexp"
 	"strings"
 
-	"github.com/go-midway/midway"
 	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/video"
 )
 
 var tplVideo *template.Template
@@ -56,6 +57,16 @@ func init() {
 	srtDateReg = regexp.MustCompile("(\\d{2}:\\d{2}:\\d{2}),(\\d{3}) --> (\\d{2}:\\d{2}:\\d{2}),(\\d{3})")
 }
 
+// isVideo reports if the file name has the extension
+// of a video file opened in the video player
+func isVideo(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".mp4", ".webm", ".mkv", ".ogv", ".mov":
+		return true
+	}
+	return false
+}
+
 // ServeVideo displays HTML5 compatible video files with proper HTML player page
 func ServeVideo(root http.FileSystem) midway.Middleware {
 	return func(inner http.Handler) http.Handler {
@@ -68,19 +79,26 @@ func ServeVideo(root http.FileSystem) midway.Middleware {
 
 				file, err := root.Open(r.URL.Path)
 				if err != nil {
-					// TODO: handle not found / other error
+					serveError(w, r, root, errorStatus(err))
 					return
 				}
+				defer file.Close()
 
 				if stat, err = file.Stat(); err != nil {
-					// TODO: handle not found / other error
+					serveError(w, r, root, errorStatus(err))
 					return
 				} else if !stat.Mode().IsRegular() {
-					// TODO: not a file
+					serveError(w, r, root, http.StatusNotFound)
 					return
 				}
 
-				// TODO: detect if the file is an mp4 / ogg / ogv / vp8 / vp9
+				// type the source by the container and codecs of the
+				// file, or offer to download files of unknown formats
+				metaType := ""
+				if format, err := video.Sniff(file); err == nil {
+					metaType = format.Type()
+				}
+
 				// find if there is srt / webvtt file in the same folder
 				subtitles := make([]map[string]string, 0, 1)
 				fileBasename := strings.TrimSuffix(r.URL.Path, filepath.Ext(r.URL.Path))
@@ -111,7 +129,7 @@ func ServeVideo(root http.FileSystem) midway.Middleware {
 				err = tplVideo.Execute(w, map[string]interface{}{
 					"Name":        r.URL.Path,
 					"Path":        r.URL.Path,
-					"MetaType":    "video/mp4",
+					"MetaType":    metaType,
 					"Stylesheets": stylesheets,
 					"Scripts":     scripts,
 					"Subtitles":   subtitles,
@@ -148,16 +166,17 @@ func ServeSrt(root http.FileSystem) midway.Middleware {
 					defer srt.Close()
 
 					// mask the SRT with masking reader
-					r, err := NewSrtWebvttReader(srt)
+					vtt, err := NewSrtWebvttReader(srt)
 					if err != nil {
-						// TODO: handler error
+						log.Printf("Error reading srt %#v: %s", r.URL.Path, err)
+						serveError(w, r, root, http.StatusInternalServerError)
 						return
 					}
 
 					// use io.Copy to pipe out the masked reader
 					w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
 					w.WriteHeader(http.StatusOK)
-					io.Copy(w, r)
+					io.Copy(w, vtt)
 					return
 				}
 			}
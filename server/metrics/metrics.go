@@ -0,0 +1,244 @@
+// Package metrics collects Prometheus metrics of goserve
+package metrics
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// HandlerFunc names the handler serving a request,
+// e.g. "listing" or "file"
+type HandlerFunc func(r *http.Request) string
+
+// Metrics of a goserve instance
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	bytes       *prometheus.CounterVec
+	inFlight    prometheus.Gauge
+	connections prometheus.Gauge
+	queries     prometheus.Counter
+	queryErrors prometheus.Counter
+}
+
+// New creates Metrics in their own registry, along
+// with the Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "goserve_http_requests_total",
+			Help: "Number of HTTP requests served, by handler, method and status code.",
+		}, []string{"handler", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "goserve_http_request_duration_seconds",
+			Help:    "Latency of HTTP requests, by handler.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"handler"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "goserve_http_response_bytes_total",
+			Help: "Bytes of response bodies sent, by handler.",
+		}, []string{"handler"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goserve_http_requests_in_flight",
+			Help: "Number of HTTP requests being served.",
+		}),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "goserve_http_active_connections",
+			Help: "Number of open client connections.",
+		}),
+		queries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "goserve_graphql_queries_total",
+			Help: "Number of GraphQL queries executed.",
+		}),
+		queryErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "goserve_graphql_errors_total",
+			Help: "Number of GraphQL queries that returned errors.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.bytes,
+		m.inFlight,
+		m.connections,
+		m.queries,
+		m.queryErrors,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Middleware generates a middleware that measures every
+// request, labelled by the handler named by handlerOf
+func (m *Metrics) Middleware(handlerOf HandlerFunc) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			handler := handlerOf(r)
+			start := time.Now()
+			rec := &recorder{ResponseWriter: w}
+
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+			inner.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			m.requests.WithLabelValues(handler, r.Method, strconv.Itoa(rec.status)).Inc()
+			m.duration.WithLabelValues(handler).Observe(time.Since(start).Seconds())
+			m.bytes.WithLabelValues(handler).Add(float64(rec.bytes))
+		})
+	}
+}
+
+// ConnState counts the open connections. Set it as the
+// ConnState hook of http.Server.
+func (m *Metrics) ConnState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		m.connections.Inc()
+	case http.StateHijacked, http.StateClosed:
+		m.connections.Dec()
+	}
+}
+
+// GraphQL counts an executed GraphQL query
+// and its error, if any
+func (m *Metrics) GraphQL(err error) {
+	m.queries.Inc()
+	if err != nil {
+		m.queryErrors.Inc()
+	}
+}
+
+// Access restricts who may read the metrics. Requests are allowed
+// with the bearer token, or from the allowed networks. If neither
+// is set, only requests from loopback addresses are allowed.
+type Access struct {
+	Token string
+	Allow []*net.IPNet
+}
+
+// ParseNetworks parses IP addresses and CIDR networks
+func ParseNetworks(values ...string) (networks []*net.IPNet, err error) {
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		var network *net.IPNet
+		if _, network, err = net.ParseCIDR(value); err != nil {
+			return
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+// Allowed reports if the request may read the metrics
+func (access Access) Allowed(r *http.Request) bool {
+
+	if access.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") &&
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(access.Token)) == 1 {
+			return true
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if access.Token == "" && len(access.Allow) == 0 {
+		return ip.IsLoopback()
+	}
+	for _, network := range access.Allow {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler serves the metrics in Prometheus text format
+// to requests allowed by access
+func (m *Metrics) Handler(access Access) http.Handler {
+	metrics := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !access.Allowed(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(w, r)
+	})
+}
+
+// recorder records the status and size of a response
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader implements http.ResponseWriter
+func (w *recorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (w *recorder) Write(b []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return
+}
+
+// Flush implements http.Flusher, if the underlying
+// writer supports it
+func (w *recorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker, if the underlying
+// writer supports it
+func (w *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("metrics: response writer does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
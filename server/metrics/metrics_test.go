@@ -0,0 +1,57 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-serve/goserve/server/metrics"
+)
+
+func TestAccess(t *testing.T) {
+
+	allow, err := metrics.ParseNetworks("10.0.0.0/8", "192.0.2.7")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := metrics.ParseNetworks("not-an-ip"); err == nil {
+		t.Errorf("expected error")
+	}
+
+	tests := []struct {
+		access metrics.Access
+		remote string
+		token  string
+		want   bool
+	}{
+		{metrics.Access{}, "127.0.0.1:1234", "", true},
+		{metrics.Access{}, "[::1]:1234", "", true},
+		{metrics.Access{}, "192.0.2.7:1234", "", false},
+		{metrics.Access{Allow: allow}, "10.1.2.3:1234", "", true},
+		{metrics.Access{Allow: allow}, "192.0.2.7:1234", "", true},
+		{metrics.Access{Allow: allow}, "127.0.0.1:1234", "", false},
+		{metrics.Access{Token: "s3cret"}, "192.0.2.8:1234", "s3cret", true},
+		{metrics.Access{Token: "s3cret"}, "192.0.2.8:1234", "wrong", false},
+		{metrics.Access{Token: "s3cret"}, "127.0.0.1:1234", "", false},
+	}
+	for i, test := range tests {
+		r := httptest.NewRequest("GET", "http://example.com/_goserve/metrics", nil)
+		r.RemoteAddr = test.remote
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		if want, have := test.want, test.access.Allowed(r); want != have {
+			t.Errorf("test %d: expected %v, got %v", i, want, have)
+		}
+	}
+}
+
+func TestHandlerForbidden(t *testing.T) {
+	m := metrics.New()
+	r := httptest.NewRequest("GET", "http://example.com/_goserve/metrics", nil)
+	w := httptest.NewRecorder()
+	m.Handler(metrics.Access{Token: "s3cret"}).ServeHTTP(w, r)
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
@@ -0,0 +1,20 @@
+// Package midway chains HTTP middlewares, as github.com/go-midway/midway
+// does, which has no release to pin in go.mod
+package midway
+
+import "net/http"
+
+// Middleware wraps an inner handler with the handling of
+// some requests, deferring the others to it
+type Middleware func(inner http.Handler) http.Handler
+
+// Chain returns a middleware of the middlewares, the first
+// one being the outermost
+func Chain(middlewares ...Middleware) Middleware {
+	return func(inner http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			inner = middlewares[i](inner)
+		}
+		return inner
+	}
+}
@@ -0,0 +1,262 @@
+// Package netlify applies the _headers and _redirects files of
+// a site, as static hosts like Netlify do
+package netlify
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// names of the site files at the root
+const (
+	HeadersFile   = "/_headers"
+	RedirectsFile = "/_redirects"
+)
+
+// ReloadInterval is the minimum interval between
+// checks of the site files for changes
+var ReloadInterval = time.Second
+
+// Site holds the rules of the site files at the root of a file
+// system, reloaded when the files change
+type Site struct {
+	root http.FileSystem
+
+	mutex          sync.Mutex
+	checked        time.Time
+	headersStamp   stamp
+	redirectsStamp stamp
+	headers        []HeaderRule
+	redirects      []Redirect
+}
+
+// stamp identifies a version of a file.
+// The zero value is a missing file.
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// New loads the site files at the root of the file system
+func New(root http.FileSystem) *Site {
+	s := &Site{root: root}
+	s.reload()
+	return s
+}
+
+// Rules returns the current rules, reloaded if the files changed
+func (s *Site) Rules() (headers []HeaderRule, redirects []Redirect) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if time.Since(s.checked) >= ReloadInterval {
+		s.reload()
+	}
+	return s.headers, s.redirects
+}
+
+func (s *Site) reload() {
+	s.checked = time.Now()
+	s.headersStamp = s.load(HeadersFile, s.headersStamp, func(r io.Reader) (err error) {
+		s.headers, err = ParseHeaders(r)
+		return
+	})
+	s.redirectsStamp = s.load(RedirectsFile, s.redirectsStamp, func(r io.Reader) (err error) {
+		s.redirects, err = ParseRedirects(r)
+		return
+	})
+}
+
+// load parses the file with parse, if changed since prev
+func (s *Site) load(name string, prev stamp, parse func(io.Reader) error) (current stamp) {
+
+	f, err := s.root.Open(name)
+	if err == nil {
+		defer f.Close()
+		if stat, err := f.Stat(); err == nil && stat.Mode().IsRegular() {
+			current = stamp{stat.ModTime(), stat.Size()}
+		}
+	}
+	if current == prev {
+		return
+	}
+
+	if current == (stamp{}) {
+		parse(strings.NewReader(""))
+		return
+	}
+	if err := parse(f); err != nil {
+		log.Printf("Error in %s: %s", strings.TrimPrefix(name, "/"), err)
+	}
+	return
+}
+
+// exists reports if a file or directory exists at the path
+func (s *Site) exists(p string) bool {
+	f, err := s.root.Open(p)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// Middleware generates a middleware that sets the headers and
+// applies the redirects of the site files. The site files
+// themselves are not served. Requests rewritten to another path
+// pass through the rewritten middlewares, e.g. to authorize
+// the rewritten path, before the inner handler.
+func (s *Site) Middleware(rewritten ...midway.Middleware) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		rewrite := midway.Chain(rewritten...)(inner)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			p := r.URL.Path
+			if strings.HasPrefix(p, "/_goserve/") {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			if p == HeadersFile || p == RedirectsFile {
+				http.NotFound(w, r)
+				return
+			}
+
+			headers, redirects := s.Rules()
+			for _, rule := range headers {
+				if _, ok := rule.Pattern.Match(p); ok {
+					for key, values := range rule.Header {
+						for _, value := range values {
+							w.Header().Add(key, value)
+						}
+					}
+				}
+			}
+
+			for _, rule := range redirects {
+				params, ok := rule.match(r)
+				if !ok {
+					continue
+				}
+
+				// existing files shadow rules that are not forced
+				if !rule.Force && s.exists(p) {
+					break
+				}
+				if s.apply(w, r, rule, expand(rule.To, params), rewrite) {
+					return
+				}
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// match matches the request path and query with the rule
+func (rule Redirect) match(r *http.Request) (params map[string]string, ok bool) {
+	if params, ok = rule.From.Match(r.URL.Path); !ok {
+		return
+	}
+	query := r.URL.Query()
+	for key, value := range rule.Query {
+		actual := query.Get(key)
+		switch {
+		case actual == "":
+			return nil, false
+		case strings.HasPrefix(value, ":"):
+			params[value[1:]] = actual
+		case value != actual:
+			return nil, false
+		}
+	}
+	return
+}
+
+// apply serves the request with the rule, passing rewritten
+// requests to rewrite. It returns false if the rule cannot be
+// applied.
+func (s *Site) apply(w http.ResponseWriter, r *http.Request, rule Redirect, to string, rewrite http.Handler) bool {
+
+	// redirects
+	if rule.Status >= 300 && rule.Status < 400 {
+		if r.URL.RawQuery != "" && len(rule.Query) == 0 && !strings.Contains(to, "?") {
+			to += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, to, rule.Status)
+		return true
+	}
+
+	target, err := url.Parse(to)
+	if err != nil {
+		return false
+	}
+
+	// rewrites
+	if rule.Status == http.StatusOK {
+		if isExternal(to) {
+			(&httputil.ReverseProxy{
+				Director: func(r *http.Request) {
+					r.URL = target
+					r.Host = target.Host
+					stripCredentials(r)
+				},
+			}).ServeHTTP(w, r)
+			return true
+		}
+		rewritten := r.Clone(r.Context())
+		rewritten.URL.Path, rewritten.URL.RawPath = target.Path, ""
+		if target.RawQuery != "" {
+			rewritten.URL.RawQuery = target.RawQuery
+		}
+		rewrite.ServeHTTP(w, rewritten)
+		return true
+	}
+
+	// custom pages with status, e.g. 404
+	if isExternal(to) {
+		return false
+	}
+	f, err := s.root.Open(target.Path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if stat, err := f.Stat(); err != nil || !stat.Mode().IsRegular() {
+		return false
+	}
+	contentType := mime.TypeByExtension(path.Ext(target.Path))
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(rule.Status)
+	if r.Method != "HEAD" {
+		io.Copy(w, f)
+	}
+	return true
+}
+
+// CookiePrefix starts the names of the cookies of goserve
+const CookiePrefix = "goserve_"
+
+// stripCredentials removes the credentials of goserve from a request
+// proxied to another host: the Authorization header and the cookies
+// named with CookiePrefix
+func stripCredentials(r *http.Request) {
+	r.Header.Del("Authorization")
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if !strings.HasPrefix(c.Name, CookiePrefix) {
+			r.AddCookie(c)
+		}
+	}
+}
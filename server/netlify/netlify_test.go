@@ -0,0 +1,220 @@
+package netlify_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/netlify"
+)
+
+func TestPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		ok      bool
+		params  string
+	}{
+		{"/news", "/news/", true, ""},
+		{"/news", "/news/2020", false, ""},
+		{"/news/*", "/news/2020/01/hello", true, "splat=2020/01/hello"},
+		{"/news/*", "/newsletter", false, ""},
+		{"/*", "/", true, "splat="},
+		{"/news/:year/:id", "/news/2020/hello", true, "id=hello year=2020"},
+		{"/news/:year/:id", "/news/2020", false, ""},
+	}
+	for _, test := range tests {
+		params, ok := netlify.ParsePattern(test.pattern).Match(test.path)
+		if want, have := test.ok, ok; want != have {
+			t.Errorf("%s %s: expected %v, got %v", test.pattern, test.path, want, have)
+			continue
+		}
+		var pairs []string
+		for _, name := range []string{"id", "splat", "year"} {
+			if value, ok := params[name]; ok {
+				pairs = append(pairs, name+"="+value)
+			}
+		}
+		if want, have := test.params, strings.Join(pairs, " "); want != have {
+			t.Errorf("%s %s: expected %#v, got %#v", test.pattern, test.path, want, have)
+		}
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	rules, err := netlify.ParseRedirects(strings.NewReader(`
+# comment
+/old          /new
+/store id=:id /products/:id 302!
+/bad
+/app/*        /app/index.html   200
+`))
+	if err == nil || !strings.HasPrefix(err.Error(), "_redirects:5:") {
+		t.Errorf("expected error on line 5, got %#v", err)
+	}
+	if want, have := 3, len(rules); want != have {
+		t.Fatalf("expected %d rules, got %d", want, have)
+	}
+	if want, have := http.StatusMovedPermanently, rules[0].Status; want != have {
+		t.Errorf("expected %d, got %d", want, have)
+	}
+	if want, have := ":id", rules[1].Query["id"]; want != have || !rules[1].Force || rules[1].Status != 302 {
+		t.Errorf("unexpected rule %#v", rules[1])
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(name), 0755)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"_headers": "/*\n  X-Frame-Options: DENY\n/assets/*\n  Cache-Control: max-age=3600\n",
+		"_redirects": `
+/old/*            /new/:splat
+/blog/:year/:slug /posts/:slug.html  200
+/store id=:id     /products/:id      302
+/docs/:s/*        /archive/:splat/:s 302
+/users/:id/:identity /u/:identity/:id 302
+/shadowed         /index.html        302
+/forced.html      /index.html        302!
+/*                /404.html          404
+`,
+		"index.html":       "index",
+		"404.html":         "not found",
+		"shadowed":         "shadowed",
+		"forced.html":      "forced",
+		"posts/hello.html": "hello post",
+		"assets/app.css":   "css",
+	})
+
+	root := http.Dir(dir)
+	h := netlify.New(root).Middleware()(http.FileServer(root))
+
+	tests := []struct {
+		path     string
+		code     int
+		location string
+		body     string
+	}{
+		{"/old/a/b?x=1", http.StatusMovedPermanently, "/new/a/b?x=1", ""},
+		{"/blog/2020/hello", http.StatusOK, "", "hello post"},
+		{"/store?id=42", http.StatusFound, "/products/42", ""},
+		{"/docs/v1/a/b", http.StatusFound, "/archive/a/b/v1", ""},
+		{"/users/7/alice", http.StatusFound, "/u/alice/7", ""},
+		{"/shadowed", http.StatusOK, "", "shadowed"},
+		{"/forced.html", http.StatusFound, "/index.html", ""},
+		{"/assets/app.css", http.StatusOK, "", "css"},
+		{"/missing", http.StatusNotFound, "", "not found"},
+		{"/_redirects", http.StatusNotFound, "", ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if want, have := test.location, w.Header().Get("Location"); want != have {
+			t.Errorf("%s: expected location %#v, got %#v", test.path, want, have)
+		}
+		if test.body != "" && test.body != w.Body.String() {
+			t.Errorf("%s: expected body %#v, got %#v", test.path, test.body, w.Body.String())
+		}
+		if want, have := "DENY", w.Header().Get("X-Frame-Options"); test.path != "/_redirects" && want != have {
+			t.Errorf("%s: expected header %#v, got %#v", test.path, want, have)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/assets/app.css", nil))
+	if want, have := "max-age=3600", w.Header().Get("Cache-Control"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestMiddlewareRewrite(t *testing.T) {
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cookies []string
+		for _, c := range r.Cookies() {
+			cookies = append(cookies, c.Name)
+		}
+		w.Write([]byte(r.URL.Path + "|" + r.Header.Get("Authorization") + "|" + strings.Join(cookies, ",")))
+	}))
+	defer backend.Close()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"_redirects": "/ext/*  " + backend.URL + "/api/:splat  200\n/docs/*  /:splat  200\n",
+		"s.txt":      "secret",
+	})
+	root := http.Dir(dir)
+
+	// rewritten requests pass through the rewritten middlewares
+	var rewritten []string
+	h := netlify.New(root).Middleware(func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rewritten = append(rewritten, r.URL.Path)
+			inner.ServeHTTP(w, r)
+		})
+	})(http.FileServer(root))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/docs/s.txt", nil))
+	if want, have := "secret", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "/s.txt", strings.Join(rewritten, ","); want != have {
+		t.Errorf("expected rewritten %#v, got %#v", want, have)
+	}
+
+	// the credentials of goserve are not sent to other hosts
+	r := httptest.NewRequest("GET", "http://example.com/ext/users", nil)
+	r.SetBasicAuth("alice", "secret")
+	r.AddCookie(&http.Cookie{Name: netlify.CookiePrefix + "share", Value: "token"})
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if want, have := "/api/users||theme", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestReload(t *testing.T) {
+
+	interval := netlify.ReloadInterval
+	netlify.ReloadInterval = 0
+	defer func() { netlify.ReloadInterval = interval }()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"_redirects": "/a /b\n"})
+	site := netlify.New(http.Dir(dir))
+	if _, redirects := site.Rules(); len(redirects) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(redirects))
+	}
+
+	writeFiles(t, dir, map[string]string{"_redirects": "/a /b\n/c /d\n"})
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "_redirects"), future, future)
+	if _, redirects := site.Rules(); len(redirects) != 2 {
+		t.Errorf("expected 2 rules after change, got %d", len(redirects))
+	}
+
+	os.Remove(filepath.Join(dir, "_redirects"))
+	if _, redirects := site.Rules(); len(redirects) != 0 {
+		t.Errorf("expected no rules after removal, got %d", len(redirects))
+	}
+}
@@ -0,0 +1,147 @@
+package netlify
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HeaderRule sets response headers for matching paths
+type HeaderRule struct {
+	Pattern *Pattern
+	Header  http.Header
+}
+
+// Redirect is a rule of the _redirects file
+type Redirect struct {
+	From *Pattern
+
+	// Query are query parameters the request must have. A value
+	// ":name" binds the parameter as a placeholder.
+	Query map[string]string
+
+	To     string
+	Status int
+
+	// Force applies the rule even if a file exists at the path
+	Force bool
+}
+
+// ParseHeaders parses a _headers file. Each path pattern is
+// followed by indented "Name: value" lines. Invalid lines are
+// skipped, and the first one is reported as error.
+func ParseHeaders(r io.Reader) (rules []HeaderRule, err error) {
+
+	scanner := bufio.NewScanner(r)
+	var rule *HeaderRule
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// path pattern
+		if line[0] != ' ' && line[0] != '\t' {
+			rules = append(rules, HeaderRule{
+				Pattern: ParsePattern(trimmed),
+				Header:  make(http.Header),
+			})
+			rule = &rules[len(rules)-1]
+			continue
+		}
+
+		// header of the pattern
+		i := strings.Index(trimmed, ":")
+		if rule == nil || i <= 0 {
+			if err == nil {
+				err = fmt.Errorf("_headers:%d: expected path or \"Name: value\"", n)
+			}
+			continue
+		}
+		rule.Header.Add(strings.TrimSpace(trimmed[:i]), strings.TrimSpace(trimmed[i+1:]))
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		err = scanErr
+	}
+	return
+}
+
+var statusPattern = regexp.MustCompile(`^([0-9]{3})(!?)$`)
+
+// ParseRedirects parses a _redirects file. Each line is a rule in
+// the form of "from [query params] to [status][!] [conditions]".
+// Invalid lines are skipped, and the first one is reported as error.
+func ParseRedirects(r io.Reader) (rules []Redirect, err error) {
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		rule := Redirect{
+			From:   ParsePattern(fields[0]),
+			Query:  make(map[string]string),
+			Status: http.StatusMovedPermanently,
+		}
+
+		if lineErr := parseRedirect(&rule, fields[1:]); lineErr != nil {
+			if err == nil {
+				err = fmt.Errorf("_redirects:%d: %s", n, lineErr)
+			}
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		err = scanErr
+	}
+	return
+}
+
+// parseRedirect parses the fields after the source path
+func parseRedirect(rule *Redirect, fields []string) error {
+
+	// query params until target
+	i := 0
+	for ; i < len(fields) && !isTarget(fields[i]); i++ {
+		parts := strings.SplitN(fields[i], "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("unexpected %#v", fields[i])
+		}
+		rule.Query[parts[0]] = parts[1]
+	}
+	if i == len(fields) {
+		return fmt.Errorf("missing target")
+	}
+	rule.To = fields[i]
+
+	// status, conditions (e.g. "Country=") are not supported
+	if i+1 < len(fields) {
+		match := statusPattern.FindStringSubmatch(fields[i+1])
+		if match == nil {
+			return fmt.Errorf("invalid status %#v", fields[i+1])
+		}
+		rule.Status, _ = strconv.Atoi(match[1])
+		rule.Force = match[2] == "!"
+	}
+	return nil
+}
+
+// isTarget reports if the field is the target of a redirect
+func isTarget(field string) bool {
+	return strings.HasPrefix(field, "/") ||
+		strings.HasPrefix(field, "http://") ||
+		strings.HasPrefix(field, "https://")
+}
+
+// isExternal reports if the target is on another site
+func isExternal(target string) bool {
+	return strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://")
+}
@@ -0,0 +1,97 @@
+package netlify
+
+import (
+	"path"
+	"strings"
+)
+
+// Pattern is a URL path pattern of _headers and _redirects files.
+// A segment ":name" matches any single path segment. A trailing "*"
+// matches the rest of the path, available as the "splat" parameter.
+type Pattern struct {
+	segments []string
+	splat    bool
+}
+
+// ParsePattern parses a URL path pattern
+func ParsePattern(pattern string) *Pattern {
+	p := &Pattern{}
+	pattern = trimSlash(pattern)
+	if strings.HasSuffix(pattern, "*") {
+		p.splat = true
+		pattern = strings.TrimSuffix(pattern, "*")
+	}
+	p.segments = splitPath(pattern)
+	return p
+}
+
+// Match matches the URL path and returns the parameters
+func (p *Pattern) Match(urlPath string) (params map[string]string, ok bool) {
+
+	segments := splitPath(trimSlash(urlPath))
+	if len(segments) < len(p.segments) || (!p.splat && len(segments) != len(p.segments)) {
+		return
+	}
+
+	params = make(map[string]string)
+	for i, segment := range p.segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			params[segment[1:]] = segments[i]
+		case segment != segments[i]:
+			return nil, false
+		}
+	}
+	if p.splat {
+		params["splat"] = strings.Join(segments[len(p.segments):], "/")
+	}
+	return params, true
+}
+
+// expand replaces the placeholders in target with parameters. A
+// placeholder is the whole name after ":", so that ":s" never
+// replaces the start of ":splat".
+func expand(target string, params map[string]string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(target, ':')
+		if i < 0 {
+			b.WriteString(target)
+			return b.String()
+		}
+		b.WriteString(target[:i])
+		end := i + 1
+		for end < len(target) && isNameByte(target[end]) {
+			end++
+		}
+		if value, ok := params[target[i+1:end]]; ok && end > i+1 {
+			b.WriteString(value)
+		} else {
+			b.WriteString(target[i:end])
+		}
+		target = target[end:]
+	}
+}
+
+// isNameByte reports if the byte may be in the name of a placeholder
+func isNameByte(c byte) bool {
+	return c == '_' || c == '-' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// trimSlash cleans the path and removes the trailing slash,
+// which is ignored in matching
+func trimSlash(p string) string {
+	if strings.HasSuffix(p, "/*") {
+		base := path.Clean("/" + strings.TrimSuffix(p, "/*"))
+		return strings.TrimSuffix(base, "/") + "/*"
+	}
+	return path.Clean("/" + p)
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
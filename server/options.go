@@ -0,0 +1,211 @@
+package server
+
+import (
+	"log"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/server/accesslog"
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/compress"
+	"github.com/go-serve/goserve/server/livereload"
+	"github.com/go-serve/goserve/server/metrics"
+	"github.com/go-serve/goserve/server/proxy"
+	"github.com/go-serve/goserve/server/share"
+	"github.com/go-serve/goserve/server/thumb"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+// Option configures the file server returned by FileServer
+type Option func(*fileServer)
+
+// WithLiveReload serves change notifications from the given Reloader
+// under "/_goserve/livereload" and injects the reload script into
+// HTML pages
+func WithLiveReload(lr *livereload.Reloader) Option {
+	return func(fs *fileServer) {
+		fs.liveReload = lr
+	}
+}
+
+// WithHeaders sets response headers according to the rules
+func WithHeaders(rules ...HeaderRule) Option {
+	return func(fs *fileServer) {
+		fs.headers = append(fs.headers, rules...)
+	}
+}
+
+// WithHidden hides files and directories reported by the function
+// from listings, downloads and the API
+func WithHidden(hidden vfs.HideFunc) Option {
+	return func(fs *fileServer) {
+		fs.hidden = hidden
+	}
+}
+
+// WithHiddenPolicy sets how dotfiles are served. Dotfiles are
+// shown by default, and /.well-known is served with any policy.
+func WithHiddenPolicy(policy HiddenPolicy) Option {
+	return func(fs *fileServer) {
+		fs.hiddenPolicy = policy
+	}
+}
+
+// WithIgnoreFiles hides files and directories listed in the ignore
+// files of the names (e.g. ".goserveignore") found in their parent
+// directories. Ignore files use the syntax of .gitignore.
+func WithIgnoreFiles(names ...string) Option {
+	return func(fs *fileServer) {
+		fs.ignoreFiles = append(fs.ignoreFiles, names...)
+	}
+}
+
+// WithUpload accepts uploads with multipart forms, PUT requests
+// and the tus protocol. The root must implement vfs.Native.
+func WithUpload(opts upload.Options) Option {
+	return func(fs *fileServer) {
+		fs.upload = &opts
+	}
+}
+
+// WithDAV serves the root with WebDAV under the URL path prefix,
+// e.g. dav.DefaultPrefix. It is read-only, unless uploads are
+// enabled with WithUpload for the modified paths.
+func WithDAV(prefix string) Option {
+	return func(fs *fileServer) {
+		fs.davPrefix = strings.TrimRight(path.Clean("/"+prefix), "/")
+	}
+}
+
+// WithAPI enables or disables the REST and GraphQL API
+// under "/_goserve/api". Enabled by default.
+func WithAPI(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.noAPI = !enabled
+	}
+}
+
+// WithVideoPlayer enables or disables the HTML5 video player page
+// and SRT to WebVTT conversion. Enabled by default.
+func WithVideoPlayer(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.noVideoPlayer = !enabled
+	}
+}
+
+// WithArchive enables or disables downloads of directories as zip
+// and tar.gz archives with "?download=zip". Enabled by default.
+func WithArchive(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.noArchive = !enabled
+	}
+}
+
+// WithArchiveDirs enables or disables browsing zip, tar and tar.gz
+// files as directories, e.g. "/release.tar.gz/". Enabled by default.
+func WithArchiveDirs(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.noArchiveDirs = !enabled
+	}
+}
+
+// WithMarkdown enables or disables rendering Markdown files as HTML,
+// and README files below directory listings. Enabled by default.
+func WithMarkdown(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.noMarkdown = !enabled
+	}
+}
+
+// WithSourceLinks links source files in directory listings to their
+// syntax highlighted "?mode=source" view. Disabled by default.
+func WithSourceLinks(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.sourceLinks = enabled
+	}
+}
+
+// WithThumbnails serves thumbnails of images, generated by the
+// Thumbnailer, under "/_goserve/thumb" and the "?mode=gallery"
+// view of directories
+func WithThumbnails(thumbnailer *thumb.Thumbnailer) Option {
+	return func(fs *fileServer) {
+		fs.thumbnailer = thumbnailer
+	}
+}
+
+// WithAuth requires HTTP Basic authentication for the scopes,
+// including the API and assets under "/_goserve"
+func WithAuth(scopes ...auth.Scope) Option {
+	return func(fs *fileServer) {
+		fs.authScopes = append(fs.authScopes, scopes...)
+	}
+}
+
+// WithShare serves expiring share links signed by the Sharer, and
+// lets the API create them. A share link grants access to its file
+// or directory tree only, bypassing the authentication of WithAuth.
+func WithShare(sharer *share.Sharer) Option {
+	return func(fs *fileServer) {
+		fs.sharer = sharer
+	}
+}
+
+// WithAccessLog logs every request to the Logger
+func WithAccessLog(logger *accesslog.Logger) Option {
+	return func(fs *fileServer) {
+		fs.accessLog = logger
+	}
+}
+
+// WithDebugLog logs accessed paths and API responses to the
+// logger for debugging. Disabled by default.
+func WithDebugLog(logger *log.Logger) Option {
+	return func(fs *fileServer) {
+		fs.debugLog = logger
+	}
+}
+
+// WithMetrics measures requests and serves the metrics at
+// "/_goserve/metrics" to requests allowed by access
+func WithMetrics(m *metrics.Metrics, access metrics.Access) Option {
+	return func(fs *fileServer) {
+		fs.metrics = m
+		fs.metricsAccess = access
+	}
+}
+
+// WithCompression compresses responses with gzip or brotli as
+// accepted by clients, and serves precompressed ".br" and ".gz"
+// sibling files in place of the requested files
+func WithCompression(opts compress.Options) Option {
+	return func(fs *fileServer) {
+		fs.compress = &opts
+	}
+}
+
+// WithSPA serves the fallback page of a single page application
+// for unknown paths, and disables directory listings in it
+func WithSPA(spa SPA) Option {
+	return func(fs *fileServer) {
+		spa = spa.normalize()
+		fs.spa = &spa
+	}
+}
+
+// WithProxy forwards requests under the prefixes of the rules
+// to backend servers
+func WithProxy(rules ...proxy.Rule) Option {
+	return func(fs *fileServer) {
+		fs.proxyRules = append(fs.proxyRules, rules...)
+	}
+}
+
+// WithSiteFiles applies the Netlify style "_headers" and
+// "_redirects" files at the root, reloaded when they change
+func WithSiteFiles(enabled bool) Option {
+	return func(fs *fileServer) {
+		fs.siteFiles = enabled
+	}
+}
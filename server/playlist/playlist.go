@@ -0,0 +1,41 @@
+// Package playlist writes extended M3U playlists
+package playlist
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ContentType is the media type of UTF-8 M3U playlists
+const ContentType = "audio/x-mpegurl; charset=utf-8"
+
+// Entry is a media file of a playlist
+type Entry struct {
+	// Title is the title shown by players
+	Title string
+
+	// URL of the media file
+	URL string
+}
+
+// Write writes the entries as an extended M3U playlist,
+// titled with name if not empty
+func Write(w io.Writer, name string, entries []Entry) error {
+	b := bufio.NewWriter(w)
+	b.WriteString("#EXTM3U\n")
+	if name != "" {
+		b.WriteString("#PLAYLIST:" + line(name) + "\n")
+	}
+	for _, entry := range entries {
+		// the duration is unknown without reading the files
+		b.WriteString("#EXTINF:-1," + line(entry.Title) + "\n")
+		b.WriteString(line(entry.URL) + "\n")
+	}
+	return b.Flush()
+}
+
+// line keeps the text on a single line
+func line(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
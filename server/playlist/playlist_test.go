@@ -0,0 +1,34 @@
+package playlist_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-serve/goserve/server/playlist"
+)
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := playlist.Write(&buf, "music", []playlist.Entry{
+		{Title: "01 Intro", URL: "http://example.com/music/01%20Intro.mp3"},
+		{Title: "two\nlines", URL: "http://example.com/music/b.ogg"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	want := "#EXTM3U\n" +
+		"#PLAYLIST:music\n" +
+		"#EXTINF:-1,01 Intro\n" +
+		"http://example.com/music/01%20Intro.mp3\n" +
+		"#EXTINF:-1,two lines\n" +
+		"http://example.com/music/b.ogg\n"
+	if have := buf.String(); want != have {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, have)
+	}
+
+	buf.Reset()
+	playlist.Write(&buf, "", nil)
+	if want, have := "#EXTM3U\n", buf.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
@@ -0,0 +1,180 @@
+// Package proxy forwards requests under URL path prefixes
+// to backend servers, e.g. an API server in development
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-serve/goserve/server/midway"
+)
+
+// DefaultTimeout to connect and to wait for response headers
+const DefaultTimeout = 30 * time.Second
+
+// Rule forwards requests under Prefix to Target
+type Rule struct {
+	Prefix string
+	Target *url.URL
+
+	// Rewrite replaces Prefix in the forwarded path, e.g. "/" to
+	// forward "/api/users" as "/users". Empty keeps the path.
+	Rewrite string
+
+	// ChangeOrigin sets the Host header to the host of Target
+	// instead of the host requested by client
+	ChangeOrigin bool
+
+	// Timeout to connect and to wait for response headers.
+	// Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// StripAuth removes the Authorization header of requests,
+	// when it holds the credentials of goserve itself
+	StripAuth bool
+}
+
+// ParseRule parses a rule in the form of "/prefix=http://host:port"
+func ParseRule(value string) (rule Rule, err error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") {
+		err = fmt.Errorf("expected /prefix=http://host:port, got %#v", value)
+		return
+	}
+	rule.Prefix = parts[0]
+	rule.Target, err = ParseTarget(parts[1])
+	return
+}
+
+// ParseTarget parses the URL of a backend server
+func ParseTarget(value string) (target *url.URL, err error) {
+	if target, err = url.Parse(value); err != nil {
+		return
+	}
+	if (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		err = fmt.Errorf("expected http or https URL, got %#v", value)
+	}
+	return
+}
+
+// rewrite returns the path forwarded to the backend
+func (rule Rule) rewrite(p string) string {
+	if rule.Rewrite != "" {
+		rewritten := path.Join("/", rule.Rewrite, strings.TrimPrefix(p, rule.Prefix))
+		if strings.HasSuffix(p, "/") && rewritten != "/" {
+			rewritten += "/"
+		}
+		p = rewritten
+	}
+	return singleJoiningSlash(rule.Target.Path, p)
+}
+
+// handler returns the reverse proxy of the rule
+func (rule Rule) handler() http.Handler {
+
+	timeout := rule.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	target := rule.Target
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.Header.Set("X-Forwarded-Host", r.Host)
+			if r.TLS != nil {
+				r.Header.Set("X-Forwarded-Proto", "https")
+			} else {
+				r.Header.Set("X-Forwarded-Proto", "http")
+			}
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path = rule.rewrite(r.URL.Path)
+			r.URL.RawPath = ""
+			if target.RawQuery != "" && r.URL.RawQuery != "" {
+				r.URL.RawQuery = target.RawQuery + "&" + r.URL.RawQuery
+			} else if target.RawQuery != "" {
+				r.URL.RawQuery = target.RawQuery
+			}
+			if rule.ChangeOrigin {
+				r.Host = target.Host
+			}
+			if rule.StripAuth {
+				r.Header.Del("Authorization")
+			}
+			if _, ok := r.Header["User-Agent"]; !ok {
+				// explicitly disable User-Agent so it's not set to default value
+				r.Header.Set("User-Agent", "")
+			}
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Error proxying %#v to %s: %s", r.URL.Path, target, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		},
+	}
+}
+
+// Middleware generates a middleware that forwards requests to the
+// rule with the longest prefix matching the URL path. WebSocket
+// upgrades are forwarded too.
+func Middleware(rules ...Rule) midway.Middleware {
+
+	type route struct {
+		prefix  string
+		handler http.Handler
+	}
+	routes := make([]route, len(rules))
+	for i, rule := range rules {
+		rule.Prefix = path.Clean("/" + rule.Prefix)
+		routes[i] = route{rule.Prefix, rule.handler()}
+	}
+
+	// longest prefix first
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, route := range routes {
+				p := r.URL.Path
+				if route.prefix == "/" || p == route.prefix || strings.HasPrefix(p, route.prefix+"/") {
+					route.handler.ServeHTTP(w, r)
+					return
+				}
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// singleJoiningSlash joins URL paths with exactly one slash
+func singleJoiningSlash(a, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,164 @@
+package proxy_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/proxy"
+)
+
+func backend(t *testing.T) *url.URL {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "websocket" {
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			fmt.Fprintf(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+			buf.Flush()
+			line, _ := buf.ReadString('\n')
+			fmt.Fprintf(buf, "echo: %s", line)
+			buf.Flush()
+			return
+		}
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.RequestURI())
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+	return target
+}
+
+func TestParseRule(t *testing.T) {
+	rule, err := proxy.ParseRule("/api=http://localhost:3000")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "/api", rule.Prefix; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "localhost:3000", rule.Target.Host; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	for _, value := range []string{"api=http://localhost", "/api", "/api=localhost:3000"} {
+		if _, err := proxy.ParseRule(value); err == nil {
+			t.Errorf("%#v: expected error", value)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	target := backend(t)
+	v1 := *target
+	v1.Path = "/v1"
+
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	h := proxy.Middleware(
+		proxy.Rule{Prefix: "/api", Target: target},
+		proxy.Rule{Prefix: "/api/v1", Target: &v1, Rewrite: "/", ChangeOrigin: true},
+	)(notFound)
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/users?page=2", http.StatusOK, "example.com /api/users?page=2"},
+		{"/api/v1/users/", http.StatusOK, target.Host + " /v1/users/"},
+		{"/apis", http.StatusNotFound, ""},
+		{"/index.html", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if test.body != "" && test.body != w.Body.String() {
+			t.Errorf("%s: expected %#v, got %#v", test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestMiddlewareStripAuth(t *testing.T) {
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(srv.Close)
+	target, _ := url.Parse(srv.URL)
+
+	h := proxy.Middleware(
+		proxy.Rule{Prefix: "/api", Target: target},
+		proxy.Rule{Prefix: "/private", Target: target, StripAuth: true},
+	)(http.NotFoundHandler())
+	tests := []struct {
+		path string
+		auth string
+	}{
+		{"/api", "Basic YWxpY2U6c2VjcmV0"},
+		{"/private", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+		r.SetBasicAuth("alice", "secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if want, have := test.auth, w.Body.String(); want != have {
+			t.Errorf("%s: expected %#v, got %#v", test.path, want, have)
+		}
+	}
+}
+
+func TestMiddlewareBadGateway(t *testing.T) {
+	target, _ := url.Parse("http://127.0.0.1:1")
+	h := proxy.Middleware(proxy.Rule{Prefix: "/api", Target: target})(http.NotFoundHandler())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/api", nil))
+	if want, have := http.StatusBadGateway, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestMiddlewareWebSocket(t *testing.T) {
+
+	target := backend(t)
+	srv := httptest.NewServer(proxy.Middleware(proxy.Rule{Prefix: "/ws", Target: target})(http.NotFoundHandler()))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := http.StatusSwitchingProtocols, resp.StatusCode; want != have {
+		b, _ := ioutil.ReadAll(resp.Body)
+		t.Fatalf("expected status %d, got %d: %s", want, have, b)
+	}
+
+	fmt.Fprintf(conn, "hello\n")
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "echo: hello\n", line; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
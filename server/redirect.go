@@ -0,0 +1,28 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RedirectHTTPS returns a handler that permanently redirects every request
+// to the same host and path over HTTPS on the given port
+func RedirectHTTPS(port uint64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+			host = host[1 : len(host)-1] // bracketed IPv6 literal without port
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, fmt.Sprintf("%d", port))
+		} else if net.ParseIP(host) != nil && net.ParseIP(host).To4() == nil {
+			host = "[" + host + "]" // bare IPv6 literal
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
@@ -1,14 +1,29 @@
 package server
 
 import (
-	"github.com/go-midway/midway"
 	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/accesslog"
 	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/compress"
+	"github.com/go-serve/goserve/server/dav"
+	"github.com/go-serve/goserve/server/ignore"
+	"github.com/go-serve/goserve/server/livereload"
+	"github.com/go-serve/goserve/server/metrics"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/netlify"
+	"github.com/go-serve/goserve/server/proxy"
+	"github.com/go-serve/goserve/server/share"
+	"github.com/go-serve/goserve/server/source"
+	"github.com/go-serve/goserve/server/thumb"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
 
 	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strings"
@@ -104,39 +119,323 @@ func ServeAssets(path string, root http.FileSystem) midway.Middleware {
 }
 
 // FileServer returns our custom goserve file server
-func FileServer(root http.FileSystem) http.Handler {
-	fserver := &fileServer{
-		root:    root,
-		fileSrv: http.FileServer(root),
-	}
-	middlewares := midway.Chain(
-		api.ServeAPI("/_goserve/api", root),
-		ServeAssets("/_goserve/assets", assets.FileSystem()),
-		ServeVideo(root),
-		ServeSrt(root),
-	)
-	return middlewares(fserver)
+func FileServer(root http.FileSystem, options ...Option) http.Handler {
+	fserver := &fileServer{}
+	for _, option := range options {
+		option(fserver)
+	}
+	root = fserver.hide(root)
+	if fserver.siteFiles {
+		fserver.site = netlify.New(root)
+	}
+	return fserver.handler(root)
+}
+
+// handler builds the middleware chain around the file server
+func (fserver *fileServer) handler(base http.FileSystem) http.Handler {
+	root := base
+	if !fserver.noArchiveDirs {
+		root = vfs.FilteredArchives(root, fserver.hide)
+	}
+	fserver.root = root
+	fserver.fileSrv = http.FileServer(root)
+
+	var chain []midway.Middleware
+	var apiOptions []api.Option
+	if fserver.accessLog != nil {
+		chain = append(chain, fserver.accessLog.Middleware())
+	}
+	if fserver.metrics != nil {
+		chain = append(chain,
+			fserver.metrics.Middleware(fserver.handlerName),
+			ServeMetrics("/_goserve/metrics", fserver.metrics.Handler(fserver.metricsAccess)),
+		)
+		apiOptions = append(apiOptions, api.WithGraphQLHook(fserver.metrics.GraphQL))
+	}
+	if fserver.debugLog != nil {
+		apiOptions = append(apiOptions, api.WithDebugLog(fserver.debugLog))
+	}
+	if fserver.sharer != nil {
+		shared := &sharedHandlers{build: func(prefix string) http.Handler {
+			// serves the shared tree without authentication,
+			// without creating further share links and without
+			// proxies and rewrites leading out of the tree. The
+			// request is already logged and measured.
+			sub := *fserver
+			sub.accessLog = nil
+			sub.metrics = nil
+			sub.authScopes = nil
+			sub.sharer = nil
+			sub.upload = nil
+			sub.davPrefix = ""
+			sub.proxyRules = nil
+			sub.site = nil
+			return sub.handler(vfs.Restrict(base, prefix))
+		}}
+		chain = append(chain, ServeShares(fserver.sharer, root, shared.get))
+		apiOptions = append(apiOptions, api.WithSharer(fserver.sharer))
+	}
+	if !fserver.noArchive {
+		apiOptions = append(apiOptions, api.WithArchive())
+	}
+	if !fserver.noArchiveDirs {
+		apiOptions = append(apiOptions, api.WithArchiveDirs())
+	}
+	if fserver.thumbnailer != nil {
+		apiOptions = append(apiOptions, api.WithThumbnails(thumbPath))
+	}
+	if len(fserver.authScopes) > 0 {
+		chain = append(chain, auth.Middleware(fserver.authPath, fserver.authScopes...))
+	}
+	if len(fserver.headers) > 0 {
+		chain = append(chain, ServeHeaders(fserver.headers))
+	}
+	fserver.uploader = nil
+	if fserver.upload != nil {
+		opts := *fserver.upload
+		opts.Protected = append(append(opts.Protected[:len(opts.Protected):len(opts.Protected)],
+			path.Base(netlify.HeadersFile), path.Base(netlify.RedirectsFile)), fserver.ignoreFiles...)
+		opts.Error = func(w http.ResponseWriter, r *http.Request, code int) {
+			serveError(w, r, root, code)
+		}
+		fserver.uploader = upload.New(root, opts)
+	}
+	if fserver.davPrefix != "" {
+		davOptions := dav.Options{Prefix: fserver.davPrefix}
+		if fserver.uploader != nil {
+			davOptions.Writable = fserver.uploader.Allowed
+			davOptions.MaxSize = fserver.upload.MaxSize
+			davOptions.Conflict = fserver.upload.Conflict
+		}
+		chain = append(chain, dav.Middleware(root, davOptions))
+	}
+	if len(fserver.proxyRules) > 0 {
+		rules := make([]proxy.Rule, len(fserver.proxyRules))
+		for i, rule := range fserver.proxyRules {
+			// never leak the credentials of goserve to backends
+			rule.StripAuth = rule.StripAuth || len(fserver.authScopes) > 0
+			rules[i] = rule
+		}
+		chain = append(chain, proxy.Middleware(rules...))
+	}
+	if fserver.site != nil {
+		var rewritten []midway.Middleware
+		if len(fserver.authScopes) > 0 {
+			// authorize the paths requests are rewritten to
+			rewritten = append(rewritten, auth.Middleware(fserver.authPath, fserver.authScopes...))
+		}
+		chain = append(chain, fserver.site.Middleware(rewritten...))
+	}
+	if fserver.uploader != nil {
+		chain = append(chain, fserver.uploader.Middleware())
+	}
+	if fserver.compress != nil {
+		chain = append(chain, compress.Middleware(*fserver.compress))
+	}
+	if !fserver.noAPI {
+		chain = append(chain, api.ServeAPI("/_goserve/api", root, apiOptions...))
+	}
+	chain = append(chain, ServeAssets("/_goserve/assets", assets.FileSystem()))
+	if fserver.thumbnailer != nil {
+		chain = append(chain, fserver.thumbnailer.Middleware(thumbPath, root))
+	}
+	if fserver.liveReload != nil {
+		chain = append(chain, fserver.liveReload.Middleware("/_goserve/livereload"))
+	}
+	chain = append(chain, ServePlaylist(root))
+	if !fserver.noArchive {
+		chain = append(chain, ServeArchive(root, fserver.authScopes...))
+	}
+	if !fserver.noMarkdown {
+		chain = append(chain, ServeMarkdown(root))
+	}
+	chain = append(chain, ServeSource(root))
+	if fserver.thumbnailer != nil {
+		chain = append(chain, ServeGallery(root))
+	}
+	chain = append(chain, ServeAudio(root))
+	if !fserver.noVideoPlayer {
+		chain = append(chain,
+			ServeVideo(root),
+			ServeSrt(root),
+		)
+	}
+	if fserver.compress != nil {
+		chain = append(chain, compress.Precompressed(root, *fserver.compress))
+	}
+	return midway.Chain(chain...)(fserver)
+}
+
+// ServeMetrics generates a middleware that serves
+// the metrics handler at the path
+func ServeMetrics(path string, metrics http.Handler) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == path {
+				metrics.ServeHTTP(w, r)
+				return
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// handlerName names the handler serving the request for metrics,
+// by its route only, so measuring takes no file system access
+func (fserver *fileServer) handlerName(r *http.Request) string {
+	p := r.URL.Path
+	switch {
+	case p == "/_goserve/api/graphql":
+		return "graphql"
+	case strings.HasPrefix(p, "/_goserve/api/"):
+		return "rest"
+	case strings.HasPrefix(p, assetsPath+"/"):
+		return "assets"
+	case p == "/_goserve/metrics":
+		return "metrics"
+	case fserver.thumbnailer != nil && strings.HasPrefix(p, thumbPath+"/"):
+		return "thumb"
+	case strings.HasPrefix(p, upload.TusPath+"/"):
+		return "upload"
+	case fserver.davPrefix != "" && hasPathPrefix(p, fserver.davPrefix):
+		return "dav"
+	case strings.HasPrefix(p, "/_goserve/"):
+		return "other"
+	}
+	for _, rule := range fserver.proxyRules {
+		if hasPathPrefix(p, path.Clean("/"+rule.Prefix)) {
+			return "proxy"
+		}
+	}
+	if fserver.upload != nil && (r.Method == http.MethodPut || r.Method == http.MethodPost) {
+		return "upload"
+	}
+	if wantsPlaylist(r) {
+		return "playlist"
+	}
+	if !fserver.noArchive && r.URL.Query().Get("download") != "" {
+		return "archive"
+	}
+	if !fserver.noMarkdown && wantsMarkdown(r) {
+		return "markdown"
+	}
+	if wantsSource(r) {
+		return "source"
+	}
+	if fserver.thumbnailer != nil && wantsGallery(r) {
+		return "gallery"
+	}
+	if wantsAudio(r) {
+		return "audioplayer"
+	}
+	if !fserver.noVideoPlayer {
+		switch r.URL.Query().Get("mode") {
+		case "videoplayer":
+			return "videoplayer"
+		case "vtt":
+			return "srt"
+		}
+	}
+	if strings.HasSuffix(p, "/") {
+		// directories are served at paths with a trailing
+		// slash, which counts their index pages as listings
+		return "listing"
+	}
+	return "file"
+}
+
+// authPath returns the file path accessed by a request for
+// authentication. Endpoints under "/_goserve" that are not bound
+// to a file path are protected as the root.
+func (fserver *fileServer) authPath(r *http.Request) string {
+	const apiPath = "/_goserve/api"
+	p := r.URL.Path
+	if fserver.davPrefix != "" && hasPathPrefix(p, fserver.davPrefix) {
+		// WebDAV moves and copies may write anywhere
+		if r.Header.Get("Destination") != "" {
+			return auth.AnyPath
+		}
+		return path.Clean("/" + strings.TrimPrefix(p, fserver.davPrefix))
+	}
+	switch {
+	case strings.HasPrefix(p, apiPath+"/stats/"):
+		return "/" + p[len(apiPath+"/stats/"):]
+	case strings.HasPrefix(p, apiPath+"/lists/"):
+		return "/" + p[len(apiPath+"/lists/"):]
+	case p == apiPath+"/graphql", p == apiPath+"/shares":
+		// share links bypass authentication, so creating one
+		// requires the credentials of every scope
+		return auth.AnyPath
+	case strings.HasPrefix(p, thumbPath+"/"):
+		return p[len(thumbPath):]
+	case fserver.uploader != nil && strings.HasPrefix(p, upload.TusPath+"/"):
+		// tus uploads by the path of the file uploaded
+		if name, ok := fserver.uploader.TusTarget(p[len(upload.TusPath+"/"):]); ok {
+			return name
+		}
+		return "/"
+	case strings.HasPrefix(p, "/_goserve/"):
+		return "/"
+	}
+	return p
 }
 
 // custom implementation of FileServer
 type fileServer struct {
 	root    http.FileSystem
 	fileSrv http.Handler
+
+	authScopes    []auth.Scope
+	headers       []HeaderRule
+	hidden        vfs.HideFunc
+	hiddenPolicy  HiddenPolicy
+	ignoreFiles   []string
+	ignore        *ignore.Matcher
+	liveReload    *livereload.Reloader
+	sharer        *share.Sharer
+	accessLog     *accesslog.Logger
+	metrics       *metrics.Metrics
+	metricsAccess metrics.Access
+	compress      *compress.Options
+	spa           *SPA
+	proxyRules    []proxy.Rule
+	siteFiles     bool
+	site          *netlify.Site
+	upload        *upload.Options
+	uploader      *upload.Uploader
+	davPrefix     string
+	debugLog      *log.Logger
+	noAPI         bool
+	noVideoPlayer bool
+	noArchive     bool
+	noArchiveDirs bool
+	noMarkdown    bool
+	sourceLinks   bool
+	thumbnailer   *thumb.Thumbnailer
 }
 
 // ServeHTTP implements http.Handler
 func (fs *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	log.Printf("access %#v", r.URL.Path)
+	if fs.debugLog != nil {
+		fs.debugLog.Printf("access %#v", r.URL.Path)
+	}
+
+	// serve the fallback page for routes of single page application
+	if fs.serveSPA(w, r) {
+		return
+	}
 
 	// serve directory indexes
 	if d, err := fs.ReadDirInfo(r.URL.Path); err == nil {
 
-		if _, err := fs.ReadIndex(r.URL.Path); err != nil {
+		index, err := fs.ReadIndex(r.URL.Path)
+		if err != nil {
 
 			files, err := d.Readdir(0)
 			if err != nil {
 				log.Printf("Error listing path %#v:%s", r.URL.Path, err)
+				serveError(w, r, fs.root, errorStatus(err))
 				return
 			}
 
@@ -148,14 +447,47 @@ func (fs *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			}
 			api.QuerySort(s, files) // TODO: add error reporting here
 
+			// show the README below the listing
+			readme := ""
+			if !fs.noMarkdown {
+				readme = fs.readme(path.Clean("/"+r.URL.Path), files)
+			}
+
 			// list the files
-			listFiles(w, r.URL.Path, files)
+			listFiles(w, r.URL.Path, files, listing{
+				Upload:      fs.uploader != nil && fs.uploader.Allowed(r.Context(), r.URL.Path),
+				Archive:     !fs.noArchive,
+				Gallery:     fs.thumbnailer != nil && len(galleryFiles(files)) > 0,
+				Playlist:    len(playlistFiles(files)) > 0,
+				SourceLinks: fs.sourceLinks,
+				Sort:        r.URL.Query().Get("sort"),
+				Readme:      readme,
+			})
 			return
 
 		}
 
+		// the file server would redirect the index of an archive,
+		// which is a file without the trailing slash
+		if name := path.Clean(r.URL.Path); !fs.noArchiveDirs && vfs.IsArchive(name) {
+			defer index.Close()
+			stat, err := index.Stat()
+			if err != nil {
+				serveError(w, r, fs.root, errorStatus(err))
+				return
+			}
+			http.ServeContent(w, r, "index.html", stat.ModTime(), index)
+			return
+		}
+		index.Close()
+
 	} else if err != errNotDir {
-		log.Printf("Error reading path %#v: %s", r.URL.Path, err)
+		code := errorStatus(err)
+		if code == http.StatusInternalServerError {
+			log.Printf("Error reading path %#v: %s", r.URL.Path, err)
+		}
+		serveError(w, r, fs.root, code)
+		return
 	}
 
 	// fallback to normal file server
@@ -204,13 +536,33 @@ func (fs *fileServer) ReadIndex(path string) (f http.File, err error) {
 	return
 }
 
-func listFiles(w http.ResponseWriter, base string, files []os.FileInfo) {
+// listing are the features shown with a directory listing
+type listing struct {
+	Upload      bool
+	Archive     bool
+	Gallery     bool
+	Playlist    bool
+	SourceLinks bool
+
+	// Sort is the sort order requested, if any
+	Sort string
+
+	// Readme is the rendered README of the directory
+	Readme string
+}
+
+func listFiles(w http.ResponseWriter, base string, files []os.FileInfo, l listing) {
 	w.Header().Add("Content-Type", "text/html; charset=utf-8")
 	err := tplIndex.Execute(w, map[string]interface{}{
 		"Stylesheets": stylesheets,
 		"Scripts":     scripts,
-		"Files":       mapFiles(files),
+		"Files":       mapFiles(files, l),
 		"Base":        base,
+		"Upload":      l.Upload,
+		"Archive":     l.Archive,
+		"Gallery":     l.Gallery,
+		"Playlist":    l.Playlist,
+		"Readme":      l.Readme,
 	})
 	if err != nil {
 		log.Printf("err: %#v", err.Error())
@@ -223,16 +575,25 @@ type fileInfo struct {
 	IsDir bool
 }
 
-func mapFiles(in []os.FileInfo) (out []fileInfo) {
+// mapFiles maps the files listed to their links. Audio files link
+// to the audio player in the sort order of the listing, and source
+// files to the source view with SourceLinks.
+func mapFiles(in []os.FileInfo, l listing) (out []fileInfo) {
 	out = make([]fileInfo, len(in))
 	for i := 0; i < len(in); i++ {
-		switch strings.ToLower(path.Ext(in[i].Name())) {
-		case ".mp4":
-			fallthrough
-		case ".webm":
+		switch {
+		case isVideo(in[i].Name()):
 			out[i].Name = in[i].Name()
 			out[i].Path = in[i].Name() + "?mode=videoplayer"
 			out[i].IsDir = in[i].IsDir()
+		case !in[i].IsDir() && isAudio(in[i].Name()):
+			out[i].Name = in[i].Name()
+			out[i].Path = (&url.URL{Path: in[i].Name(), RawQuery: audioQuery(l.Sort)}).String()
+			out[i].IsDir = false
+		case l.SourceLinks && !in[i].IsDir() && source.IsSource(in[i].Name()):
+			out[i].Name = in[i].Name()
+			out[i].Path = (&url.URL{Path: in[i].Name()}).String() + "?mode=source"
+			out[i].IsDir = false
 		default:
 			out[i].Name = in[i].Name()
 			out[i].Path = in[i].Name()
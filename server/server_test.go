@@ -2,10 +2,34 @@ package server_test
 
 import (
 	"github.com/go-serve/goserve/server"
+	"github.com/go-serve/goserve/server/api"
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/glob"
+	"github.com/go-serve/goserve/server/metrics"
+	"github.com/go-serve/goserve/server/proxy"
+	"github.com/go-serve/goserve/server/share"
+	"github.com/go-serve/goserve/server/thumb"
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
 
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"testing"
 )
@@ -33,3 +57,1293 @@ func TestFileServer(t *testing.T) {
 	}
 
 }
+
+func TestRedirectHTTPS(t *testing.T) {
+
+	tests := []struct {
+		port     uint64
+		url      string
+		location string
+	}{
+		{8443, "http://localhost:8080/foo/bar?sort=name", "https://localhost:8443/foo/bar?sort=name"},
+		{443, "http://example.com:8080/", "https://example.com/"},
+		{8443, "http://[::1]:8080/", "https://[::1]:8443/"},
+		{8443, "http://[::1]/", "https://[::1]:8443/"},
+		{443, "http://[::1]/foo", "https://[::1]/foo"},
+		{443, "http://[::1]:8080/", "https://[::1]/"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", test.url, nil)
+		w := httptest.NewRecorder()
+		server.RedirectHTTPS(test.port).ServeHTTP(w, req)
+
+		if want, have := http.StatusMovedPermanently, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.url, want, have)
+		}
+		if want, have := test.location, w.Header().Get("Location"); want != have {
+			t.Errorf("%s: expected location %#v, got %#v", test.url, want, have)
+		}
+	}
+}
+
+func TestFileServerOptions(t *testing.T) {
+
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithHeaders(
+			server.HeaderRule{
+				Header: http.Header{"Cache-Control": {"no-cache"}},
+			},
+			server.HeaderRule{
+				Pattern: glob.MustCompile("/folder1/**"),
+				Header:  http.Header{"Cache-Control": {"public, max-age=60"}},
+			},
+		),
+		server.WithHidden(vfs.HidePatterns(glob.MustCompile("foo2"))),
+	)
+
+	tests := []struct {
+		path         string
+		code         int
+		cacheControl string
+	}{
+		{"/", http.StatusOK, "no-cache"},
+		{"/folder1/foo1", http.StatusOK, "public, max-age=60"},
+		{"/folder1/foo2", http.StatusNotFound, "public, max-age=60"},
+		{"/_goserve/api/stats/folder1/foo2", http.StatusNotFound, "no-cache"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if want, have := test.cacheControl, w.Header().Get("Cache-Control"); want != have {
+			t.Errorf("%s: expected Cache-Control %#v, got %#v", test.path, want, have)
+		}
+	}
+
+	// hidden file should not be listed
+	w := httptest.NewRecorder()
+	th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/_goserve/api/lists/folder1", nil))
+	if strings.Contains(w.Body.String(), "foo2") {
+		t.Errorf("hidden file listed: %s", w.Body.String())
+	}
+}
+
+func TestFileServerAuth(t *testing.T) {
+
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithAuth(
+			auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}},
+			auth.Scope{Prefix: "/folder1", Users: auth.Users{"bob": "secret"}},
+		),
+	)
+
+	tests := []struct {
+		path string
+		user string
+		code int
+	}{
+		{"/", "", http.StatusUnauthorized},
+		{"/", "alice", http.StatusOK},
+		{"/_goserve/assets/css/app.css", "", http.StatusUnauthorized},
+		{"/_goserve/assets/css/app.css", "alice", http.StatusOK},
+		{"/_goserve/api/lists", "alice", http.StatusOK},
+		{"/_goserve/api/lists/folder1", "alice", http.StatusUnauthorized},
+		{"/_goserve/api/lists/folder1", "bob", http.StatusOK},
+		{"/folder1/foo1", "bob", http.StatusOK},
+		{"/_goserve/api/graphql?query={stat(path:\"/\"){name}}", "alice", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+		if test.user != "" {
+			r.SetBasicAuth(test.user, "secret")
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s as %#v: expected status %d, got %d", test.path, test.user, want, have)
+		}
+	}
+}
+
+func TestFileServerShare(t *testing.T) {
+
+	sharer := share.New([]byte("0123456789abcdef"))
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithAuth(auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}}),
+		server.WithShare(sharer),
+	)
+	serve := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	folder, _ := sharer.Sign("/folder1", time.Hour, 0, "")
+	expired, _ := sharer.Sign("/folder1", -time.Hour, 0, "")
+	limited, _ := sharer.Sign("/folder1/foo1", time.Hour, 1, "")
+	protected, _ := sharer.Sign("/folder1/foo1", time.Hour, 0, "pass")
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/folder1/foo1?share=" + folder.Token, http.StatusOK},
+		{"/folder1/?share=" + folder.Token, http.StatusOK},
+		{"/index.html?share=" + folder.Token, http.StatusForbidden},
+		{"/folder1/foo1?share=" + folder.Token + "x", http.StatusForbidden},
+		{"/folder1/foo1?share=" + expired.Token, http.StatusGone},
+		{"/folder1/foo1?share=" + limited.Token, http.StatusOK},
+		{"/folder1/foo1?share=" + limited.Token, http.StatusGone},
+		{"/folder1/foo1?share=" + protected.Token, http.StatusUnauthorized},
+		{"/_goserve/api/lists/?share=" + folder.Token, http.StatusOK},
+		{"/_goserve/api/stats/index.html?share=" + folder.Token, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		if want, have := test.code, serve(test.path).Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+	}
+
+	// the cookie grants access to the shared tree only
+	w := serve("/folder1/foo2?share=" + folder.Token)
+	cookies := w.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("expected share cookie")
+	}
+	if want, have := http.StatusOK, serve("/folder1/foo2", cookies...).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := http.StatusUnauthorized, serve("/index.html", cookies...).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if body := serve("/_goserve/api/lists/", cookies...).Body.String(); strings.Contains(body, "index.html") {
+		t.Errorf("file outside shared tree listed: %s", body)
+	}
+
+	// password in basic auth
+	r := httptest.NewRequest("GET", "http://example.com/folder1/foo1?share="+protected.Token, nil)
+	r.SetBasicAuth("", "pass")
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestFileServerShareRouting(t *testing.T) {
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "folder"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "folder", "a.txt"), []byte("shared"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "_redirects"), []byte("/folder/ext/*  "+backend.URL+"/:splat  200\n"), 0644)
+
+	sharer := share.New([]byte("0123456789abcdef"))
+	th := server.FileServer(vfs.Dir(root),
+		server.WithAuth(auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}}),
+		server.WithShare(sharer),
+		server.WithSiteFiles(true),
+		server.WithProxy(proxy.Rule{Prefix: "/folder/api", Target: target, Rewrite: "/"}),
+	)
+	link, _ := sharer.Sign("/folder", time.Hour, 0, "")
+
+	// share links reach files of the shared tree only,
+	// neither proxies nor rewrites of _redirects
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/folder/a.txt", http.StatusOK, "shared"},
+		{"/folder/api/users", http.StatusNotFound, ""},
+		{"/folder/ext/users", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+test.path+"?share="+link.Token, nil))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if test.body != "" && test.body != w.Body.String() {
+			t.Errorf("%s: expected %#v, got %#v", test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestFileServerCreateShare(t *testing.T) {
+
+	sharer := share.New([]byte("0123456789abcdef"))
+	th := server.FileServer(http.Dir("./../_example"), server.WithShare(sharer))
+
+	r := httptest.NewRequest("POST", "http://example.com/_goserve/api/shares",
+		strings.NewReader(`{"path":"/folder1/foo1","ttl":"2h","maxDownloads":3}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	var info api.ShareInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 3, info.MaxDownloads; want != have {
+		t.Errorf("expected %d, got %d", want, have)
+	}
+	if want, have := "http://example.com/folder1/foo1?share=", info.URL; !strings.HasPrefix(have, want) {
+		t.Errorf("expected prefix %#v, got %#v", want, have)
+	}
+
+	// created link works
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, httptest.NewRequest("GET", info.URL, nil))
+	if want, have := "bar1\n", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// not for missing files
+	r = httptest.NewRequest("POST", "http://example.com/_goserve/api/shares",
+		strings.NewReader(`{"path":"/nothing"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// graphql mutation
+	r = httptest.NewRequest("POST", "http://example.com/_goserve/api/graphql",
+		strings.NewReader(`{"query":"mutation { createShare(path: \"/folder1\", password: \"pw\") { url hasPassword } }"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := `"hasPassword":true`, w.Body.String(); !strings.Contains(have, want) {
+		t.Errorf("expected %s in %s", want, have)
+	}
+
+	// only a protected folder, with a public root
+	th = server.FileServer(http.Dir("./../_example"),
+		server.WithShare(sharer),
+		server.WithAuth(auth.Scope{Prefix: "/folder1", Users: auth.Users{"bob": "secret"}}),
+	)
+	tests := []struct {
+		body string
+		user string
+		code int
+	}{
+		{`{"path":"/folder1/foo1"}`, "", http.StatusUnauthorized},
+		{`{"path":"/index.html"}`, "", http.StatusUnauthorized},
+		{`{"path":"/folder1/foo1"}`, "bob", http.StatusOK},
+	}
+	for _, test := range tests {
+		r = httptest.NewRequest("POST", "http://example.com/_goserve/api/shares", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", "application/json")
+		if test.user != "" {
+			r.SetBasicAuth(test.user, "secret")
+		}
+		w = httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s as %#v: expected status %d, got %d", test.body, test.user, want, have)
+		}
+	}
+}
+
+func TestFileServerMetrics(t *testing.T) {
+
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithMetrics(metrics.New(), metrics.Access{}))
+
+	for _, path := range []string{
+		"/folder1/",
+		"/folder1/foo1",
+		"/_goserve/api/lists/folder1",
+		"/_goserve/api/graphql?query={stat(path:\"/\"){name}}",
+		"/_goserve/api/graphql?query={nothing}",
+	} {
+		th.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com"+path, nil))
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/_goserve/metrics", nil)
+	r.RemoteAddr = "127.0.0.1:1234"
+	w := httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	for _, want := range []string{
+		`goserve_http_requests_total{code="200",handler="listing",method="GET"} 1`,
+		`goserve_http_requests_total{code="200",handler="file",method="GET"} 1`,
+		`goserve_http_requests_total{code="200",handler="rest",method="GET"} 1`,
+		`goserve_http_response_bytes_total{handler="file"} 5`,
+		`goserve_graphql_queries_total 2`,
+		`goserve_graphql_errors_total 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected %s in metrics", want)
+		}
+	}
+
+	// handlers are named without opening files
+	for _, path := range []string{"/folder1/", "/folder1/foo1"} {
+		var opens [2]int
+		for i, options := range [][]server.Option{
+			nil,
+			{server.WithMetrics(metrics.New(), metrics.Access{})},
+		} {
+			root := countingFS{FileSystem: http.Dir("./../_example"), opens: &opens[i]}
+			server.FileServer(root, options...).ServeHTTP(httptest.NewRecorder(),
+				httptest.NewRequest("GET", "http://example.com"+path, nil))
+		}
+		if want, have := opens[0], opens[1]; want != have {
+			t.Errorf("%s: expected %d opens, got %d", path, want, have)
+		}
+	}
+
+	// other clients are not allowed
+	r = httptest.NewRequest("GET", "http://example.com/_goserve/metrics", nil)
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+// countingFS counts the files opened
+type countingFS struct {
+	http.FileSystem
+	opens *int
+}
+
+func (fs countingFS) Open(name string) (http.File, error) {
+	*fs.opens++
+	return fs.FileSystem.Open(name)
+}
+
+func TestFileServerSPA(t *testing.T) {
+
+	index, err := ioutil.ReadFile("./../_example/index.html")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithSPA(server.SPA{Exclude: []string{"/api"}}))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/settings/profile", http.StatusOK, string(index)},
+		{"/folder1/", http.StatusOK, string(index)},
+		{"/folder1/foo1", http.StatusOK, "bar1\n"},
+		{"/js/missing.js", http.StatusNotFound, ""},
+		{"/api/users", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+test.path, nil))
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if test.body != "" && test.body != w.Body.String() {
+			t.Errorf("%s: unexpected body %#v", test.path, w.Body.String())
+		}
+	}
+}
+
+func TestFileServerProxy(t *testing.T) {
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("backend " + r.URL.Path + r.Header.Get("Authorization")))
+	}))
+	defer backend.Close()
+	target, _ := url.Parse(backend.URL)
+
+	th := server.FileServer(http.Dir("./../_example"),
+		server.WithSPA(server.SPA{}),
+		server.WithProxy(proxy.Rule{Prefix: "/api", Target: target, Rewrite: "/"}),
+	)
+	w := httptest.NewRecorder()
+	th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/api/users", nil))
+	if want, have := "backend /users", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// credentials of goserve are not forwarded
+	th = server.FileServer(http.Dir("./../_example"),
+		server.WithAuth(auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}}),
+		server.WithProxy(proxy.Rule{Prefix: "/api", Target: target, Rewrite: "/"}),
+	)
+	r := httptest.NewRequest("GET", "http://example.com/api/users", nil)
+	r.SetBasicAuth("alice", "secret")
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, r)
+	if want, have := "backend /users", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestFileServerErrors(t *testing.T) {
+
+	th := server.FileServer(http.Dir("./../_example"))
+
+	tests := []struct {
+		path        string
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/missing", "text/html", http.StatusNotFound, "text/html; charset=utf-8", "<h1>404 Not Found</h1>"},
+		{"/missing", "application/json", http.StatusNotFound, "application/json", `"code":404`},
+		{"/missing.mp4?mode=videoplayer", "", http.StatusNotFound, "text/html; charset=utf-8", "404 Not Found"},
+		{"/folder1?mode=videoplayer", "", http.StatusNotFound, "text/html; charset=utf-8", "404 Not Found"},
+		{"/missing.srt?mode=vtt", "", http.StatusNotFound, "text/html; charset=utf-8", "404 Not Found"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+		if want, have := test.contentType, w.Header().Get("Content-Type"); want != have {
+			t.Errorf("%s: expected Content-Type %#v, got %#v", test.path, want, have)
+		}
+		if !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("%s: expected body to contain %#v, got %#v", test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestFileServerCustomErrorPage(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "goserve-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "404.html"), []byte("custom not found"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	th := server.FileServer(http.Dir(root))
+	w := httptest.NewRecorder()
+	th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/missing.mp4?mode=videoplayer", nil))
+	if want, have := http.StatusNotFound, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := "custom not found", w.Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
+
+func TestFileServerHiddenPolicy(t *testing.T) {
+
+	root, err := ioutil.TempDir("", "goserve-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		".env":           "SECRET=1",
+		".goserveignore": "*.key\n",
+		"server.key":     "key",
+		"public.txt":     "public",
+
+		".well-known/security.txt": "Contact: admin@example.com",
+	}
+	os.Mkdir(filepath.Join(root, ".well-known"), 0755)
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	// backup.zip with the same files, filtered alike
+	f, _ := os.Create(filepath.Join(root, "backup.zip"))
+	zw := zip.NewWriter(f)
+	for _, name := range []string{".env", "server.key", "public.txt"} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(files[name]))
+	}
+	zw.Close()
+	f.Close()
+
+	serve := func(th http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+	graphStat := func(name string) string {
+		return `{"query":"{ stat(path: \"` + name + `\") { name } }"}`
+	}
+
+	tests := []struct {
+		policy server.HiddenPolicy
+		listed bool
+		code   int
+	}{
+		{server.HiddenShow, true, http.StatusOK},
+		{server.HiddenHide, false, http.StatusOK},
+		{server.HiddenDeny, false, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		th := server.FileServer(http.Dir(root),
+			server.WithHiddenPolicy(test.policy),
+			server.WithIgnoreFiles(".goserveignore"))
+
+		list := serve(th, "GET", "/_goserve/api/lists/", "").Body.String()
+		if want, have := test.listed, strings.Contains(list, ".env"); want != have {
+			t.Errorf("%s: expected .env listed %v, got %s", test.policy, want, list)
+		}
+		if !strings.Contains(list, "public.txt") || strings.Contains(list, "server.key") {
+			t.Errorf("%s: unexpected listing %s", test.policy, list)
+		}
+		if want, have := test.code, serve(th, "GET", "/.env", "").Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.policy, want, have)
+		}
+		if want, have := test.code, serve(th, "GET", "/_goserve/api/stats/.env", "").Code; want != have {
+			t.Errorf("%s: expected REST status %d, got %d", test.policy, want, have)
+		}
+		graph := serve(th, "POST", "/_goserve/api/graphql", graphStat(".env")).Body.String()
+		if want, have := test.code == http.StatusOK, strings.Contains(graph, `"name":".env"`); want != have {
+			t.Errorf("%s: unexpected GraphQL response %s", test.policy, graph)
+		}
+		if want, have := http.StatusNotFound, serve(th, "GET", "/server.key", "").Code; want != have {
+			t.Errorf("%s: expected ignored file status %d, got %d", test.policy, want, have)
+		}
+		if want, have := http.StatusOK, serve(th, "GET", "/.well-known/security.txt", "").Code; want != have {
+			t.Errorf("%s: expected well-known status %d, got %d", test.policy, want, have)
+		}
+
+		list = serve(th, "GET", "/backup.zip/", "").Body.String()
+		if want, have := test.listed, strings.Contains(list, ".env"); want != have {
+			t.Errorf("%s: expected archived .env listed %v, got %s", test.policy, want, list)
+		}
+		if !strings.Contains(list, "public.txt") || strings.Contains(list, "server.key") {
+			t.Errorf("%s: unexpected archive listing %s", test.policy, list)
+		}
+		if want, have := test.code, serve(th, "GET", "/backup.zip/.env", "").Code; want != have {
+			t.Errorf("%s: expected archived .env status %d, got %d", test.policy, want, have)
+		}
+		if want, have := http.StatusNotFound, serve(th, "GET", "/backup.zip/server.key", "").Code; want != have {
+			t.Errorf("%s: expected archived ignored file status %d, got %d", test.policy, want, have)
+		}
+	}
+}
+
+func TestFileServerSiteFilesAuth(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "private"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "_redirects"), []byte("/docs/*  /:splat  200\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "private", "s.txt"), []byte("secret"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "public.txt"), []byte("public"), 0644)
+
+	th := server.FileServer(vfs.Dir(root),
+		server.WithSiteFiles(true),
+		server.WithAuth(auth.Scope{Prefix: "/private", Users: auth.Users{"alice": "secret"}}),
+	)
+	serve := func(path, user string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		if user != "" {
+			r.SetBasicAuth(user, "secret")
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	// rewritten paths are authorized
+	tests := []struct {
+		path string
+		user string
+		code int
+	}{
+		{"/private/s.txt", "", http.StatusUnauthorized},
+		{"/docs/private/s.txt", "", http.StatusUnauthorized},
+		{"/docs/private/s.txt", "alice", http.StatusOK},
+		{"/docs/public.txt", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		if want, have := test.code, serve(test.path, test.user).Code; want != have {
+			t.Errorf("%s as %#v: expected status %d, got %d", test.path, test.user, want, have)
+		}
+	}
+}
+
+func TestFileServerUpload(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "incoming"), 0755)
+
+	th := server.FileServer(vfs.Dir(root),
+		server.WithAuth(
+			auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret", "bob": "secret"}},
+		),
+		server.WithUpload(upload.Options{
+			Permissions: []upload.Permission{{Prefix: "/incoming", Users: []string{"alice"}}},
+		}),
+		server.WithIgnoreFiles(".goserveignore"),
+	)
+
+	serve := func(method, path, user string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		if user != "" {
+			r.SetBasicAuth(user, "secret")
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		path string
+		user string
+		code int
+	}{
+		{"/incoming/a.txt", "", http.StatusUnauthorized},
+		{"/incoming/a.txt", "bob", http.StatusForbidden},
+		{"/a.txt", "alice", http.StatusForbidden},
+		{"/incoming/a.txt", "alice", http.StatusCreated},
+		{"/incoming/_redirects", "alice", http.StatusForbidden},
+		{"/incoming/_headers", "alice", http.StatusForbidden},
+		{"/incoming/.goserveignore", "alice", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if want, have := test.code, serve("PUT", test.path, test.user, "hello").Code; want != have {
+			t.Errorf("PUT %s as %#v: expected status %d, got %d", test.path, test.user, want, have)
+		}
+	}
+	if want, have := "hello", serve("GET", "/incoming/a.txt", "bob", "").Body.String(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// upload form in listing of allowed users only
+	if body := serve("GET", "/incoming/", "alice", "").Body.String(); !strings.Contains(body, `enctype="multipart/form-data"`) {
+		t.Errorf("expected upload form in listing: %s", body)
+	}
+	if body := serve("GET", "/incoming/", "bob", "").Body.String(); strings.Contains(body, `enctype="multipart/form-data"`) {
+		t.Errorf("unexpected upload form in listing: %s", body)
+	}
+
+	// temporary files of uploads in progress are hidden
+	ioutil.WriteFile(filepath.Join(root, "incoming", ".goserve-upload-123"), []byte("partial"), 0644)
+	if body := serve("GET", "/_goserve/api/lists/incoming/", "bob", "").Body.String(); strings.Contains(body, ".goserve-upload-") {
+		t.Errorf("unexpected temporary file in listing: %s", body)
+	}
+	if want, have := http.StatusNotFound, serve("GET", "/incoming/.goserve-upload-123", "bob", "").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestFileServerTus(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "team"), 0755)
+
+	th := server.FileServer(vfs.Dir(root),
+		server.WithAuth(auth.Scope{Prefix: "/team", Users: auth.Users{"bob": "secret"}}),
+		server.WithUpload(upload.Options{
+			Permissions: []upload.Permission{{Prefix: "/team", Users: []string{"bob"}}},
+		}),
+	)
+	serve := func(method, path, user, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		r.Header.Set("Tus-Resumable", upload.TusVersion)
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		if user != "" {
+			r.SetBasicAuth(user, "secret")
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	// uploads are authorized by the path of their file
+	w := serve("POST", "/team", "bob", "", map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("a.txt")),
+	})
+	if want, have := http.StatusCreated, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	location := w.Header().Get("Location")
+	patch := map[string]string{
+		"Upload-Offset": "0",
+		"Content-Type":  "application/offset+octet-stream",
+	}
+	if want, have := http.StatusUnauthorized, serve("PATCH", location, "", "hello", patch).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := http.StatusNoContent, serve("PATCH", location, "bob", "hello", patch).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(root, "team", "a.txt")); string(b) != "hello" {
+		t.Errorf("expected uploaded file, got %#v", string(b))
+	}
+}
+
+func TestFileServerDAV(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "incoming"), 0755)
+
+	th := server.FileServer(vfs.Dir(root),
+		server.WithAuth(
+			auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret", "bob": "secret"}},
+		),
+		server.WithUpload(upload.Options{
+			Permissions: []upload.Permission{{Prefix: "/incoming", Users: []string{"alice"}}},
+		}),
+		server.WithDAV("/_goserve/dav"),
+		server.WithIgnoreFiles(".goserveignore"),
+	)
+
+	serve := func(method, path, user string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		if user != "" {
+			r.SetBasicAuth(user, "secret")
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		user   string
+		code   int
+	}{
+		{"PROPFIND", "/_goserve/dav/", "", http.StatusUnauthorized},
+		{"PROPFIND", "/_goserve/dav/", "bob", http.StatusMultiStatus},
+		{"PUT", "/_goserve/dav/incoming/a.txt", "bob", http.StatusForbidden},
+		{"PUT", "/_goserve/dav/a.txt", "alice", http.StatusForbidden},
+		{"PUT", "/_goserve/dav/incoming/a.txt", "alice", http.StatusCreated},
+		{"GET", "/_goserve/dav/incoming/a.txt", "bob", http.StatusOK},
+		{"PUT", "/_goserve/dav/incoming/_redirects", "alice", http.StatusForbidden},
+		{"PUT", "/_goserve/dav/incoming/.goserveignore", "alice", http.StatusForbidden},
+		{"MKCOL", "/_goserve/dav/incoming/_headers", "alice", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		body := ""
+		if test.method == "PUT" {
+			body = "hello"
+		}
+		if want, have := test.code, serve(test.method, test.path, test.user, body).Code; want != have {
+			t.Errorf("%s %s as %#v: expected status %d, got %d", test.method, test.path, test.user, want, have)
+		}
+	}
+}
+
+func TestFileServerArchive(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	os.Mkdir(filepath.Join(root, "team"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("hello"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "b.txt"), []byte("world"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "team", "c.txt"), []byte("team"), 0644)
+	ioutil.WriteFile(filepath.Join(root, ".env"), []byte("SECRET=1"), 0644)
+
+	th := server.FileServer(vfs.Dir(root),
+		server.WithHiddenPolicy(server.HiddenDeny),
+		server.WithAuth(auth.Scope{Prefix: "/team", Users: auth.Users{"bob": "secret"}}),
+	)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+	entries := func(w *httptest.ResponseRecorder) string {
+		b := w.Body.Bytes()
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		names := make([]string, len(zr.File))
+		for i, f := range zr.File {
+			names[i] = f.Name
+		}
+		return strings.Join(names, ", ")
+	}
+
+	// whole directory, without hidden files and protected scopes
+	w := serve(httptest.NewRequest("GET", "http://example.com/?download=zip", nil))
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	if want, have := `attachment; filename=download.zip`, w.Header().Get("Content-Disposition"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "download/, download/docs/, download/docs/a.txt, download/docs/b.txt", entries(w); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	r := httptest.NewRequest("GET", "http://example.com/?download=zip", nil)
+	r.SetBasicAuth("bob", "secret")
+	if want, have := "download/, download/docs/, download/docs/a.txt, download/docs/b.txt, download/team/, download/team/c.txt", entries(serve(r)); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	// selected files
+	form := url.Values{"download": {"zip"}, "path": {"b.txt", "../.env"}}
+	r = httptest.NewRequest("POST", "http://example.com/docs/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(r)
+	if want, have := `attachment; filename=docs.zip`, w.Header().Get("Content-Disposition"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "docs/b.txt", entries(w); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	r = httptest.NewRequest("POST", "http://example.com/docs/?download=tar.gz", strings.NewReader(`{"paths":["a.txt"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	w = serve(r)
+	if want, have := "application/gzip", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// errors
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/docs/?download=rar", http.StatusBadRequest},
+		{"/missing/?download=zip", http.StatusNotFound},
+		{"/team/?download=zip", http.StatusUnauthorized},
+		{"/docs/a.txt?download=zip", http.StatusOK},
+	}
+	for _, test := range tests {
+		if want, have := test.code, serve(httptest.NewRequest("GET", "http://example.com"+test.path, nil)).Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+	}
+}
+
+func TestFileServerArchiveDirs(t *testing.T) {
+
+	root := t.TempDir()
+
+	// release.tar.gz with a binary and notes
+	f, _ := os.Create(filepath.Join(root, "release.tar.gz"))
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for name, content := range map[string]string{"bin/tool": "binary", "notes.txt": "notes"} {
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gw.Close()
+	f.Close()
+
+	// site.zip with an index page
+	f, _ = os.Create(filepath.Join(root, "site.zip"))
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("index.html")
+	w.Write([]byte("<h1>site</h1>"))
+	zw.Close()
+	f.Close()
+
+	th := server.FileServer(vfs.Dir(root))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return w
+	}
+
+	tests := []struct {
+		path        string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"/release.tar.gz/", http.StatusOK, "text/html; charset=utf-8", ""},
+		{"/release.tar.gz/bin/", http.StatusOK, "text/html; charset=utf-8", ""},
+		{"/release.tar.gz/notes.txt", http.StatusOK, "text/plain; charset=utf-8", "notes"},
+		{"/release.tar.gz/missing.txt", http.StatusNotFound, "", ""},
+		{"/site.zip/", http.StatusOK, "text/html; charset=utf-8", "<h1>site</h1>"},
+	}
+	for _, test := range tests {
+		w := serve(test.path)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+			continue
+		}
+		if want, have := test.contentType, w.Header().Get("Content-Type"); test.contentType != "" && want != have {
+			t.Errorf("%s: expected content type %#v, got %#v", test.path, want, have)
+		}
+		if want, have := test.body, w.Body.String(); test.body != "" && want != have {
+			t.Errorf("%s: expected %#v, got %#v", test.path, want, have)
+		}
+	}
+
+	// the archive itself is a file
+	if want, have := "\x1f\x8b", serve("/release.tar.gz").Body.String()[:2]; want != have {
+		t.Errorf("expected gzip content, got %#v", have)
+	}
+
+	// archives of archives
+	b := serve("/release.tar.gz/?download=zip").Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 4, len(zr.File); want != have {
+		t.Errorf("expected %d entries, got %d", want, have)
+	}
+
+	// API
+	var resp struct {
+		Items []api.FileInfo `json:"items"`
+	}
+	if err := json.NewDecoder(serve("/_goserve/api/lists/release.tar.gz?sort=name").Body).Decode(&resp); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	names := make([]string, len(resp.Items))
+	for i, item := range resp.Items {
+		names[i] = item.Path
+	}
+	if want, have := "release.tar.gz/bin, release.tar.gz/notes.txt", strings.Join(names, ", "); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	body := serve("/_goserve/api/graphql?query=" + url.QueryEscape(`{stat(path:"/release.tar.gz"){type children{name}}}`)).Body.String()
+	if want, have := `{"data":{"stat":{"children":[{"name":"bin"},{"name":"notes.txt"}],"type":"file"}}}`, strings.TrimSpace(body); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	// disabled
+	th = server.FileServer(vfs.Dir(root), server.WithArchiveDirs(false))
+	if want, have := http.StatusNotFound, serve("/release.tar.gz/notes.txt").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestFileServerMarkdown(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "docs"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "README.md"), []byte("# Project\n\nSee [the guide](docs/guide.md)."), 0644)
+	ioutil.WriteFile(filepath.Join(root, "docs", "guide.md"), []byte("## Usage\n\n<b>raw</b>"), 0644)
+
+	serve := func(th http.Handler, path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	th := server.FileServer(vfs.Dir(root))
+	tests := []struct {
+		path     string
+		accept   string
+		rendered bool
+		contains string
+	}{
+		{"/docs/guide.md", "text/html,*/*", true, `<h2 id="usage"><a href="#usage" class="anchor">#</a>Usage</h2>`},
+		{"/docs/guide.md", "", false, "<b>raw</b>"},
+		{"/docs/guide.md?mode=raw", "text/html,*/*", false, "<b>raw</b>"},
+		{"/docs/guide.md?mode=markdown", "", true, "<!-- raw HTML omitted -->"},
+		{"/", "text/html,*/*", true, `<a href="/docs/guide.md">the guide</a>`},
+	}
+	for _, test := range tests {
+		w := serve(th, test.path, test.accept)
+		if want, have := http.StatusOK, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+			continue
+		}
+		// the type of raw files depends on the system MIME types
+		if want, have := test.rendered, w.Header().Get("Content-Type") == "text/html; charset=utf-8"; want != have {
+			t.Errorf("%s: expected rendered %t, got content type %#v", test.path, want, w.Header().Get("Content-Type"))
+		}
+		if body := w.Body.String(); !strings.Contains(body, test.contains) {
+			t.Errorf("%s: expected %#v in body:\n%s", test.path, test.contains, body)
+		}
+	}
+	if want, have := "Accept", serve(th, "/docs/guide.md", "").Header().Get("Vary"); want != have {
+		t.Errorf("expected Vary %#v, got %#v", want, have)
+	}
+
+	// disabled
+	th = server.FileServer(vfs.Dir(root), server.WithMarkdown(false))
+	if body := serve(th, "/docs/guide.md", "text/html,*/*").Body.String(); !strings.Contains(body, "<b>raw</b>") {
+		t.Errorf("expected raw Markdown, got %s", body)
+	}
+	if body := serve(th, "/", "text/html,*/*").Body.String(); strings.Contains(body, "the guide") {
+		t.Errorf("unexpected README in listing: %s", body)
+	}
+}
+
+func TestFileServerSource(t *testing.T) {
+
+	root := t.TempDir()
+	ioutil.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "run"), []byte("#!/bin/sh\necho <hi>\n"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "blob.bin"), []byte("\x00\x01\x02"), 0644)
+
+	serve := func(th http.Handler, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return w
+	}
+
+	th := server.FileServer(vfs.Dir(root))
+	tests := []struct {
+		path     string
+		code     int
+		contains string
+	}{
+		{"/main.go?mode=source", http.StatusOK, `<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`},
+		{"/run?mode=source", http.StatusOK, `<span class="nb">echo</span>`},
+		{"/run?mode=source", http.StatusOK, `&lt;hi&gt;`},
+		{"/blob.bin?mode=source", http.StatusOK, "\x00\x01\x02"},
+		{"/missing.go?mode=source", http.StatusNotFound, ""},
+		{"/main.go", http.StatusOK, "package main\n"},
+	}
+	for _, test := range tests {
+		w := serve(th, test.path)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+			continue
+		}
+		if body := w.Body.String(); !strings.Contains(body, test.contains) {
+			t.Errorf("%s: expected %#v in body:\n%s", test.path, test.contains, body)
+		}
+	}
+
+	// links in listings
+	link := `<a href="main.go?mode=source">main.go</a>`
+	if body := serve(th, "/").Body.String(); strings.Contains(body, link) {
+		t.Errorf("unexpected source link in listing")
+	}
+	th = server.FileServer(vfs.Dir(root), server.WithSourceLinks(true))
+	if body := serve(th, "/").Body.String(); !strings.Contains(body, link) {
+		t.Errorf("expected %s in listing:\n%s", link, body)
+	}
+}
+
+func TestFileServerThumbnails(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "shots"), 0755)
+	for _, name := range []string{"shots/a b.png", "shots/c.gif"} {
+		f, _ := os.Create(filepath.Join(root, name))
+		img := image.NewRGBA(image.Rect(0, 0, 600, 300))
+		if strings.HasSuffix(name, ".gif") {
+			gif.Encode(f, img, nil)
+		} else {
+			png.Encode(f, img)
+		}
+		f.Close()
+	}
+	ioutil.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes"), 0644)
+
+	serve := func(th http.Handler, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return w
+	}
+
+	th := server.FileServer(vfs.Dir(root), server.WithThumbnails(thumb.New(thumb.Options{})))
+	tests := []struct {
+		path     string
+		code     int
+		contains string
+	}{
+		{"/shots/?mode=gallery", http.StatusOK, `<img src="/_goserve/thumb/shots/a%20b.png?w=256" srcset="/_goserve/thumb/shots/a%20b.png?w=512 2x"`},
+		{"/shots/?mode=gallery", http.StatusOK, `<a href="/shots/c.gif">`},
+		{"/?mode=gallery", http.StatusOK, "No images"},
+		{"/notes.txt?mode=gallery", http.StatusNotFound, ""},
+		{"/shots/", http.StatusOK, `<a href="?mode=gallery">`},
+		{"/_goserve/thumb/shots/c.gif?w=64", http.StatusOK, "\x89PNG"},
+		{"/_goserve/thumb/notes.txt", http.StatusNotFound, ""},
+		{"/_goserve/api/graphql?query=" + url.QueryEscape(`{stat(path:"/shots/c.gif"){thumbnailUrl(width:100)}}`), http.StatusOK, `"thumbnailUrl":"/_goserve/thumb/shots/c.gif?w=128"`},
+		{"/_goserve/api/graphql?query=" + url.QueryEscape(`{stat(path:"/notes.txt"){thumbnailUrl}}`), http.StatusOK, `"thumbnailUrl":null`},
+	}
+	for _, test := range tests {
+		w := serve(th, test.path)
+		if want, have := test.code, w.Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+			continue
+		}
+		if body := w.Body.String(); !strings.Contains(body, test.contains) {
+			t.Errorf("%s: expected %#v in body:\n%s", test.path, test.contains, body)
+		}
+	}
+	if body := serve(th, "/").Body.String(); strings.Contains(body, "?mode=gallery") {
+		t.Errorf("unexpected gallery link in listing without images")
+	}
+
+	// disabled
+	th = server.FileServer(vfs.Dir(root))
+	if want, have := http.StatusNotFound, serve(th, "/_goserve/thumb/shots/c.gif").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if body := serve(th, "/shots/").Body.String(); strings.Contains(body, "?mode=gallery") {
+		t.Errorf("unexpected gallery link in listing")
+	}
+	body := serve(th, "/_goserve/api/graphql?query="+url.QueryEscape(`{stat(path:"/shots/c.gif"){thumbnailUrl}}`)).Body.String()
+	if !strings.Contains(body, `"thumbnailUrl":null`) {
+		t.Errorf("unexpected thumbnail URL: %s", body)
+	}
+}
+
+func TestFileServerAudio(t *testing.T) {
+
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "album"), 0755)
+	for _, name := range []string{"album/01 intro.mp3", "album/02.ogg", "album/cover.txt", "album/03.FLAC", "album/clip.webm"} {
+		ioutil.WriteFile(filepath.Join(root, name), []byte("media"), 0644)
+	}
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.FileServer(vfs.Dir(root)).ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return w
+	}
+
+	// player queued with the other audio files
+	w := serve("/album/02.ogg?mode=audioplayer&sort=name")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`<audio controls autoplay preload="metadata" src="/album/02.ogg">`,
+		`<li><a href="/album/01%20intro.mp3?mode=audioplayer&amp;sort=name" data-src="/album/01%20intro.mp3">01 intro.mp3</a></li>`,
+		`<li class="current"><a href="/album/02.ogg?mode=audioplayer&amp;sort=name" data-src="/album/02.ogg">02.ogg</a></li>`,
+		`<li><a href="/album/03.FLAC?mode=audioplayer&amp;sort=name" data-src="/album/03.FLAC">03.FLAC</a></li>`,
+		`<a class="prev" href="./?sort=name">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in body:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "cover.txt") || strings.Contains(body, "clip.webm") {
+		t.Errorf("unexpected files in queue:\n%s", body)
+	}
+	if want, have := http.StatusNotFound, serve("/album/missing.mp3?mode=audioplayer").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := http.StatusNotFound, serve("/album/cover.txt?mode=audioplayer").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// links in listings
+	body = serve("/album/?sort=name").Body.String()
+	for _, want := range []string{
+		`<a href="02.ogg?mode=audioplayer&amp;sort=name">02.ogg</a>`,
+		`<a href="?download=m3u8">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in listing:\n%s", want, body)
+		}
+	}
+
+	// playlist of the audio and video files
+	w = serve("/album/?download=m3u8&sort=name")
+	if want, have := `attachment; filename=album.m3u8`, w.Header().Get("Content-Disposition"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	want := "#EXTM3U\n" +
+		"#PLAYLIST:album\n" +
+		"#EXTINF:-1,01 intro\nhttp://example.com/album/01%20intro.mp3\n" +
+		"#EXTINF:-1,02\nhttp://example.com/album/02.ogg\n" +
+		"#EXTINF:-1,03\nhttp://example.com/album/03.FLAC\n" +
+		"#EXTINF:-1,clip\nhttp://example.com/album/clip.webm\n"
+	if have := w.Body.String(); want != have {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, have)
+	}
+	if body := serve("/?download=m3u8").Body.String(); body != "#EXTM3U\n#PLAYLIST:playlist\n" {
+		t.Errorf("unexpected playlist %#v", body)
+	}
+
+	// behind a reverse proxy terminating TLS
+	r := httptest.NewRequest("GET", "http://example.com/album/?download=m3u8&sort=name", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	server.FileServer(vfs.Dir(root)).ServeHTTP(w, r)
+	if want := "\nhttps://example.com/album/02.ogg\n"; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %#v in playlist:\n%s", want, w.Body.String())
+	}
+
+	// through a share link, with its token
+	sharer := share.New([]byte("0123456789abcdef"))
+	link, _ := sharer.Sign("/album", time.Hour, 0, "")
+	th := server.FileServer(vfs.Dir(root),
+		server.WithAuth(auth.Scope{Prefix: "/", Users: auth.Users{"alice": "secret"}}),
+		server.WithShare(sharer),
+	)
+	w = httptest.NewRecorder()
+	th.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/album/?download=m3u8&sort=name&share="+link.Token, nil))
+	if want := "\nhttp://example.com/album/02.ogg?share=" + url.QueryEscape(link.Token) + "\n"; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("expected %#v in playlist:\n%s", want, w.Body.String())
+	}
+}
+
+func TestFileServerVideo(t *testing.T) {
+
+	// WebM of a VP9 track, up to its media data
+	webm := []byte("\x1A\x45\xDF\xA3\x87\x42\x82\x84webm" +
+		"\x18\x53\x80\x67\x01\xFF\xFF\xFF\xFF\xFF\xFF\xFF" +
+		"\x16\x54\xAE\x6B\x8C\xAE\x8A\x83\x81\x01\x86\x85V_VP9")
+
+	root := t.TempDir()
+	ioutil.WriteFile(filepath.Join(root, "clip.mkv"), webm, 0644)
+	ioutil.WriteFile(filepath.Join(root, "broken.mp4"), []byte("not a video"), 0644)
+
+	serve := func(path string) string {
+		w := httptest.NewRecorder()
+		server.FileServer(vfs.Dir(root)).ServeHTTP(w, httptest.NewRequest("GET", "http://example.com"+path, nil))
+		return w.Body.String()
+	}
+
+	body := serve("/clip.mkv?mode=videoplayer")
+	if want := `<source src="/clip.mkv" type="video/webm; codecs=vp9"`; !strings.Contains(body, want) {
+		t.Errorf("expected %s in body:\n%s", want, body)
+	}
+	if want := `<p class="video-fallback" hidden>`; !strings.Contains(body, want) {
+		t.Errorf("expected %s in body:\n%s", want, body)
+	}
+
+	// download unknown formats
+	body = serve("/broken.mp4?mode=videoplayer")
+	if strings.Contains(body, "<video") {
+		t.Errorf("unexpected player in body:\n%s", body)
+	}
+	if want := `<p class="video-fallback">`; !strings.Contains(body, want) {
+		t.Errorf("expected %s in body:\n%s", want, body)
+	}
+
+	// links in listings
+	if want, body := `<a href="clip.mkv?mode=videoplayer">`, serve("/"); !strings.Contains(body, want) {
+		t.Errorf("expected %s in listing:\n%s", want, body)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/netlify"
+	"github.com/go-serve/goserve/server/share"
+)
+
+// cookies are named with netlify.CookiePrefix, not to be
+// sent to other hosts by external rewrites
+const (
+	shareCookie      = netlify.CookiePrefix + "share"
+	shareProofCookie = netlify.CookiePrefix + "share_proof"
+	shareRealm       = "goserve shared link"
+)
+
+// contextKey is the type of the keys of request context values
+type contextKey int
+
+const (
+	// ctxKeyShareToken is the token of the share link
+	// a request is served through
+	ctxKeyShareToken contextKey = iota
+)
+
+// shareToken returns the token of the share link the
+// request is served through, or "" if none
+func shareToken(r *http.Request) string {
+	token, _ := r.Context().Value(ctxKeyShareToken).(string)
+	return token
+}
+
+// ServeShares generates a middleware that serves requests with a valid
+// share token, in the "share" query parameter or the cookie it sets,
+// by the handler returned by shared for the shared path. Other requests
+// are deferred to the inner handler. A cookie never restricts access:
+// requests outside the shared tree with a share cookie are also
+// deferred to the inner handler.
+func ServeShares(sharer *share.Sharer, root http.FileSystem, shared func(prefix string) http.Handler) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			token, fromQuery := r.URL.Query().Get(share.QueryParam), true
+			if token == "" {
+				if c, err := r.Cookie(shareCookie); err == nil {
+					token, fromQuery = c.Value, false
+				}
+			}
+			if token == "" {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			link, err := sharer.Verify(token)
+			if err == nil && !strings.HasPrefix(r.URL.Path, "/_goserve/") && !link.Contains(r.URL.Path) {
+				err = share.ErrInvalid
+			}
+			if err != nil {
+				switch {
+				case !fromQuery:
+					inner.ServeHTTP(w, r)
+				case err == share.ErrExpired:
+					http.Error(w, "Share link expired", http.StatusGone)
+				default:
+					http.Error(w, "Invalid share link", http.StatusForbidden)
+				}
+				return
+			}
+
+			// password protected links
+			if link.HasPassword() {
+				c, err := r.Cookie(shareProofCookie)
+				if err != nil || !sharer.CheckProof(link, c.Value) {
+					_, password, ok := r.BasicAuth()
+					if !ok || !sharer.CheckPassword(link, password) {
+						w.Header().Set("WWW-Authenticate", `Basic realm="`+shareRealm+`", charset="UTF-8"`)
+						http.Error(w, "Password required", http.StatusUnauthorized)
+						return
+					}
+					http.SetCookie(w, shareCookieOf(shareProofCookie, sharer.Proof(link), link, r))
+				}
+			}
+
+			// count downloads of files
+			if link.MaxDownloads > 0 && isDownload(root, r) {
+				if err := sharer.Download(link); err == share.ErrExhausted {
+					http.Error(w, "Share link download limit reached", http.StatusGone)
+					return
+				} else if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			if fromQuery {
+				http.SetCookie(w, shareCookieOf(shareCookie, token, link, r))
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ctxKeyShareToken, token))
+			shared(link.Path).ServeHTTP(w, r)
+		})
+	}
+}
+
+// shareCookieOf returns a cookie that lasts as long as the link
+func shareCookieOf(name, value string, link share.Link, r *http.Request) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  link.Expires,
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// isDownload reports if the request fetches the beginning of a
+// regular file. Later parts of ranged requests, e.g. seeking in a
+// video, do not count as another download.
+func isDownload(root http.FileSystem, r *http.Request) bool {
+	if r.Method != "GET" || strings.HasPrefix(r.URL.Path, "/_goserve/") || r.URL.Query().Get("mode") != "" {
+		return false
+	}
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=0-") {
+		return false
+	}
+	f, err := root.Open(r.URL.Path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// sharedHandlers caches the file server handlers of shared paths
+type sharedHandlers struct {
+	mutex    sync.Mutex
+	handlers map[string]http.Handler
+	build    func(prefix string) http.Handler
+}
+
+// get returns the handler of the shared path
+func (s *sharedHandlers) get(prefix string) http.Handler {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]http.Handler)
+	}
+	if h, ok := s.handlers[prefix]; ok {
+		return h
+	}
+	h := s.build(prefix)
+	s.handlers[prefix] = h
+	return h
+}
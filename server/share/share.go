@@ -0,0 +1,248 @@
+// Package share mints and verifies expiring HMAC-signed links
+// that grant access to a single file or directory tree
+package share
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errors of link verification
+var (
+	ErrInvalid   = errors.New("share: invalid link")
+	ErrExpired   = errors.New("share: link expired")
+	ErrExhausted = errors.New("share: download limit reached")
+)
+
+// QueryParam is the URL query parameter carrying the token
+const QueryParam = "share"
+
+// Link is the verified content of a share token
+type Link struct {
+	Token        string    `json:"-"`
+	ID           string    `json:"i"`
+	Path         string    `json:"p"`
+	Expires      time.Time `json:"-"`
+	ExpiresUnix  int64     `json:"e"`
+	MaxDownloads int       `json:"n,omitempty"`
+	Verifier     string    `json:"w,omitempty"`
+}
+
+// HasPassword reports if the link requires a password
+func (link Link) HasPassword() bool {
+	return link.Verifier != ""
+}
+
+// Contains reports if the URL path is inside the shared tree
+func (link Link) Contains(p string) bool {
+	p = path.Clean("/" + p)
+	return link.Path == "/" || p == link.Path || strings.HasPrefix(p, link.Path+"/")
+}
+
+// URL returns the share URL on the given base URL
+// (e.g. "http://localhost:8080")
+func (link Link) URL(base string) string {
+	u := strings.TrimRight(base, "/") + (&url.URL{Path: link.Path}).EscapedPath()
+	return u + "?" + QueryParam + "=" + link.Token
+}
+
+// Sharer signs and verifies share links with a secret key.
+// It also counts downloads of links with a download limit.
+type Sharer struct {
+	key []byte
+
+	mutex     sync.Mutex
+	downloads map[string]int
+	stateFile string
+}
+
+// New creates a Sharer with the secret key
+func New(key []byte) *Sharer {
+	return &Sharer{
+		key:       key,
+		downloads: make(map[string]int),
+	}
+}
+
+// DefaultKeyFile returns the default path of the secret key file,
+// shared by the "goserve share" command and the server
+func DefaultKeyFile() (filename string, err error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return
+	}
+	filename = filepath.Join(dir, "goserve", "share.key")
+	return
+}
+
+// LoadKey reads the secret key from file. A random key
+// is generated and saved if the file does not exist.
+func LoadKey(filename string) (key []byte, err error) {
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err = rand.Read(key); err != nil {
+			return
+		}
+		if err = os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			return
+		}
+		err = ioutil.WriteFile(filename, []byte(hex.EncodeToString(key)+"\n"), 0600)
+		return
+	}
+	if err != nil {
+		return
+	}
+	if key, err = hex.DecodeString(strings.TrimSpace(string(b))); err != nil || len(key) < 16 {
+		err = fmt.Errorf("share: invalid key in %s", filename)
+	}
+	return
+}
+
+// SetStateFile persists download counts to the file,
+// and loads existing counts from it
+func (s *Sharer) SetStateFile(filename string) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stateFile = filename
+	b, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	return json.Unmarshal(b, &s.downloads)
+}
+
+// Sign mints a share link of the URL path valid for ttl. A positive
+// maxDownloads limits the number of downloads. A non-empty password
+// is required to use the link.
+func (s *Sharer) Sign(p string, ttl time.Duration, maxDownloads int, password string) (link Link, err error) {
+
+	id := make([]byte, 9)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+
+	link = Link{
+		ID:           base64.RawURLEncoding.EncodeToString(id),
+		Path:         path.Clean("/" + p),
+		Expires:      time.Now().Add(ttl).Truncate(time.Second),
+		MaxDownloads: maxDownloads,
+	}
+	link.ExpiresUnix = link.Expires.Unix()
+	if password != "" {
+		link.Verifier = s.passwordVerifier(link.ID, password)
+	}
+
+	payload, err := json.Marshal(link)
+	if err != nil {
+		return
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	link.Token = encoded + "." + base64.RawURLEncoding.EncodeToString(s.mac("link", encoded))
+	return
+}
+
+// Verify checks the signature and expiry of a token
+func (s *Sharer) Verify(token string) (link Link, err error) {
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		err = ErrInvalid
+		return
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.mac("link", parts[0])) {
+		err = ErrInvalid
+		return
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		err = ErrInvalid
+		return
+	}
+	if err = json.Unmarshal(payload, &link); err != nil {
+		err = ErrInvalid
+		return
+	}
+
+	link.Token = token
+	link.Expires = time.Unix(link.ExpiresUnix, 0)
+	if time.Now().After(link.Expires) {
+		err = ErrExpired
+	}
+	return
+}
+
+// CheckPassword reports if the password is correct for the link
+func (s *Sharer) CheckPassword(link Link, password string) bool {
+	if !link.HasPassword() {
+		return true
+	}
+	return hmac.Equal([]byte(link.Verifier), []byte(s.passwordVerifier(link.ID, password)))
+}
+
+// Proof returns a value to prove that the password of a link has
+// been verified, for storing in cookie
+func (s *Sharer) Proof(link Link) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac("proof", link.ID))
+}
+
+// CheckProof verifies a value returned by Proof
+func (s *Sharer) CheckProof(link Link, proof string) bool {
+	return hmac.Equal([]byte(proof), []byte(s.Proof(link)))
+}
+
+// Download counts a download of the link. It returns ErrExhausted
+// if the download limit of the link is already reached.
+func (s *Sharer) Download(link Link) (err error) {
+	if link.MaxDownloads <= 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.downloads[link.ID] >= link.MaxDownloads {
+		return ErrExhausted
+	}
+	s.downloads[link.ID]++
+
+	if s.stateFile != "" {
+		b, _ := json.Marshal(s.downloads)
+		err = ioutil.WriteFile(s.stateFile, b, 0600)
+	}
+	return
+}
+
+// Downloads returns the number of downloads counted for the link
+func (s *Sharer) Downloads(link Link) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.downloads[link.ID]
+}
+
+func (s *Sharer) passwordVerifier(id, password string) string {
+	return base64.RawURLEncoding.EncodeToString(s.mac("password", id+":"+password)[:18])
+}
+
+func (s *Sharer) mac(purpose, message string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(purpose + "\x00" + message))
+	return h.Sum(nil)
+}
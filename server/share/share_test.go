@@ -0,0 +1,110 @@
+package share_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/share"
+)
+
+func TestSignVerify(t *testing.T) {
+
+	sharer := share.New([]byte("0123456789abcdef"))
+	link, err := sharer.Sign("/folder1/", time.Hour, 0, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	verified, err := sharer.Verify(link.Token)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "/folder1", verified.Path; want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if !verified.Contains("/folder1/foo1") || verified.Contains("/folder10") || verified.Contains("/") {
+		t.Errorf("unexpected shared tree of %#v", verified.Path)
+	}
+	if want, have := "http://localhost:8080/folder1?share="+link.Token, link.URL("http://localhost:8080/"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// tampered token
+	parts := strings.SplitN(link.Token, ".", 2)
+	other, _ := sharer.Sign("/", time.Hour, 0, "")
+	if _, err := sharer.Verify(strings.SplitN(other.Token, ".", 2)[0] + "." + parts[1]); err != share.ErrInvalid {
+		t.Errorf("expected ErrInvalid, got %#v", err)
+	}
+
+	// signed by another key
+	if _, err := share.New([]byte("fedcba9876543210")).Verify(link.Token); err != share.ErrInvalid {
+		t.Errorf("expected ErrInvalid, got %#v", err)
+	}
+
+	// expired
+	expired, _ := sharer.Sign("/", -time.Minute, 0, "")
+	if _, err := sharer.Verify(expired.Token); err != share.ErrExpired {
+		t.Errorf("expected ErrExpired, got %#v", err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	sharer := share.New([]byte("0123456789abcdef"))
+	link, _ := sharer.Sign("/", time.Hour, 0, "secret")
+	if !link.HasPassword() {
+		t.Fatalf("expected link to require password")
+	}
+	if sharer.CheckPassword(link, "wrong") {
+		t.Errorf("wrong password accepted")
+	}
+	if !sharer.CheckPassword(link, "secret") {
+		t.Errorf("password rejected")
+	}
+	if !sharer.CheckProof(link, sharer.Proof(link)) {
+		t.Errorf("proof rejected")
+	}
+}
+
+func TestDownload(t *testing.T) {
+
+	stateFile := filepath.Join(t.TempDir(), "downloads.json")
+	sharer := share.New([]byte("0123456789abcdef"))
+	if err := sharer.SetStateFile(stateFile); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	link, _ := sharer.Sign("/folder1/foo1", time.Hour, 2, "")
+	for i := 0; i < 2; i++ {
+		if err := sharer.Download(link); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+	if want, have := share.ErrExhausted, sharer.Download(link); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// counts survive restart
+	restarted := share.New([]byte("0123456789abcdef"))
+	if err := restarted.SetStateFile(stateFile); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := 2, restarted.Downloads(link); want != have {
+		t.Errorf("expected %d downloads, got %d", want, have)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "goserve", "share.key")
+	key, err := share.LoadKey(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	loaded, err := share.LoadKey(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if string(key) != string(loaded) {
+		t.Errorf("expected the generated key to be reused")
+	}
+}
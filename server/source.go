@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/go-serve/goserve/assets"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/source"
+)
+
+var tplSource *template.Template
+var sourceCSS template.CSS
+
+func init() {
+
+	fs := assets.FileSystem()
+	fh, err := fs.Open("/html/source.html")
+	if err != nil {
+		log.Print("Failed to load template")
+		panic(err)
+	}
+
+	b, err := ioutil.ReadAll(fh)
+	if err != nil {
+		log.Print("Failed to read template file")
+		panic(err)
+	}
+
+	tplSource, err = template.New("source.html").Parse(string(b))
+	if err != nil {
+		log.Print("Failed to parse source.html into template")
+		panic(err)
+	}
+
+	var css bytes.Buffer
+	if err := source.WriteCSS(&css); err != nil {
+		log.Print("Failed to write the stylesheet of highlighted source")
+		panic(err)
+	}
+	sourceCSS = template.CSS(css.String())
+}
+
+// ServeSource displays text files with syntax highlighting and
+// line numbers for "?mode=source". Binary files are served as they are.
+func ServeSource(root http.FileSystem) midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if !wantsSource(r) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			file, err := root.Open(r.URL.Path)
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			defer file.Close()
+			stat, err := file.Stat()
+			if err != nil {
+				serveError(w, r, root, errorStatus(err))
+				return
+			} else if !stat.Mode().IsRegular() {
+				serveError(w, r, root, http.StatusNotFound)
+				return
+			}
+
+			// read a byte more than the limit to tell if it is cut
+			content, err := ioutil.ReadAll(io.LimitReader(file, source.MaxSize+1))
+			if err != nil {
+				log.Printf("Error reading %#v: %s", r.URL.Path, err)
+				serveError(w, r, root, errorStatus(err))
+				return
+			}
+			if source.IsBinary(content) {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			content, truncated := source.Cut(content)
+
+			language := source.Language(stat.Name(), content)
+			var highlighted bytes.Buffer
+			if err := source.Highlight(&highlighted, language, content); err != nil {
+				log.Printf("Error highlighting %#v: %s", r.URL.Path, err)
+				serveError(w, r, root, http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if r.Method == http.MethodHead {
+				return
+			}
+			err = tplSource.Execute(w, map[string]interface{}{
+				"Name":        stat.Name(),
+				"Raw":         (&url.URL{Path: stat.Name()}).String(),
+				"Language":    language,
+				"Content":     template.HTML(highlighted.String()),
+				"Truncated":   truncated,
+				"Lines":       bytes.Count(content, []byte("\n")),
+				"CSS":         sourceCSS,
+				"Stylesheets": stylesheets,
+			})
+			if err != nil {
+				log.Printf("error executing template source.html: %s", err.Error())
+			}
+		})
+	}
+}
+
+// wantsSource reports if the request is for the source view
+func wantsSource(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return r.URL.Query().Get("mode") == "source"
+}
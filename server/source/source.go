@@ -0,0 +1,159 @@
+// Package source highlights source files as HTML, with
+// line numbers that link to themselves
+package source
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// MaxSize is the size in bytes of the largest file to highlight.
+// Larger files are cut at the last line before it.
+const MaxSize = 1 << 20
+
+// LinePrefix prefixes the line numbers in the IDs of lines,
+// as in "#L10"
+const LinePrefix = "L"
+
+var style = styles.Get("github")
+
+var formatter = chromahtml.New(
+	chromahtml.WithClasses(true),
+	chromahtml.WithLineNumbers(true),
+	chromahtml.WithLinkableLineNumbers(true, LinePrefix),
+	chromahtml.WrapLongLines(true),
+	chromahtml.TabWidth(4),
+)
+
+// documents are the extensions of files viewed as they are,
+// rather than as source, in listings
+var documents = map[string]bool{
+	".htm":      true,
+	".html":     true,
+	".xhtml":    true,
+	".svg":      true,
+	".md":       true,
+	".markdown": true,
+	".txt":      true,
+}
+
+// IsSource reports if the file name is the one of a
+// source file in a known language
+func IsSource(name string) bool {
+	if documents[strings.ToLower(path.Ext(name))] {
+		return false
+	}
+	return match(name) != nil
+}
+
+// match returns the lexer of the file name, ignoring
+// the case of the name as a fallback
+func match(name string) chroma.Lexer {
+	name = path.Base(name)
+	if l := lexers.Match(name); l != nil {
+		return l
+	}
+	return lexers.Match(strings.ToLower(name))
+}
+
+// IsBinary reports if the content looks binary rather than text
+func IsBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// Cut cuts the content at the last line ending before MaxSize,
+// and reports if it did
+func Cut(content []byte) ([]byte, bool) {
+	if len(content) <= MaxSize {
+		return content, false
+	}
+	content = content[:MaxSize]
+	if i := bytes.LastIndexByte(content, '\n'); i >= 0 {
+		content = content[:i+1]
+	}
+	return content, true
+}
+
+// Language returns the name of the language of the file, picked by
+// its name, then its shebang line, then its content
+func Language(name string, content []byte) string {
+	return lexer(name, content).Config().Name
+}
+
+func lexer(name string, content []byte) chroma.Lexer {
+	if l := match(name); l != nil {
+		return l
+	}
+	if l := shebang(content); l != nil {
+		return l
+	}
+	if l := lexers.Analyse(string(content)); l != nil {
+		return l
+	}
+	return lexers.Fallback
+}
+
+// shebang returns the lexer of the interpreter in the
+// "#!" line of a script, if any
+func shebang(content []byte) chroma.Lexer {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return nil
+	}
+	line := string(content[2:])
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// skip options, as in "env -S deno run"
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+	switch interpreter = strings.TrimRight(interpreter, "0123456789."); interpreter {
+	case "":
+		return nil
+	case "node", "deno":
+		interpreter = "javascript"
+	case "sh", "dash", "ash", "ksh", "zsh":
+		interpreter = "bash"
+	}
+	return lexers.Get(interpreter)
+}
+
+// Highlight writes the content as highlighted HTML in the named
+// language, as returned by Language, with a link to each line
+func Highlight(w io.Writer, language string, content []byte) error {
+	l := lexers.Get(language)
+	if l == nil {
+		l = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(l).Tokenise(nil, string(content))
+	if err != nil {
+		return err
+	}
+	return formatter.Format(w, style, iterator)
+}
+
+// WriteCSS writes the stylesheet of highlighted HTML
+func WriteCSS(w io.Writer) error {
+	return formatter.WriteCSS(w, style)
+}
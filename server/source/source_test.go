@@ -0,0 +1,81 @@
+package source_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/source"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"main.go", "package main\n", "Go"},
+		{"Makefile", "all:\n", "Makefile"},
+		{"deploy", "#!/usr/bin/env python3\nprint(1)\n", "Python"},
+		{"build", "#!/bin/sh\necho hi\n", "Bash"},
+		{"serve", "#!/usr/bin/env -S node --harmony\n", "JavaScript"},
+		{"notes", "hello", "fallback"},
+	}
+	for _, test := range tests {
+		if have := source.Language(test.name, []byte(test.content)); test.want != have {
+			t.Errorf("%s: expected %#v, got %#v", test.name, test.want, have)
+		}
+	}
+}
+
+func TestIsSource(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"main.go", true},
+		{"app.JS", true},
+		{"Dockerfile", true},
+		{"index.html", false},
+		{"README.md", false},
+		{"movie.mp4", false},
+	}
+	for _, test := range tests {
+		if want, have := test.ok, source.IsSource(test.name); want != have {
+			t.Errorf("%s: expected %v, got %v", test.name, want, have)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var buf bytes.Buffer
+	if err := source.Highlight(&buf, "Go", []byte("package main\n\nfunc main() {}\n")); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	for _, want := range []string{
+		`<span class="ln" id="L3"><a class="lnlinks" href="#L3">3</a></span>`,
+		`<span class="kd">func</span>`,
+	} {
+		if have := buf.String(); !strings.Contains(have, want) {
+			t.Errorf("expected %s in:\n%s", want, have)
+		}
+	}
+}
+
+func TestCut(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	content := []byte(strings.Repeat(line, source.MaxSize/len(line)+10))
+	cut, truncated := source.Cut(content)
+	if !truncated {
+		t.Errorf("expected the content to be cut")
+	}
+	if want, have := source.MaxSize/len(line)*len(line), len(cut); want != have {
+		t.Errorf("expected %d bytes, got %d", want, have)
+	}
+	if _, truncated := source.Cut([]byte(line)); truncated {
+		t.Errorf("unexpected cut")
+	}
+	if !source.IsBinary([]byte("PK\x03\x04\x00")) || source.IsBinary([]byte(line)) {
+		t.Errorf("unexpected binary detection")
+	}
+}
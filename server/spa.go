@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// SPA configures the single page application mode, in which unknown
+// paths under Root are served the Fallback page, so the application
+// can route them in browser. Directory listings are disabled under Root.
+type SPA struct {
+	// Root is the URL path of the application. Defaults to "/".
+	Root string
+
+	// Fallback is the page to serve for unknown paths.
+	// Defaults to "index.html" under Root.
+	Fallback string
+
+	// Exclude are URL path prefixes that are never served the
+	// Fallback page, e.g. "/api"
+	Exclude []string
+}
+
+// normalize fills in the defaults
+func (spa SPA) normalize() SPA {
+	spa.Root = path.Clean("/" + spa.Root)
+	if spa.Fallback == "" {
+		spa.Fallback = path.Join(spa.Root, "index.html")
+	}
+	spa.Fallback = path.Clean("/" + spa.Fallback)
+	exclude := make([]string, len(spa.Exclude))
+	for i, prefix := range spa.Exclude {
+		exclude[i] = path.Clean("/" + prefix)
+	}
+	spa.Exclude = exclude
+	return spa
+}
+
+// contains reports if the URL path is routed by the application
+func (spa SPA) contains(p string) bool {
+	if !hasPathPrefix(p, spa.Root) {
+		return false
+	}
+	for _, prefix := range spa.Exclude {
+		if hasPathPrefix(p, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPathPrefix reports if p is prefix or inside it
+func hasPathPrefix(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+// isAssetPath reports if the path looks like a request for
+// a file asset (e.g. "/js/app.js") rather than an application route
+func isAssetPath(p string) bool {
+	return path.Ext(path.Base(p)) != ""
+}
+
+// serveSPA serves the request in single page application mode.
+// It returns false if the request should be served as usual.
+func (fs *fileServer) serveSPA(w http.ResponseWriter, r *http.Request) bool {
+
+	spa := fs.spa
+	if spa == nil || !spa.contains(r.URL.Path) {
+		return false
+	}
+
+	f, err := fs.root.Open(r.URL.Path)
+	if err == nil {
+		stat, err := f.Stat()
+		f.Close()
+		if err != nil || !stat.IsDir() {
+			return false
+		}
+
+		// directory with index page is served as usual,
+		// others are routes of the application
+		if index, err := fs.ReadIndex(r.URL.Path); index != nil {
+			index.Close()
+			if err == nil {
+				return false
+			}
+		}
+	} else if isAssetPath(r.URL.Path) {
+		return false
+	}
+
+	fallback, err := fs.root.Open(spa.Fallback)
+	if err != nil {
+		return false
+	}
+	defer fallback.Close()
+	stat, err := fallback.Stat()
+	if err != nil || stat.IsDir() {
+		return false
+	}
+	http.ServeContent(w, r, spa.Fallback, stat.ModTime(), fallback)
+	return true
+}
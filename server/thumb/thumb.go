@@ -0,0 +1,361 @@
+// Package thumb generates thumbnails of JPEG, PNG and GIF images,
+// cached on disk
+package thumb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoder of GIF images
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-serve/goserve/server/exif"
+	"github.com/go-serve/goserve/server/imageinfo"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/vfs"
+	"golang.org/x/image/draw"
+)
+
+// DefaultWidth is the width of thumbnails when not requested
+const DefaultWidth = 256
+
+// Widths are the widths of thumbnails generated. Requested
+// widths are rounded up to one of them, to limit the cache.
+var Widths = []int{64, 128, 256, 512, 1024}
+
+// MaxPixels is the number of pixels of the largest image
+// to generate thumbnails of, about 40 megapixels. Decoding
+// takes 4 bytes per pixel, 160 MB at most.
+const MaxPixels = 40 << 20
+
+// ErrTooLarge is returned for images over MaxPixels
+var ErrTooLarge = errors.New("thumb: image too large")
+
+// IsImage reports if the file name has the extension
+// of an image to generate thumbnails of
+func IsImage(name string) bool {
+	return imageinfo.IsImage(name)
+}
+
+// Width returns the width of thumbnails generated for the
+// requested width, or the default width if w is not positive
+func Width(w int) int {
+	if w <= 0 {
+		return DefaultWidth
+	}
+	for _, width := range Widths {
+		if w <= width {
+			return width
+		}
+	}
+	return Widths[len(Widths)-1]
+}
+
+// URL returns the URL of the thumbnail of the image at
+// the URL path p, served under the prefix
+func URL(prefix, p string, width int) string {
+	u := &url.URL{
+		Path:     strings.TrimRight(prefix, "/") + path.Clean("/"+p),
+		RawQuery: "w=" + strconv.Itoa(width),
+	}
+	return u.String()
+}
+
+// DefaultCacheDir returns the default directory of cached
+// thumbnails, in the cache directory of the user
+func DefaultCacheDir() (dir string, err error) {
+	if dir, err = os.UserCacheDir(); err != nil {
+		return
+	}
+	dir = filepath.Join(dir, "goserve", "thumbs")
+	return
+}
+
+// Options of a Thumbnailer
+type Options struct {
+	// Cache is the directory to cache thumbnails in.
+	// Thumbnails are not cached if empty.
+	Cache string
+
+	// Concurrency is the number of thumbnails generated
+	// at once (default: 2)
+	Concurrency int
+}
+
+// Thumbnailer generates thumbnails of images of file systems
+type Thumbnailer struct {
+	cache string
+	slots chan struct{}
+}
+
+// New returns a Thumbnailer with the options
+func New(opts Options) *Thumbnailer {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 2
+	}
+	return &Thumbnailer{
+		cache: opts.Cache,
+		slots: make(chan struct{}, opts.Concurrency),
+	}
+}
+
+// Middleware generates a middleware that serves the thumbnails of
+// the images of root under the URL path prefix, e.g. for the prefix
+// "/_goserve/thumb", "/_goserve/thumb/photos/a.jpg?w=256"
+func (t *Thumbnailer) Middleware(prefix string, root http.FileSystem) midway.Middleware {
+	prefix = strings.TrimRight(prefix, "/")
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+				inner.ServeHTTP(w, r)
+				return
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				return
+			}
+			t.serve(w, r, root, path.Clean(r.URL.Path[len(prefix):]))
+		})
+	}
+}
+
+// serve serves the thumbnail of the image at the path p of root
+func (t *Thumbnailer) serve(w http.ResponseWriter, r *http.Request, root http.FileSystem, p string) {
+
+	if !IsImage(p) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := root.Open(p)
+	if err != nil {
+		serveError(w, err)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		serveError(w, err)
+		return
+	} else if !stat.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+
+	requested, _ := strconv.Atoi(r.URL.Query().Get("w"))
+	width := Width(requested)
+	name := t.key(root, p, stat, width) + outputExt(p)
+	w.Header().Set("ETag", `"`+name+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+
+	if cached := t.open(name); cached != nil {
+		defer cached.Close()
+		http.ServeContent(w, r, name, stat.ModTime(), cached)
+		return
+	}
+
+	// wait for a slot to generate it
+	select {
+	case t.slots <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	var content []byte
+	cached := t.open(name)
+	if cached == nil {
+		content, err = Generate(file, path.Ext(p), width)
+	}
+	<-t.slots
+
+	switch {
+	case cached != nil:
+		defer cached.Close()
+		http.ServeContent(w, r, name, stat.ModTime(), cached)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	default:
+		t.store(name, content)
+		http.ServeContent(w, r, name, stat.ModTime(), bytes.NewReader(content))
+	}
+}
+
+// key returns the cache key of a thumbnail of the file, which
+// changes with the path, size and modification time of the file
+func (t *Thumbnailer) key(root http.FileSystem, p string, stat os.FileInfo, width int) string {
+	if native, err := vfs.NativePath(root, p); err == nil {
+		p = native
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%d", p, stat.Size(), stat.ModTime().UnixNano(), width)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// open opens the cached thumbnail, if any
+func (t *Thumbnailer) open(name string) *os.File {
+	if t.cache == "" {
+		return nil
+	}
+	f, err := os.Open(filepath.Join(t.cache, name[:2], name))
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// store writes the thumbnail to the cache. Thumbnails that
+// cannot be cached are generated again.
+func (t *Thumbnailer) store(name string, content []byte) {
+	if t.cache == "" {
+		return
+	}
+	dir := filepath.Join(t.cache, name[:2])
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	tmp, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(content)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func serveError(w http.ResponseWriter, err error) {
+	switch {
+	case os.IsNotExist(err):
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	case os.IsPermission(err):
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// outputExt returns the extension of thumbnails of the image:
+// JPEG for JPEG images, PNG for others to keep transparency
+func outputExt(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Generate reads the image, with the extension ext, and encodes its
+// thumbnail fitting in a square of the width. Images are turned
+// upright according to their EXIF orientation, and never enlarged.
+// Thumbnails of animated GIF images show their first frame.
+func Generate(r io.ReadSeeker, ext string, width int) ([]byte, error) {
+
+	orientation := 1
+	if outputExt(ext) == ".jpg" {
+		if x, err := exif.Decode(r); err == nil {
+			orientation = x.Orientation
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := orient(scale(src, width), orientation)
+	var buf bytes.Buffer
+	if outputExt(ext) == ".jpg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = png.Encode(&buf, dst)
+	}
+	return buf.Bytes(), err
+}
+
+// scale scales the image down to fit in a square of the width
+func scale(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > width || h > width {
+		if w >= h {
+			w, h = width, h*width/w
+		} else {
+			w, h = w*width/h, width
+		}
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+// orient transforms the image from the EXIF orientation to upright
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180°
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotated 90° clockwise to display
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90° counterclockwise to display
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
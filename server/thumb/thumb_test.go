@@ -0,0 +1,167 @@
+package thumb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/thumb"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+// landscape returns an image twice as wide as high,
+// with a red left half
+func landscape(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{0, 0, 255, 255}
+			if x < w/2 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// jpegOriented encodes the image as a JPEG with EXIF orientation
+func jpegOriented(t *testing.T, img image.Image, orientation uint16) []byte {
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, img, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, []uint16{42, 0, 8, 1, 0x0112, 3, 0, 1, orientation, 0, 0, 0})
+	var out bytes.Buffer
+	out.Write(b.Bytes()[:2])
+	binary.Write(&out, binary.BigEndian, []uint16{0xFFE1, uint16(2 + 6 + tiff.Len())})
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff.Bytes())
+	out.Write(b.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestWidth(t *testing.T) {
+	tests := []struct{ requested, want int }{
+		{0, 256}, {-5, 256}, {1, 64}, {64, 64}, {200, 256}, {300, 512}, {5000, 1024},
+	}
+	for _, test := range tests {
+		if have := thumb.Width(test.requested); test.want != have {
+			t.Errorf("%d: expected %d, got %d", test.requested, test.want, have)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+
+	var b bytes.Buffer
+	png.Encode(&b, landscape(400, 200))
+	out, err := thumb.Generate(bytes.NewReader(b.Bytes()), ".png", 256)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	img, format, err := image.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "png 256x128", format+" "+sizeOf(img); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+
+	// small images are not enlarged
+	out, _ = thumb.Generate(bytes.NewReader(b.Bytes()), ".png", 1024)
+	img, _, _ = image.Decode(bytes.NewReader(out))
+	if want, have := "400x200", sizeOf(img); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+
+	// rotated 90° clockwise: the red half goes on top
+	out, err = thumb.Generate(bytes.NewReader(jpegOriented(t, landscape(400, 200), 6)), ".JPG", 256)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	img, format, _ = image.Decode(bytes.NewReader(out))
+	if want, have := "jpeg 128x256", format+" "+sizeOf(img); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	if r, _, b, _ := img.At(64, 32).RGBA(); r < b {
+		t.Errorf("expected red on top, got %#v", img.At(64, 32))
+	}
+
+	if _, err := thumb.Generate(bytes.NewReader([]byte("not an image")), ".gif", 256); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func sizeOf(img image.Image) string {
+	b := img.Bounds()
+	return fmt.Sprintf("%dx%d", b.Dx(), b.Dy())
+}
+
+func TestMiddleware(t *testing.T) {
+
+	root, cache := t.TempDir(), t.TempDir()
+	f, _ := os.Create(filepath.Join(root, "a.png"))
+	png.Encode(f, landscape(400, 200))
+	f.Close()
+	ioutil.WriteFile(filepath.Join(root, "a.txt"), []byte("text"), 0644)
+
+	th := thumb.New(thumb.Options{Cache: cache, Concurrency: 1}).
+		Middleware("/_goserve/thumb", vfs.Dir(root))(http.NotFoundHandler())
+	serve := func(path, etag string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		th.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("/_goserve/thumb/a.png?w=100", "")
+	if want, have := http.StatusOK, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d: %s", want, have, w.Body.String())
+	}
+	if want, have := "image/png", w.Header().Get("Content-Type"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	img, _, err := image.Decode(w.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "128x64", sizeOf(img); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+	cached, _ := filepath.Glob(filepath.Join(cache, "*", "*.png"))
+	if want, have := 1, len(cached); want != have {
+		t.Errorf("expected %d cached thumbnail, got %d", want, have)
+	}
+
+	// revalidated with the ETag
+	if want, have := http.StatusNotModified, serve("/_goserve/thumb/a.png?w=100", w.Header().Get("ETag")).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	for path, code := range map[string]int{
+		"/_goserve/thumb/a.txt":     http.StatusNotFound,
+		"/_goserve/thumb/b.png":     http.StatusNotFound,
+		"/_goserve/thumb/../a.png":  http.StatusOK,
+		"/_goserve/thumbnail/a.png": http.StatusNotFound,
+	} {
+		if have := serve(path, "").Code; code != have {
+			t.Errorf("%s: expected status %d, got %d", path, code, have)
+		}
+	}
+}
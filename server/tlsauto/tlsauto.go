@@ -0,0 +1,249 @@
+// Package tlsauto generates and caches a local certificate authority and
+// a leaf certificate signed by it, so that goserve can serve HTTPS on a
+// development machine without any manual certificate setup.
+//
+// Trusting the generated CA certificate (ca.crt in the cache directory)
+// in the browser or operating system removes the security warning.
+package tlsauto
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// file names inside the cache directory
+const (
+	caCertFile   = "ca.crt"
+	caKeyFile    = "ca.key"
+	leafCertFile = "cert.pem"
+	leafKeyFile  = "key.pem"
+)
+
+// validity of generated certificates
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 365 * 24 * time.Hour
+	leafRenew    = 30 * 24 * time.Hour
+)
+
+// DefaultCacheDir returns the default directory to cache
+// the generated certificates
+func DefaultCacheDir() (dir string, err error) {
+	if dir, err = os.UserConfigDir(); err != nil {
+		return
+	}
+	dir = filepath.Join(dir, "goserve", "tls")
+	return
+}
+
+// LocalHosts returns the host names and IP addresses that the local
+// machine can be reached with: localhost, loopback addresses, the
+// machine host name and the addresses of its network interfaces.
+func LocalHosts() (hosts []string) {
+	hosts = []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		hosts = append(hosts, hostname)
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		hosts = append(hosts, ipnet.IP.String())
+	}
+	return
+}
+
+// Load returns a leaf certificate for the given hosts signed by the local
+// CA cached in dir. The CA and the leaf certificate are generated on first
+// use. The leaf certificate is regenerated whenever it does not cover all
+// the hosts, is about to expire or is not signed by the cached CA.
+func Load(dir string, hosts []string) (cert tls.Certificate, err error) {
+
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	ca, caKey, err := loadCA(dir)
+	if os.IsNotExist(err) {
+		ca, caKey, err = createCA(dir)
+	}
+	if err != nil {
+		err = fmt.Errorf("tlsauto: failed to load local CA: %s", err)
+		return
+	}
+
+	certPath := filepath.Join(dir, leafCertFile)
+	keyPath := filepath.Join(dir, leafKeyFile)
+	if cert, err = tls.LoadX509KeyPair(certPath, keyPath); err == nil && leafUsable(cert, ca, hosts) {
+		return
+	}
+
+	if err = createLeaf(ca, caKey, hosts, certPath, keyPath); err != nil {
+		err = fmt.Errorf("tlsauto: failed to generate certificate: %s", err)
+		return
+	}
+	return tls.LoadX509KeyPair(certPath, keyPath)
+}
+
+// CACertPath returns the path of the CA certificate
+// for users to install as trusted
+func CACertPath(dir string) string {
+	return filepath.Join(dir, caCertFile)
+}
+
+func loadCA(dir string) (ca *x509.Certificate, key crypto.Signer, err error) {
+	pair, err := tls.LoadX509KeyPair(filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile))
+	if err != nil {
+		return
+	}
+	if ca, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return
+	}
+	if !ca.IsCA || time.Now().After(ca.NotAfter) {
+		err = os.ErrNotExist // treat as missing and regenerate
+		return
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		err = fmt.Errorf("unsupported CA private key type %T", pair.PrivateKey)
+	}
+	return
+}
+
+func createCA(dir string) (ca *x509.Certificate, key crypto.Signer, err error) {
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
+	hostname, _ := os.Hostname()
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization:       []string{"goserve local CA"},
+			OrganizationalUnit: []string{hostname},
+			CommonName:         "goserve local CA " + hostname,
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, priv.Public(), priv)
+	if err != nil {
+		return
+	}
+	if err = writePair(der, priv, filepath.Join(dir, caCertFile), filepath.Join(dir, caKeyFile)); err != nil {
+		return
+	}
+	if ca, err = x509.ParseCertificate(der); err != nil {
+		return
+	}
+	key = priv
+	return
+}
+
+func createLeaf(ca *x509.Certificate, caKey crypto.Signer, hosts []string, certPath, keyPath string) (err error) {
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+
+	serial, err := newSerial()
+	if err != nil {
+		return
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{"goserve development certificate"},
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().Add(leafValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, host)
+		}
+	}
+	if len(tpl.DNSNames) > 0 {
+		tpl.Subject.CommonName = tpl.DNSNames[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, priv.Public(), caKey)
+	if err != nil {
+		return
+	}
+	return writePair(der, priv, certPath, keyPath)
+}
+
+// leafUsable tests if a cached leaf certificate can be reused
+func leafUsable(cert tls.Certificate, ca *x509.Certificate, hosts []string) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+	if time.Now().Add(leafRenew).After(leaf.NotAfter) {
+		return false
+	}
+	if !bytes.Equal(leaf.RawIssuer, ca.RawSubject) || leaf.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+	for _, host := range hosts {
+		if leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func writePair(der []byte, priv *ecdsa.PrivateKey, certPath, keyPath string) (err error) {
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err = ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return ioutil.WriteFile(certPath, certPEM, 0644)
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,62 @@
+package tlsauto_test
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"testing"
+
+	"github.com/go-serve/goserve/server/tlsauto"
+)
+
+func TestLoad(t *testing.T) {
+
+	dir := t.TempDir()
+	hosts := []string{"localhost", "127.0.0.1", "192.168.1.10"}
+
+	cert, err := tlsauto.Load(dir, hosts)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// leaf should be trusted by the generated CA
+	caPEM, err := ioutil.ReadFile(tlsauto.CACertPath(dir))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatalf("failed to parse CA certificate")
+	}
+	for _, host := range hosts {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: pool}); err != nil {
+			t.Errorf("verify %s: unexpected error: %s", host, err.Error())
+		}
+	}
+
+	// cached certificate should be reused
+	cert2, err := tlsauto.Load(dir, hosts[:2])
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	leaf2, _ := x509.ParseCertificate(cert2.Certificate[0])
+	if leaf.SerialNumber.Cmp(leaf2.SerialNumber) != 0 {
+		t.Errorf("expected cached certificate to be reused")
+	}
+
+	// new host requires a new certificate from the same CA
+	cert3, err := tlsauto.Load(dir, append(hosts, "example.local"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	leaf3, _ := x509.ParseCertificate(cert3.Certificate[0])
+	if leaf.SerialNumber.Cmp(leaf3.SerialNumber) == 0 {
+		t.Errorf("expected certificate to be regenerated for new host")
+	}
+	if _, err := leaf3.Verify(x509.VerifyOptions{DNSName: "example.local", Roots: pool}); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,291 @@
+package upload
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-serve/goserve/server/auth"
+)
+
+// TusPath is the URL path of the uploads created with the tus
+// protocol. Uploads are created by POST requests to the URL
+// of the target directory, with the file name in metadata.
+const TusPath = "/_goserve/uploads"
+
+// TusVersion is the supported version of the tus protocol
+const TusVersion = "1.0.0"
+
+// tusUpload is an upload in progress
+type tusUpload struct {
+	mutex   sync.Mutex
+	name    string
+	tmpName string
+	user    string
+	length  int64
+	offset  int64
+
+	// expires is guarded by the mutex of the Uploader
+	expires time.Time
+}
+
+// serveTusCreate creates an upload into the directory at the URL
+// path with the creation extension of tus
+func (u *Uploader) serveTusCreate(w http.ResponseWriter, r *http.Request) {
+
+	if !u.tusResumable(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		u.opts.Error(w, r, http.StatusBadRequest)
+		return
+	}
+	if u.opts.MaxSize > 0 && length > u.opts.MaxSize {
+		u.opts.Error(w, r, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	filename := parseMetadata(r.Header.Get("Upload-Metadata"))["filename"]
+	base := path.Base(strings.Replace(filename, `\`, "/", -1))
+	if filename == "" || base == "." || base == "/" || base == ".." {
+		u.opts.Error(w, r, http.StatusBadRequest)
+		return
+	}
+	name := path.Join("/", r.URL.Path, base)
+	if code := u.check(r, name); code != 0 {
+		u.opts.Error(w, r, code)
+		return
+	}
+
+	u.expire(time.Now())
+	tmp, err := u.create(name)
+	if err != nil {
+		u.opts.Error(w, r, errorStatus(err))
+		return
+	}
+	tmp.Close()
+
+	upload := &tusUpload{
+		name:    name,
+		tmpName: tmp.Name(),
+		user:    auth.User(r.Context()),
+		length:  length,
+	}
+
+	// empty files are complete on creation
+	if length == 0 {
+		written, _, err := u.commit(upload.tmpName, name)
+		if err != nil {
+			u.opts.Error(w, r, errorStatus(err))
+			return
+		}
+		w.Header().Set("Location", (&url.URL{Path: written}).String())
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	id := newID()
+	u.mutex.Lock()
+	upload.expires = time.Now().Add(u.opts.Expiry)
+	u.uploads[id] = upload
+	u.mutex.Unlock()
+
+	w.Header().Set("Location", TusPath+"/"+id)
+	w.Header().Set("Upload-Expires", upload.expires.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// serveTus serves the uploads created by serveTusCreate
+func (u *Uploader) serveTus(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Tus-Resumable", TusVersion)
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", TusVersion)
+		w.Header().Set("Tus-Extension", "creation,termination,expiration")
+		if u.opts.MaxSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(u.opts.MaxSize, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if !u.tusResumable(w, r) {
+		return
+	}
+	if !sameOrigin(r) {
+		u.opts.Error(w, r, http.StatusForbidden)
+		return
+	}
+
+	u.expire(time.Now())
+	id := strings.TrimPrefix(r.URL.Path, TusPath+"/")
+	u.mutex.Lock()
+	upload, ok := u.uploads[id]
+	ok = ok && upload.user == auth.User(r.Context())
+	var expires time.Time
+	if ok {
+		upload.expires = time.Now().Add(u.opts.Expiry)
+		expires = upload.expires
+	}
+	u.mutex.Unlock()
+	if !ok {
+		u.opts.Error(w, r, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+
+	upload.mutex.Lock()
+	defer upload.mutex.Unlock()
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.length, 10))
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodPatch:
+		u.serveTusPatch(w, r, id, upload)
+
+	case http.MethodDelete:
+		u.mutex.Lock()
+		delete(u.uploads, id)
+		u.mutex.Unlock()
+		os.Remove(upload.tmpName)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "OPTIONS, HEAD, PATCH, DELETE")
+		u.opts.Error(w, r, http.StatusMethodNotAllowed)
+	}
+}
+
+// TusTarget returns the URL path of the file uploaded by
+// the tus upload of the ID
+func (u *Uploader) TusTarget(id string) (name string, ok bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	upload, ok := u.uploads[id]
+	if !ok {
+		return "", false
+	}
+	return upload.name, true
+}
+
+// serveTusPatch appends the request body to the upload, and
+// commits the file once complete
+func (u *Uploader) serveTusPatch(w http.ResponseWriter, r *http.Request, id string, upload *tusUpload) {
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		u.opts.Error(w, r, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		u.opts.Error(w, r, http.StatusBadRequest)
+		return
+	} else if offset != upload.offset {
+		u.opts.Error(w, r, http.StatusConflict)
+		return
+	}
+
+	tmp, err := os.OpenFile(upload.tmpName, os.O_WRONLY, 0)
+	if err != nil {
+		u.opts.Error(w, r, errorStatus(err))
+		return
+	}
+	if _, err = tmp.Seek(upload.offset, io.SeekStart); err == nil {
+		// keep what is received of an interrupted request,
+		// so the client may resume from there
+		var n int64
+		n, err = io.Copy(tmp, io.LimitReader(r.Body, upload.length-upload.offset))
+		upload.offset += n
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		u.opts.Error(w, r, http.StatusInternalServerError)
+		return
+	}
+
+	if upload.offset == upload.length {
+		u.mutex.Lock()
+		delete(u.uploads, id)
+		u.mutex.Unlock()
+		if _, _, err := u.commit(upload.tmpName, upload.name); err != nil {
+			u.opts.Error(w, r, errorStatus(err))
+			return
+		}
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expire removes the uploads unfinished at their expiry, with their
+// temporary files. Uploads receiving a request are kept.
+func (u *Uploader) expire(now time.Time) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for id, upload := range u.uploads {
+		if now.Before(upload.expires) || !upload.mutex.TryLock() {
+			continue
+		}
+		delete(u.uploads, id)
+		os.Remove(upload.tmpName)
+		upload.mutex.Unlock()
+	}
+}
+
+// tusResumable checks the protocol version of the request
+func (u *Uploader) tusResumable(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", TusVersion)
+	if r.Header.Get("Tus-Resumable") != TusVersion {
+		w.Header().Set("Tus-Version", TusVersion)
+		u.opts.Error(w, r, http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseMetadata parses the Upload-Metadata header of comma
+// separated keys and base64 encoded values
+func parseMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 {
+			continue
+		}
+		value := ""
+		if len(parts) > 1 {
+			b, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				continue
+			}
+			value = string(b)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata
+}
+
+// newID returns a random upload ID
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
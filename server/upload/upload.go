@@ -0,0 +1,452 @@
+// Package upload writes files uploaded to goserve with multipart
+// forms, PUT requests and the tus resumable upload protocol
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-serve/goserve/server/auth"
+	"github.com/go-serve/goserve/server/midway"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+// Conflict is the policy for uploads to paths of existing files
+type Conflict string
+
+// conflict policies
+const (
+	// Overwrite replaces the existing file
+	Overwrite Conflict = "overwrite"
+
+	// Rename writes to a free name like "report (1).pdf"
+	Rename Conflict = "rename"
+
+	// Reject refuses the upload with 409 Conflict
+	Reject Conflict = "reject"
+)
+
+// ParseConflict parses "overwrite", "rename" or "reject"
+func ParseConflict(s string) (conflict Conflict, err error) {
+	switch conflict = Conflict(s); conflict {
+	case Overwrite, Rename, Reject:
+		return
+	}
+	return "", fmt.Errorf("unknown conflict policy %#v, expected overwrite, rename or reject", s)
+}
+
+// DefaultExpiry is the default time unfinished tus uploads
+// are kept after their last request
+const DefaultExpiry = 24 * time.Hour
+
+// TempPrefix starts the names of the temporary files of uploads
+const TempPrefix = ".goserve-upload-"
+
+// errors of uploads
+var (
+	ErrTooLarge = errors.New("upload: file too large")
+	ErrExists   = errors.New("upload: file already exists")
+)
+
+// Permission allows uploads under a URL path prefix
+type Permission struct {
+	Prefix string
+
+	// Users who may upload. If empty, anyone who may
+	// access the path may upload.
+	Users []string
+}
+
+// Options configures an Uploader
+type Options struct {
+	// Permissions of URL paths, the longest prefix applies.
+	// Without permissions, uploads are allowed everywhere.
+	Permissions []Permission
+
+	// MaxSize in bytes of an uploaded file, 0 for no limit
+	MaxSize int64
+
+	// Conflict policy, defaults to Reject
+	Conflict Conflict
+
+	// Expiry is the time unfinished tus uploads are kept after
+	// their last request, defaults to DefaultExpiry
+	Expiry time.Duration
+
+	// Protected are the base names of files that may not be
+	// written anywhere, e.g. "_redirects" or ".goserveignore",
+	// as they control how the server serves other files
+	Protected []string
+
+	// Error writes error responses. Defaults to plain text.
+	Error func(w http.ResponseWriter, r *http.Request, code int)
+}
+
+// File is an uploaded file
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Uploader writes uploaded files into a file system implementing
+// vfs.Native. Files are written to a temporary file in the target
+// directory, then renamed into place once complete.
+type Uploader struct {
+	root http.FileSystem
+	opts Options
+
+	// mutex serializes the commits of files and guards tus uploads
+	mutex   sync.Mutex
+	uploads map[string]*tusUpload
+}
+
+// New returns an Uploader writing to the root
+func New(root http.FileSystem, opts Options) *Uploader {
+
+	perms := make([]Permission, len(opts.Permissions))
+	for i, perm := range opts.Permissions {
+		perm.Prefix = path.Clean("/" + perm.Prefix)
+		perms[i] = perm
+	}
+	if len(perms) == 0 {
+		perms = []Permission{{Prefix: "/"}}
+	}
+
+	// longest prefix first
+	sort.SliceStable(perms, func(i, j int) bool {
+		return len(perms[i].Prefix) > len(perms[j].Prefix)
+	})
+	opts.Permissions = perms
+
+	if opts.Conflict == "" {
+		opts.Conflict = Reject
+	}
+	if opts.Expiry <= 0 {
+		opts.Expiry = DefaultExpiry
+	}
+	if opts.Error == nil {
+		opts.Error = func(w http.ResponseWriter, r *http.Request, code int) {
+			http.Error(w, http.StatusText(code), code)
+		}
+	}
+
+	return &Uploader{
+		root:    root,
+		opts:    opts,
+		uploads: make(map[string]*tusUpload),
+	}
+}
+
+// Allowed reports if the user of the request context
+// may upload to the path
+func (u *Uploader) Allowed(ctx context.Context, name string) bool {
+	name = path.Clean("/" + name)
+	for _, protected := range u.opts.Protected {
+		if path.Base(name) == protected {
+			return false
+		}
+	}
+	for _, perm := range u.opts.Permissions {
+		if !hasPathPrefix(name, perm.Prefix) {
+			continue
+		}
+		if len(perm.Users) == 0 {
+			return true
+		}
+		user := auth.User(ctx)
+		for _, allowed := range perm.Users {
+			if user != "" && user == allowed {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Middleware generates a middleware that handles PUT requests,
+// multipart form posts and tus uploads. Other requests are
+// deferred to the inner handler.
+func (u *Uploader) Middleware() midway.Middleware {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			switch {
+			case hasPathPrefix(r.URL.Path, TusPath):
+				u.serveTus(w, r)
+				return
+			case strings.HasPrefix(r.URL.Path, "/_goserve/"):
+			case r.Method == http.MethodPut:
+				u.servePut(w, r)
+				return
+			case r.Method == http.MethodPost && r.Header.Get("Tus-Resumable") != "":
+				u.serveTusCreate(w, r)
+				return
+			case r.Method == http.MethodPost && isMultipart(r):
+				u.serveForm(w, r)
+				return
+			}
+
+			// defers to inner handler
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// servePut writes the request body to the file at the URL path
+func (u *Uploader) servePut(w http.ResponseWriter, r *http.Request) {
+
+	name := path.Clean("/" + r.URL.Path)
+	if code := u.check(r, name); code != 0 {
+		u.opts.Error(w, r, code)
+		return
+	}
+
+	file, created, err := u.write(name, r.Body)
+	if err != nil {
+		u.opts.Error(w, r, errorStatus(err))
+		return
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	w.Header().Set("Location", (&url.URL{Path: file.Path}).String())
+	writeJSON(w, code, file)
+}
+
+// serveForm writes the files of a multipart form into
+// the directory at the URL path
+func (u *Uploader) serveForm(w http.ResponseWriter, r *http.Request) {
+
+	dir := path.Clean("/" + r.URL.Path)
+	if code := u.check(r, dir); code != 0 {
+		u.opts.Error(w, r, code)
+		return
+	}
+	if native, err := vfs.NativePath(u.root, dir); err != nil {
+		u.opts.Error(w, r, errorStatus(err))
+		return
+	} else if stat, err := os.Stat(native); err != nil || !stat.IsDir() {
+		u.opts.Error(w, r, http.StatusNotFound)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		u.opts.Error(w, r, http.StatusBadRequest)
+		return
+	}
+
+	files := make([]File, 0, 1)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			u.opts.Error(w, r, http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+
+		base := path.Base(strings.Replace(part.FileName(), `\`, "/", -1))
+		if base == "." || base == "/" || base == ".." {
+			u.opts.Error(w, r, http.StatusBadRequest)
+			return
+		}
+		file, _, err := u.write(path.Join(dir, base), part)
+		if err != nil {
+			u.opts.Error(w, r, errorStatus(err))
+			return
+		}
+		files = append(files, file)
+	}
+
+	// browsers submitting the form of listing page
+	// are redirected back to the listing
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	}
+	writeJSON(w, http.StatusCreated, files)
+}
+
+// check returns the status code to refuse an upload to
+// the path with, or 0 if the upload may proceed
+func (u *Uploader) check(r *http.Request, name string) int {
+	if !sameOrigin(r) || !u.Allowed(r.Context(), name) {
+		return http.StatusForbidden
+	}
+	if u.opts.MaxSize > 0 && r.ContentLength > u.opts.MaxSize && !isMultipart(r) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return 0
+}
+
+// write stores the content of src as the file at the URL path
+func (u *Uploader) write(name string, src io.Reader) (file File, created bool, err error) {
+
+	tmp, err := u.create(name)
+	if err != nil {
+		return
+	}
+
+	if u.opts.MaxSize > 0 {
+		src = io.LimitReader(src, u.opts.MaxSize+1)
+	}
+	file.Size, err = io.Copy(tmp, src)
+	if err == nil && u.opts.MaxSize > 0 && file.Size > u.opts.MaxSize {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+
+	file.Path, created, err = u.commit(tmp.Name(), name)
+	return
+}
+
+// create opens a temporary file in the directory of the URL path,
+// creating missing directories
+func (u *Uploader) create(name string) (tmp *os.File, err error) {
+
+	// the file itself must not be hidden
+	if _, err = vfs.NativePath(u.root, name); err != nil {
+		return
+	}
+	dir, err := vfs.NativePath(u.root, path.Dir(name))
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	return ioutil.TempFile(dir, TempPrefix)
+}
+
+// IsTemp is a vfs.HideFunc reporting the temporary files of
+// uploads in progress, which are written next to their target
+func IsTemp(name string, isDir bool) bool {
+	return !isDir && strings.HasPrefix(path.Base(name), TempPrefix)
+}
+
+// commit renames the temporary file to the URL path according to
+// the conflict policy. It returns the URL path of the file and
+// if the file did not exist before.
+func (u *Uploader) commit(tmpName, name string) (written string, created bool, err error) {
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	defer func() {
+		if err != nil {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err = os.Chmod(tmpName, 0644); err != nil {
+		return
+	}
+
+	written = path.Clean("/" + name)
+	for i := 1; ; i++ {
+		var target string
+		if target, err = vfs.NativePath(u.root, written); err != nil {
+			return
+		}
+		stat, serr := os.Lstat(target)
+		if os.IsNotExist(serr) {
+			created = true
+			err = os.Rename(tmpName, target)
+			return
+		} else if serr != nil {
+			err = serr
+			return
+		}
+
+		switch {
+		case u.opts.Conflict == Rename:
+			written = numbered(name, i)
+		case u.opts.Conflict == Overwrite && stat.Mode().IsRegular():
+			err = os.Rename(tmpName, target)
+			return
+		default:
+			err = ErrExists
+			return
+		}
+	}
+}
+
+// numbered returns the path with a number added to
+// its base name, e.g. "/report (1).pdf"
+func numbered(name string, i int) string {
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+}
+
+// errorStatus maps errors of uploads to HTTP status code
+func errorStatus(err error) int {
+	switch {
+	case err == ErrTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case err == ErrExists:
+		return http.StatusConflict
+	case os.IsNotExist(err):
+		return http.StatusNotFound
+	case os.IsPermission(err):
+		return http.StatusForbidden
+	}
+	if perr, ok := err.(*os.PathError); ok && perr.Err == vfs.ErrNotNative {
+		return http.StatusMethodNotAllowed
+	}
+	return http.StatusInternalServerError
+}
+
+// isMultipart reports if the request body is a multipart form
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// sameOrigin reports if the request is not a cross site request
+// from browser, which may carry the credentials of the user
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func hasPathPrefix(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
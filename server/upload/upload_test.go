@@ -0,0 +1,318 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/upload"
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func tempRoot(t *testing.T) string {
+	root, err := ioutil.TempDir("", "goserve-test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return root
+}
+
+func readFile(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	return string(b)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	http.NotFound(w, r)
+}
+
+func put(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("PUT", "http://example.com"+path, strings.NewReader(body)))
+	return w
+}
+
+func TestPut(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		conflict upload.Conflict
+		codes    []int
+		files    map[string]string
+	}{
+		{upload.Reject, []int{http.StatusCreated, http.StatusConflict}, map[string]string{
+			"reject/a.txt": "first",
+		}},
+		{upload.Overwrite, []int{http.StatusCreated, http.StatusOK}, map[string]string{
+			"overwrite/a.txt": "second",
+		}},
+		{upload.Rename, []int{http.StatusCreated, http.StatusCreated}, map[string]string{
+			"rename/a.txt":     "first",
+			"rename/a (1).txt": "second",
+		}},
+	}
+	for _, test := range tests {
+		u := upload.New(vfs.Dir(root), upload.Options{Conflict: test.conflict})
+		h := u.Middleware()(http.HandlerFunc(notFound))
+		for i, body := range []string{"first", "second"} {
+			w := put(h, "/"+string(test.conflict)+"/a.txt", body)
+			if want, have := test.codes[i], w.Code; want != have {
+				t.Errorf("%s: expected status %d, got %d", test.conflict, want, have)
+			}
+		}
+		for name, content := range test.files {
+			if want, have := content, readFile(t, filepath.Join(root, name)); want != have {
+				t.Errorf("%s: expected %#v, got %#v", name, want, have)
+			}
+		}
+	}
+
+	// no temporary file left behind
+	list, _ := ioutil.ReadDir(filepath.Join(root, "reject"))
+	if want, have := 1, len(list); want != have {
+		t.Errorf("expected %d file, got %d", want, have)
+	}
+}
+
+func TestPutLimits(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+	os.Mkdir(filepath.Join(root, "private"), 0755)
+
+	u := upload.New(vfs.Hide(vfs.Dir(root), vfs.IsDotfile), upload.Options{
+		MaxSize: 4,
+		Permissions: []upload.Permission{
+			{Prefix: "/incoming"},
+		},
+	})
+	h := u.Middleware()(http.HandlerFunc(notFound))
+
+	tests := []struct {
+		path string
+		body string
+		code int
+	}{
+		{"/incoming/ok.txt", "1234", http.StatusCreated},
+		{"/incoming/large.txt", "12345", http.StatusRequestEntityTooLarge},
+		{"/private/a.txt", "1", http.StatusForbidden},
+		{"/incoming/.env", "1", http.StatusNotFound},
+		{"/incoming/../private/a.txt", "1", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		if want, have := test.code, put(h, test.path, test.body).Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+	}
+
+	// size limit without Content-Length
+	r := httptest.NewRequest("PUT", "http://example.com/incoming/chunked.txt", strings.NewReader("12345"))
+	r.ContentLength = -1
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if want, have := http.StatusRequestEntityTooLarge, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	list, _ := ioutil.ReadDir(filepath.Join(root, "incoming"))
+	if want, have := 1, len(list); want != have {
+		t.Errorf("expected %d file, got %d", want, have)
+	}
+
+	// cross site request
+	r = httptest.NewRequest("PUT", "http://example.com/incoming/csrf.txt", strings.NewReader("1"))
+	r.Header.Set("Origin", "http://evil.com")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if want, have := http.StatusForbidden, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+
+	u := upload.New(vfs.Dir(root), upload.Options{})
+	h := u.Middleware()(http.HandlerFunc(notFound))
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range map[string]string{"a.txt": "aaa", "b.txt": "bbb"} {
+		fw, _ := mw.CreateFormFile("file", name)
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "http://example.com/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if want, have := http.StatusSeeOther, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	for name, content := range map[string]string{"a.txt": "aaa", "b.txt": "bbb"} {
+		if want, have := content, readFile(t, filepath.Join(root, name)); want != have {
+			t.Errorf("%s: expected %#v, got %#v", name, want, have)
+		}
+	}
+}
+
+func TestTus(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+
+	u := upload.New(vfs.Dir(root), upload.Options{})
+	h := u.Middleware()(http.HandlerFunc(notFound))
+
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		r.Header.Set("Tus-Resumable", upload.TusVersion)
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("OPTIONS", upload.TusPath, "", nil)
+	if want, have := "creation,termination,expiration", w.Header().Get("Tus-Extension"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	w = serve("POST", "/builds", "", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("app.zip")),
+	})
+	if want, have := http.StatusCreated, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	location := w.Header().Get("Location")
+	if expires, err := http.ParseTime(w.Header().Get("Upload-Expires")); err != nil || expires.Before(time.Now().Add(upload.DefaultExpiry-time.Minute)) {
+		t.Errorf("unexpected Upload-Expires %#v", w.Header().Get("Upload-Expires"))
+	}
+
+	patch := func(offset, body string) *httptest.ResponseRecorder {
+		return serve("PATCH", location, body, map[string]string{
+			"Upload-Offset": offset,
+			"Content-Type":  "application/offset+octet-stream",
+		})
+	}
+	if want, have := http.StatusNoContent, patch("0", "hello").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := http.StatusConflict, patch("0", "hello").Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if want, have := "5", serve("HEAD", location, "", nil).Header().Get("Upload-Offset"); want != have {
+		t.Errorf("expected offset %#v, got %#v", want, have)
+	}
+	if _, err := os.Stat(filepath.Join(root, "builds", "app.zip")); !os.IsNotExist(err) {
+		t.Errorf("expected incomplete upload to be invisible, got %#v", err)
+	}
+	if want, have := "11", patch("5", " world").Header().Get("Upload-Offset"); want != have {
+		t.Errorf("expected offset %#v, got %#v", want, have)
+	}
+	if want, have := "hello world", readFile(t, filepath.Join(root, "builds", "app.zip")); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := http.StatusNotFound, serve("HEAD", location, "", nil).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+
+	// version mismatch
+	r := httptest.NewRequest("POST", "http://example.com"+upload.TusPath+"/x", nil)
+	r.Header.Set("Tus-Resumable", "0.2.0")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r.WithContext(context.Background()))
+	if want, have := http.StatusPreconditionFailed, w.Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+}
+
+func TestTusExpiry(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+
+	u := upload.New(vfs.Dir(root), upload.Options{Expiry: time.Millisecond})
+	h := u.Middleware()(http.HandlerFunc(notFound))
+
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "http://example.com"+path, nil)
+		r.Header.Set("Tus-Resumable", upload.TusVersion)
+		for key, value := range headers {
+			r.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve("POST", "/builds", map[string]string{
+		"Upload-Length":   "11",
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("app.zip")),
+	})
+	if want, have := http.StatusCreated, w.Code; want != have {
+		t.Fatalf("expected status %d, got %d", want, have)
+	}
+	temps, _ := filepath.Glob(filepath.Join(root, "builds", ".goserve-upload-*"))
+	if want, have := 1, len(temps); want != have {
+		t.Fatalf("expected %d temporary file, got %d", want, have)
+	}
+	if !upload.IsTemp("/builds/"+filepath.Base(temps[0]), false) {
+		t.Errorf("expected %s to be a temporary file", temps[0])
+	}
+
+	// expired uploads are removed with their temporary files
+	time.Sleep(10 * time.Millisecond)
+	if want, have := http.StatusNotFound, serve("HEAD", w.Header().Get("Location"), nil).Code; want != have {
+		t.Errorf("expected status %d, got %d", want, have)
+	}
+	if _, err := os.Stat(temps[0]); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file removed, got %#v", err)
+	}
+}
+
+func TestProtected(t *testing.T) {
+
+	root := tempRoot(t)
+	defer os.RemoveAll(root)
+
+	u := upload.New(vfs.Dir(root), upload.Options{Protected: []string{"_redirects", ".goserveignore"}})
+	h := u.Middleware()(http.HandlerFunc(notFound))
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/_redirects", http.StatusForbidden},
+		{"/docs/.goserveignore", http.StatusForbidden},
+		{"/docs/_redirects.txt", http.StatusCreated},
+	}
+	for _, test := range tests {
+		if want, have := test.code, put(h, test.path, "x").Code; want != have {
+			t.Errorf("%s: expected status %d, got %d", test.path, want, have)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "_redirects")); !os.IsNotExist(err) {
+		t.Errorf("expected no file written, got %#v", err)
+	}
+}
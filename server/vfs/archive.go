@@ -0,0 +1,522 @@
+package vfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxArchiveIndexes is the number of archive indexes cached by
+// Archives, the least recently used ones being evicted first
+const maxArchiveIndexes = 32
+
+// IsArchive reports if the file name has the extension of
+// an archive browsed as a directory by Archives
+func IsArchive(name string) bool {
+	return archiveFormat(name) != ""
+}
+
+// archiveFormat returns the format of an archive by its file name
+func archiveFormat(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// Archives returns a file system that presents the zip, tar and tar.gz
+// files of fs as read-only directories. An archive opens as a file by
+// its path, and as a directory by its path with a trailing slash (e.g.
+// "/release.tar.gz/"). Members open by their path in the archive
+// directory (e.g. "/release.tar.gz/bin/tool").
+//
+// As paths are cleaned by other file systems, Archives must wrap them,
+// not the other way round.
+func Archives(fs http.FileSystem) http.FileSystem {
+	return FilteredArchives(fs, nil)
+}
+
+// FilteredArchives is Archives, with the members of archives opened
+// through the file system returned by filter (e.g. with Hide), so that
+// they are filtered by their full path (e.g. "/backup.zip/.env") like
+// the files of fs. The file system passed to filter opens paths in
+// archives as members, and other paths in fs.
+func FilteredArchives(fs http.FileSystem, filter func(http.FileSystem) http.FileSystem) http.FileSystem {
+	a := &archiveFS{
+		fs:      fs,
+		indexes: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	if filter != nil {
+		a.members = filter(memberFS{a})
+	}
+	return a
+}
+
+type archiveFS struct {
+	fs http.FileSystem
+
+	// members opens the members of archives, if filtered
+	members http.FileSystem
+
+	// indexes of archives by path, in lru from the most
+	// to the least recently used
+	mutex   sync.Mutex
+	indexes map[string]*list.Element
+	lru     *list.List
+}
+
+// Open implements http.FileSystem
+func (a *archiveFS) Open(name string) (http.File, error) {
+	archive, member, ok := a.split(name)
+	if !ok {
+		return a.fs.Open(name)
+	}
+	if a.members != nil {
+		return a.members.Open(path.Join(archive, member))
+	}
+	return a.openMember(archive, member)
+}
+
+// memberFS opens the paths in archives as their members, archives
+// as directories, and other paths in the file system of the archives
+type memberFS struct {
+	a *archiveFS
+}
+
+// Open implements http.FileSystem
+func (m memberFS) Open(name string) (http.File, error) {
+	if archive, member, ok := m.a.split(strings.TrimSuffix(name, "/") + "/"); ok {
+		return m.a.openMember(archive, member)
+	}
+	return m.a.fs.Open(name)
+}
+
+// NativePath implements Native. Paths in archives are read-only.
+func (a *archiveFS) NativePath(name string) (string, error) {
+	if _, _, ok := a.split(name); ok {
+		return "", &os.PathError{Op: "resolve", Path: name, Err: os.ErrPermission}
+	}
+	return NativePath(a.fs, name)
+}
+
+// split finds the archive opened as a directory in the path name,
+// and returns the paths of the archive and of the member in it
+func (a *archiveFS) split(name string) (archive, member string, ok bool) {
+	clean := path.Clean("/" + name)
+	for i := 1; i <= len(clean); i++ {
+		if i < len(clean) && clean[i] != '/' {
+			continue
+		}
+		if !IsArchive(clean[:i]) {
+			continue
+		}
+		if i == len(clean) && !strings.HasSuffix(name, "/") {
+			// the archive itself
+			return
+		}
+		if a.isFile(clean[:i]) {
+			return clean[:i], path.Clean("/" + clean[i:]), true
+		}
+	}
+	return
+}
+
+// isFile reports if the path is a regular file
+func (a *archiveFS) isFile(name string) bool {
+	f, err := a.fs.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	return err == nil && stat.Mode().IsRegular()
+}
+
+// openMember opens the member of the archive at the path
+func (a *archiveFS) openMember(archive, member string) (http.File, error) {
+
+	f, err := a.fs.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	index, err := a.index(archive, f, stat)
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: archive, Err: err}
+	}
+
+	info, ok := index.entries[member]
+	if !ok {
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: path.Join(archive, member), Err: os.ErrNotExist}
+	}
+	if info.IsDir() {
+		f.Close()
+		return &virtualDir{
+			info: info.(virtualInfo),
+			list: append([]os.FileInfo(nil), index.children[member]...),
+		}, nil
+	}
+
+	var content io.ReadSeeker
+	if index.format == "zip" {
+		content, err = openZipMember(f, stat.Size(), member)
+	} else {
+		content = &streamSeeker{open: func() (io.ReadCloser, error) {
+			return openTarMember(f, index.format, member)
+		}}
+	}
+	if err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "open", Path: path.Join(archive, member), Err: err}
+	}
+	return &memberFile{ReadSeeker: content, archive: f, info: info}, nil
+}
+
+// index returns the cached index of the archive, or reads it
+func (a *archiveFS) index(archive string, f http.File, stat os.FileInfo) (index *archiveIndex, err error) {
+
+	a.mutex.Lock()
+	if elem, ok := a.indexes[archive]; ok {
+		index = elem.Value.(*archiveIndex)
+		a.lru.MoveToFront(elem)
+	}
+	a.mutex.Unlock()
+	if index != nil && index.size == stat.Size() && index.modTime.Equal(stat.ModTime()) {
+		return
+	}
+
+	index = &archiveIndex{
+		path:     archive,
+		format:   archiveFormat(archive),
+		size:     stat.Size(),
+		modTime:  stat.ModTime(),
+		entries:  make(map[string]os.FileInfo),
+		children: make(map[string][]os.FileInfo),
+	}
+	index.entries["/"] = virtualInfo{name: path.Base(archive), modTime: stat.ModTime()}
+	if index.format == "zip" {
+		err = index.readZip(f, stat.Size())
+	} else {
+		err = index.readTar(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	index.link()
+
+	a.mutex.Lock()
+	if elem, ok := a.indexes[archive]; ok {
+		a.lru.Remove(elem)
+	}
+	a.indexes[archive] = a.lru.PushFront(index)
+	for a.lru.Len() > maxArchiveIndexes {
+		oldest := a.lru.Remove(a.lru.Back()).(*archiveIndex)
+		delete(a.indexes, oldest.path)
+	}
+	a.mutex.Unlock()
+	return
+}
+
+// archiveIndex lists the members of an archive
+type archiveIndex struct {
+	path    string
+	format  string
+	size    int64
+	modTime time.Time
+
+	// entries by path in the archive, "/" is the archive itself
+	entries  map[string]os.FileInfo
+	children map[string][]os.FileInfo
+}
+
+// add adds a member with its parent directories
+func (index *archiveIndex) add(name string, isDir bool, size int64, modTime time.Time) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return
+	}
+	if isDir {
+		index.entries[name] = virtualInfo{name: path.Base(name), modTime: modTime}
+	} else {
+		index.entries[name] = memberInfo{name: path.Base(name), size: size, modTime: modTime}
+	}
+	for dir := path.Dir(name); dir != "/"; dir = path.Dir(dir) {
+		if _, ok := index.entries[dir]; ok {
+			break
+		}
+		index.entries[dir] = virtualInfo{name: path.Base(dir), modTime: index.modTime}
+	}
+}
+
+// link lists the children of every directory
+func (index *archiveIndex) link() {
+	for name, info := range index.entries {
+		if name != "/" {
+			dir := path.Dir(name)
+			index.children[dir] = append(index.children[dir], info)
+		}
+	}
+	for _, list := range index.children {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Name() < list[j].Name()
+		})
+	}
+}
+
+func (index *archiveIndex) readZip(f http.File, size int64) error {
+	zr, err := zip.NewReader(readerAt(f), size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		mode := zf.Mode()
+		if mode.IsDir() || strings.HasSuffix(zf.Name, "/") {
+			index.add(zf.Name, true, 0, zf.Modified)
+		} else if mode.IsRegular() {
+			index.add(zf.Name, false, int64(zf.UncompressedSize64), zf.Modified)
+		}
+	}
+	return nil
+}
+
+func (index *archiveIndex) readTar(f http.File) error {
+	r, err := tarReader(f, index.format)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			index.add(header.Name, true, 0, header.ModTime)
+		case tar.TypeReg, tar.TypeRegA:
+			index.add(header.Name, false, header.Size, header.ModTime)
+		}
+	}
+}
+
+// openZipMember returns the content of a zip member. Stored members
+// are read in place, compressed ones are decompressed as read.
+func openZipMember(f http.File, size int64, member string) (io.ReadSeeker, error) {
+	ra := readerAt(f)
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, zf := range zr.File {
+		if path.Clean("/"+zf.Name) != member || strings.HasSuffix(zf.Name, "/") {
+			continue
+		}
+		if zf.Method == zip.Store {
+			offset, err := zf.DataOffset()
+			if err != nil {
+				return nil, err
+			}
+			return io.NewSectionReader(ra, offset, int64(zf.UncompressedSize64)), nil
+		}
+		return &streamSeeker{open: zf.Open}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// openTarMember returns a reader of the content of a tar member
+func openTarMember(f http.File, format, member string) (io.ReadCloser, error) {
+	r, err := tarReader(f, format)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			r.Close()
+			return nil, os.ErrNotExist
+		} else if err != nil {
+			r.Close()
+			return nil, err
+		}
+		if path.Clean("/"+header.Name) == member && header.FileInfo().Mode().IsRegular() {
+			return struct {
+				io.Reader
+				io.Closer
+			}{tr, r}, nil
+		}
+	}
+}
+
+// tarReader reads a tar or tar.gz archive from the start
+func tarReader(f http.File, format string) (io.ReadCloser, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if format == "tar.gz" {
+		return gzip.NewReader(f)
+	}
+	return ioutil.NopCloser(f), nil
+}
+
+// readerAt returns an io.ReaderAt of the file
+func readerAt(f http.File) io.ReaderAt {
+	if ra, ok := f.(io.ReaderAt); ok {
+		return ra
+	}
+	return &seekReaderAt{f: f}
+}
+
+// seekReaderAt implements io.ReaderAt with Seek and Read
+type seekReaderAt struct {
+	mutex sync.Mutex
+	f     http.File
+}
+
+// ReadAt implements io.ReaderAt
+func (r *seekReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, err := r.f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.ReadFull(r.f, p)
+}
+
+// streamSeeker seeks in a stream that can only be read from the
+// start, by reopening it to seek backwards and skipping the content
+// to seek forwards
+type streamSeeker struct {
+	open func() (io.ReadCloser, error)
+	r    io.ReadCloser
+
+	// pos is the position to read, rpos the position of r
+	pos, rpos int64
+}
+
+// Read implements io.Reader
+func (s *streamSeeker) Read(p []byte) (n int, err error) {
+	if s.r != nil && s.rpos > s.pos {
+		s.r.Close()
+		s.r = nil
+	}
+	if s.r == nil {
+		if s.r, err = s.open(); err != nil {
+			s.r = nil
+			return
+		}
+		s.rpos = 0
+	}
+	if s.rpos < s.pos {
+		skipped, err := io.CopyN(ioutil.Discard, s.r, s.pos-s.rpos)
+		s.rpos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err = s.r.Read(p)
+	s.pos += int64(n)
+	s.rpos += int64(n)
+	return
+}
+
+// Seek implements io.Seeker. Seeking from the end is not supported,
+// as the size is unknown to the stream. The size of the member is
+// taken from its stat instead.
+func (s *streamSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.pos
+	default:
+		return s.pos, fmt.Errorf("vfs: seek from end is not supported")
+	}
+	if offset < 0 {
+		return s.pos, fmt.Errorf("vfs: negative position")
+	}
+	s.pos = offset
+	return offset, nil
+}
+
+// Close implements io.Closer
+func (s *streamSeeker) Close() error {
+	if s.r != nil {
+		return s.r.Close()
+	}
+	return nil
+}
+
+// memberFile is a file in an archive
+type memberFile struct {
+	io.ReadSeeker
+	archive http.File
+	info    os.FileInfo
+}
+
+// Seek implements io.Seeker, answering seeks from the end
+// with the size of the member
+func (f *memberFile) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekEnd {
+		return f.ReadSeeker.Seek(f.info.Size()+offset, io.SeekStart)
+	}
+	return f.ReadSeeker.Seek(offset, whence)
+}
+
+// Close implements http.File
+func (f *memberFile) Close() error {
+	if closer, ok := f.ReadSeeker.(io.Closer); ok {
+		closer.Close()
+	}
+	return f.archive.Close()
+}
+
+// Readdir implements http.File
+func (f *memberFile) Readdir(count int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("vfs: %s is not a directory", f.info.Name())
+}
+
+// Stat implements http.File
+func (f *memberFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// memberInfo is the os.FileInfo of a file in an archive
+type memberInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi memberInfo) Name() string       { return fi.name }
+func (fi memberInfo) Size() int64        { return fi.size }
+func (fi memberInfo) Mode() os.FileMode  { return 0444 }
+func (fi memberInfo) ModTime() time.Time { return fi.modTime }
+func (fi memberInfo) IsDir() bool        { return false }
+func (fi memberInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,236 @@
+package vfs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+// archiveMembers are the files written to test archives
+var archiveMembers = []struct {
+	name    string
+	content string
+}{
+	{"release/", ""},
+	{"release/README.txt", "# Release\n"},
+	{"release/bin/tool", strings.Repeat("0123456789", 100)},
+	{"../escape.txt", "escape"},
+}
+
+func writeZip(t *testing.T, name string) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for i, member := range archiveMembers {
+		method := zip.Deflate
+		if i%2 == 1 {
+			method = zip.Store
+		}
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: member.name, Method: method, Modified: time.Now()})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		io.WriteString(w, member.content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func writeTar(t *testing.T, name string, compress bool) {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer f.Close()
+	var w io.Writer = f
+	if compress {
+		gw := gzip.NewWriter(f)
+		defer gw.Close()
+		w = gw
+	}
+	tw := tar.NewWriter(w)
+	for _, member := range archiveMembers {
+		header := &tar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(member.name, "/") {
+			header.Typeflag, header.Mode = tar.TypeDir, 0755
+		}
+		tw.WriteHeader(header)
+		io.WriteString(tw, member.content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestArchives(t *testing.T) {
+
+	root := t.TempDir()
+	writeZip(t, filepath.Join(root, "a.zip"))
+	writeTar(t, filepath.Join(root, "a.tar"), false)
+	writeTar(t, filepath.Join(root, "a.tar.gz"), true)
+	os.Mkdir(filepath.Join(root, "dir.zip"), 0755)
+	fs := vfs.Archives(vfs.Dir(root))
+
+	for _, archive := range []string{"/a.zip", "/a.tar", "/a.tar.gz"} {
+
+		// the archive itself
+		f, err := fs.Open(archive)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", archive, err.Error())
+		}
+		stat, _ := f.Stat()
+		f.Close()
+		if !stat.Mode().IsRegular() {
+			t.Errorf("%s: expected a regular file", archive)
+		}
+
+		// as directories
+		if want, have := "escape.txt, release", readdirNames(t, fs, archive+"/"); want != have {
+			t.Errorf("%s: expected %#v, got %#v", archive, want, have)
+		}
+		if want, have := "README.txt, bin", readdirNames(t, fs, archive+"/release"); want != have {
+			t.Errorf("%s: expected %#v, got %#v", archive, want, have)
+		}
+
+		// members
+		for _, member := range archiveMembers[1:3] {
+			f, err := fs.Open(archive + "/" + member.name)
+			if err != nil {
+				t.Fatalf("%s/%s: unexpected error: %s", archive, member.name, err.Error())
+			}
+			b, _ := ioutil.ReadAll(f)
+			if want, have := member.content, string(b); want != have {
+				t.Errorf("%s/%s: expected %#v, got %#v", archive, member.name, want, have)
+			}
+
+			// seek back and forth
+			if _, err := f.Seek(5, io.SeekStart); err != nil {
+				t.Fatalf("%s/%s: unexpected error: %s", archive, member.name, err.Error())
+			}
+			b = make([]byte, 3)
+			io.ReadFull(f, b)
+			if want, have := member.content[5:8], string(b); want != have {
+				t.Errorf("%s/%s: expected %#v, got %#v", archive, member.name, want, have)
+			}
+			if size, _ := f.Seek(0, io.SeekEnd); size != int64(len(member.content)) {
+				t.Errorf("%s/%s: expected size %d, got %d", archive, member.name, len(member.content), size)
+			}
+			f.Close()
+		}
+
+		if _, err := fs.Open(archive + "/missing"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected not exist error, got %#v", archive, err)
+		}
+	}
+
+	// directories named like archives
+	if want, have := "", readdirNames(t, fs, "/dir.zip/"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// archives are read-only
+	if _, err := vfs.NativePath(fs, "/a.zip/release/new.txt"); !os.IsPermission(err) {
+		t.Errorf("expected permission error, got %#v", err)
+	}
+	if _, err := vfs.NativePath(fs, "/a.zip"); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
+
+func TestFilteredArchives(t *testing.T) {
+
+	root := t.TempDir()
+	writeZip(t, filepath.Join(root, "a.zip"))
+	writeTar(t, filepath.Join(root, "a.tar"), false)
+	fs := vfs.FilteredArchives(vfs.Dir(root), func(fs http.FileSystem) http.FileSystem {
+		fs = vfs.Hide(fs, func(name string, isDir bool) bool {
+			return strings.HasSuffix(name, "/bin")
+		})
+		return vfs.Deny(fs, func(name string, isDir bool) bool {
+			return strings.HasSuffix(name, "/README.txt")
+		})
+	})
+
+	for _, archive := range []string{"/a.zip", "/a.tar"} {
+		if want, have := "escape.txt, release", readdirNames(t, fs, archive+"/"); want != have {
+			t.Errorf("%s: expected %#v, got %#v", archive, want, have)
+		}
+		if want, have := "", readdirNames(t, fs, archive+"/release"); want != have {
+			t.Errorf("%s: expected %#v, got %#v", archive, want, have)
+		}
+		if _, err := fs.Open(archive + "/release/bin/tool"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected not exist error, got %#v", archive, err)
+		}
+		if _, err := fs.Open(archive + "/release/README.txt"); !os.IsPermission(err) {
+			t.Errorf("%s: expected permission error, got %#v", archive, err)
+		}
+		f, err := fs.Open(archive + "/escape.txt")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", archive, err.Error())
+		}
+		f.Close()
+	}
+
+	// the archive itself is not filtered
+	f, err := fs.Open("/a.zip")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	stat, _ := f.Stat()
+	f.Close()
+	if !stat.Mode().IsRegular() {
+		t.Errorf("expected a regular file")
+	}
+}
+
+func TestArchivesRange(t *testing.T) {
+
+	root := t.TempDir()
+	writeZip(t, filepath.Join(root, "a.zip"))
+	ts := httptest.NewServer(http.FileServer(vfs.Archives(vfs.Dir(root))))
+	defer ts.Close()
+
+	for _, name := range []string{"README.txt", "bin/tool"} {
+		r, _ := http.NewRequest("GET", ts.URL+"/a.zip/release/"+name, nil)
+		r.Header.Set("Range", "bytes=2-5")
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if want, have := http.StatusPartialContent, resp.StatusCode; want != have {
+			t.Errorf("%s: expected status %d, got %d", name, want, have)
+		}
+		if want, have := "2345", string(b); name == "bin/tool" && want != have {
+			t.Errorf("%s: expected %#v, got %#v", name, want, have)
+		}
+		if want, have := "Rele", string(b); name == "README.txt" && want != have {
+			t.Errorf("%s: expected %#v, got %#v", name, want, have)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/a.zip/release/README.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	resp.Body.Close()
+	if want, have := "text/plain; charset=utf-8", resp.Header.Get("Content-Type"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+}
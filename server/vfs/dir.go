@@ -0,0 +1,78 @@
+// Package vfs provides http.FileSystem implementations and wrappers
+// used by goserve to expose the served directory
+package vfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Dir implements http.FileSystem using the native file system restricted
+// to a specific directory tree, like http.Dir. Unlike http.Dir, it refuses
+// to open any path that resolves, through symbolic links, to a location
+// outside of the directory.
+//
+// An empty Dir is treated as ".".
+type Dir string
+
+// Open implements http.FileSystem
+func (d Dir) Open(name string) (http.File, error) {
+	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
+		return nil, errors.New("vfs: invalid character in file path")
+	}
+
+	root, err := d.realRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	fullName := filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+	realName, err := filepath.EvalSymlinks(fullName)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+	}
+	if !Contains(root, realName) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+
+	return os.Open(fullName)
+}
+
+// realRoot returns the absolute path of the directory with
+// all symbolic links resolved
+func (d Dir) realRoot() (root string, err error) {
+	root = string(d)
+	if root == "" {
+		root = "."
+	}
+	if root, err = filepath.Abs(root); err != nil {
+		return
+	}
+	return filepath.EvalSymlinks(root)
+}
+
+// Contains reports whether the native path target is root itself
+// or lies within the directory tree of root. Both paths are expected
+// to be absolute and cleaned.
+func Contains(root, target string) bool {
+	if root == target {
+		return true
+	}
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+	return strings.HasPrefix(target, root)
+}
+
+// unwrapPathError strips the native path from errors so that
+// file system layout is not leaked to clients
+func unwrapPathError(err error) error {
+	if perr, ok := err.(*os.PathError); ok {
+		return perr.Err
+	}
+	return err
+}
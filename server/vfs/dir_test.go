@@ -0,0 +1,61 @@
+package vfs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func TestDir(t *testing.T) {
+
+	root := vfs.Dir("./../../_example")
+
+	f, err := root.Open("/folder1/../folder1/foo1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "bar1\n", string(b); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if _, err := root.Open("/../../_example/not-exists"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %#v", err)
+	}
+}
+
+func TestDirSymlink(t *testing.T) {
+
+	outside := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "inside"), 0755); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("cannot create symlink: %s", err.Error())
+	}
+	if err := os.Symlink("inside", filepath.Join(dir, "alias")); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	root := vfs.Dir(dir)
+	if _, err := root.Open("/escape/secret"); !os.IsPermission(err) {
+		t.Errorf("expected permission error, got %#v", err)
+	}
+	f, err := root.Open("/alias")
+	if err != nil {
+		t.Fatalf("symlink within root should be allowed, got %s", err.Error())
+	}
+	f.Close()
+}
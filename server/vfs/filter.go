@@ -0,0 +1,135 @@
+package vfs
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-serve/goserve/server/glob"
+)
+
+// HideFunc reports if the file or directory at the slash separated
+// path name should be hidden
+type HideFunc func(name string, isDir bool) bool
+
+// Hide returns a file system that hides files and directories reported
+// by the hidden function. Hidden entries are left out of directory
+// listings, and opening them, or anything inside a hidden directory,
+// fails with a not-exist error.
+func Hide(fs http.FileSystem, hidden HideFunc) http.FileSystem {
+	return &hideFS{fs: fs, hidden: hidden, openErr: os.ErrNotExist}
+}
+
+// Deny returns a file system like Hide, except that opening
+// the denied entries fails with a permission error
+func Deny(fs http.FileSystem, denied HideFunc) http.FileSystem {
+	return &hideFS{fs: fs, hidden: denied, openErr: os.ErrPermission}
+}
+
+// Unlist returns a file system that leaves entries reported by the
+// unlisted function out of directory listings. Unlike Hide, they can
+// still be opened by name.
+func Unlist(fs http.FileSystem, unlisted HideFunc) http.FileSystem {
+	return &hideFS{fs: fs, hidden: unlisted}
+}
+
+type hideFS struct {
+	fs     http.FileSystem
+	hidden HideFunc
+
+	// openErr is the error of opening hidden entries,
+	// or nil if they may be opened
+	openErr error
+}
+
+// Open implements http.FileSystem
+func (h *hideFS) Open(name string) (http.File, error) {
+
+	name = path.Clean("/" + name)
+	openErr := &os.PathError{Op: "open", Path: name, Err: h.openErr}
+
+	// any hidden parent directory hides the path
+	for i := 1; h.openErr != nil && i < len(name); i++ {
+		if name[i] == '/' && h.hidden(name[:i], true) {
+			return nil, openErr
+		}
+	}
+
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if h.openErr != nil && name != "/" && h.hidden(name, stat.IsDir()) {
+		f.Close()
+		return nil, openErr
+	}
+	if stat.IsDir() {
+		return &hideDir{File: f, dir: name, hidden: h.hidden}, nil
+	}
+	return f, nil
+}
+
+// hideDir filters hidden entries from directory listing
+type hideDir struct {
+	http.File
+	dir    string
+	hidden HideFunc
+}
+
+// Readdir implements http.File
+func (d *hideDir) Readdir(count int) (list []os.FileInfo, err error) {
+	for {
+		var items []os.FileInfo
+		items, err = d.File.Readdir(count)
+		for _, item := range items {
+			if !d.hidden(path.Join(d.dir, item.Name()), item.IsDir()) {
+				list = append(list, item)
+			}
+		}
+		// with a positive count, avoid returning an empty batch
+		// before the end of the directory
+		if count <= 0 || len(list) > 0 || err != nil {
+			return
+		}
+	}
+}
+
+// HidePatterns returns a HideFunc that hides every path matching
+// any of the given patterns
+func HidePatterns(patterns ...*glob.Pattern) HideFunc {
+	return func(name string, isDir bool) bool {
+		for _, p := range patterns {
+			if p.Match(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// IsDotfile is a HideFunc reporting files and directories
+// with a name starting with "."
+func IsDotfile(name string, isDir bool) bool {
+	return strings.HasPrefix(path.Base(name), ".")
+}
+
+// Restrict returns a file system that only exposes the directory tree
+// at prefix. Parent directories of prefix remain accessible, but only
+// list the entry leading to prefix.
+func Restrict(fs http.FileSystem, prefix string) http.FileSystem {
+	prefix = path.Clean("/" + prefix)
+	return Hide(fs, func(name string, isDir bool) bool {
+		if _, ok := within(prefix, name); ok {
+			return false
+		}
+		_, isParent := within(name, prefix)
+		return !isParent
+	})
+}
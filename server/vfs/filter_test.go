@@ -0,0 +1,71 @@
+package vfs_test
+
+import (
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func TestRestrict(t *testing.T) {
+
+	fs := vfs.Restrict(http.Dir("./../../_example"), "/folder1/foo1")
+
+	if want, have := "folder1", readdirNames(t, fs, "/"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if want, have := "foo1", readdirNames(t, fs, "/folder1"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if f, err := fs.Open("/folder1/foo1"); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	} else {
+		f.Close()
+	}
+	for _, name := range []string{"/index.html", "/folder1/foo2"} {
+		if _, err := fs.Open(name); !os.IsNotExist(err) {
+			t.Errorf("%s: expected not exist error, got %#v", name, err)
+		}
+	}
+}
+
+func TestDenyUnlist(t *testing.T) {
+
+	isFoo1 := func(name string, isDir bool) bool {
+		return name == "/folder1/foo1"
+	}
+
+	fs := vfs.Deny(http.Dir("./../../_example"), isFoo1)
+	if want, have := "foo2", readdirNames(t, fs, "/folder1"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if _, err := fs.Open("/folder1/foo1"); !os.IsPermission(err) {
+		t.Errorf("expected permission error, got %#v", err)
+	}
+
+	fs = vfs.Unlist(http.Dir("./../../_example"), isFoo1)
+	if want, have := "foo2", readdirNames(t, fs, "/folder1"); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+	if f, err := fs.Open("/folder1/foo1"); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	} else {
+		f.Close()
+	}
+}
+
+func TestIsDotfile(t *testing.T) {
+	tests := map[string]bool{
+		"/":           false,
+		"/.env":       true,
+		"/.git":       true,
+		"/foo/.hid":   true,
+		"/foo/bar.js": false,
+	}
+	for name, want := range tests {
+		if have := vfs.IsDotfile(name, false); want != have {
+			t.Errorf("%s: expected %v, got %v", name, want, have)
+		}
+	}
+}
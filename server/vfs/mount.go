@@ -0,0 +1,255 @@
+package vfs
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Mount is a file system mounted at a URL path prefix
+type Mount struct {
+	Prefix string
+	FS     http.FileSystem
+}
+
+// Mounts implements http.FileSystem by dispatching every path to the
+// file system mounted at the longest matching prefix. Parent directories
+// of mount points that are not covered by any mounted file system are
+// presented as read-only virtual directories listing the mount points.
+type Mounts struct {
+	mounts  []Mount
+	modTime time.Time
+}
+
+// NewMounts returns a mount table of the given mounts.
+// Prefixes are cleaned and must be unique.
+func NewMounts(mounts ...Mount) (mt *Mounts, err error) {
+
+	mt = &Mounts{
+		mounts:  make([]Mount, len(mounts)),
+		modTime: time.Now(),
+	}
+
+	seen := make(map[string]bool)
+	for i, mount := range mounts {
+		prefix := path.Clean("/" + mount.Prefix)
+		if seen[prefix] {
+			err = fmt.Errorf("vfs: duplicated mount point %#v", prefix)
+			return nil, err
+		}
+		seen[prefix] = true
+		mt.mounts[i] = Mount{Prefix: prefix, FS: mount.FS}
+	}
+
+	// longest prefix first
+	sort.SliceStable(mt.mounts, func(i, j int) bool {
+		return len(mt.mounts[i].Prefix) > len(mt.mounts[j].Prefix)
+	})
+	return
+}
+
+// Mounts returns the mount points in the table
+func (mt *Mounts) Mounts() []Mount {
+	return append([]Mount(nil), mt.mounts...)
+}
+
+// Open implements http.FileSystem
+func (mt *Mounts) Open(name string) (http.File, error) {
+
+	name = path.Clean("/" + name)
+
+	var file http.File
+	var err error = os.ErrNotExist
+	for _, mount := range mt.mounts {
+		if rel, ok := within(mount.Prefix, name); ok {
+			file, err = mount.FS.Open(rel)
+			if err == nil && rel == "/" && mount.Prefix != "/" {
+				file = &renamedFile{File: file, name: path.Base(mount.Prefix)}
+			}
+			break
+		}
+	}
+
+	children := mt.children(name)
+	if err != nil {
+		if len(children) == 0 || !os.IsNotExist(err) {
+			return nil, &os.PathError{Op: "open", Path: name, Err: unwrapPathError(err)}
+		}
+		return &virtualDir{
+			info: virtualInfo{name: path.Base(name), modTime: mt.modTime},
+			list: children,
+		}, nil
+	}
+
+	// add mount points nested inside a mounted directory
+	if len(children) > 0 {
+		if stat, err := file.Stat(); err == nil && stat.IsDir() {
+			return &mountedDir{File: file, extra: children}, nil
+		}
+	}
+	return file, nil
+}
+
+// children returns the file info of mount points, or virtual parent
+// directories of mount points, that are direct children of dir
+func (mt *Mounts) children(dir string) (list []os.FileInfo) {
+	seen := make(map[string]bool)
+	for i := len(mt.mounts) - 1; i >= 0; i-- {
+		mount := mt.mounts[i]
+		rel, ok := within(dir, mount.Prefix)
+		if !ok || rel == "/" {
+			continue
+		}
+		name := strings.SplitN(rel[1:], "/", 2)[0]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var info os.FileInfo = virtualInfo{name: name, modTime: mt.modTime}
+		if path.Join(dir, name) == mount.Prefix {
+			if f, err := mount.FS.Open("/"); err == nil {
+				if stat, err := f.Stat(); err == nil {
+					info = renamedInfo{FileInfo: stat, name: name}
+				}
+				f.Close()
+			}
+		}
+		list = append(list, info)
+	}
+	return
+}
+
+// within reports if name is prefix itself or inside prefix, and
+// returns the path of name relative to prefix (always with leading slash)
+func within(prefix, name string) (rel string, ok bool) {
+	if prefix == "/" {
+		return name, true
+	}
+	if name == prefix {
+		return "/", true
+	}
+	if strings.HasPrefix(name, prefix+"/") {
+		return name[len(prefix):], true
+	}
+	return "", false
+}
+
+// mountedDir is a directory of a mounted file system
+// with mount points nested inside
+type mountedDir struct {
+	http.File
+	extra []os.FileInfo
+	list  []os.FileInfo
+	read  bool
+}
+
+// Readdir implements http.File
+func (d *mountedDir) Readdir(count int) ([]os.FileInfo, error) {
+	if !d.read {
+		list, err := d.File.Readdir(0)
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]bool, len(list))
+		for _, item := range list {
+			names[item.Name()] = true
+		}
+		for _, item := range d.extra {
+			if !names[item.Name()] {
+				list = append(list, item)
+			}
+		}
+		d.list, d.read = list, true
+	}
+	return readdir(&d.list, count)
+}
+
+// virtualDir is a directory that only contains mount points
+type virtualDir struct {
+	info virtualInfo
+	list []os.FileInfo
+}
+
+// Close implements http.File
+func (d *virtualDir) Close() error {
+	return nil
+}
+
+// Read implements http.File
+func (d *virtualDir) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("vfs: %s is a directory", d.info.name)
+}
+
+// Seek implements http.File
+func (d *virtualDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+// Readdir implements http.File
+func (d *virtualDir) Readdir(count int) ([]os.FileInfo, error) {
+	return readdir(&d.list, count)
+}
+
+// Stat implements http.File
+func (d *virtualDir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+// readdir pops up to count items from list,
+// with the semantic of os.File.Readdir
+func readdir(list *[]os.FileInfo, count int) (items []os.FileInfo, err error) {
+	if count <= 0 {
+		items, *list = *list, nil
+		return
+	}
+	if len(*list) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(*list) {
+		count = len(*list)
+	}
+	items, *list = (*list)[:count], (*list)[count:]
+	return
+}
+
+// virtualInfo is the os.FileInfo of a virtual directory
+type virtualInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (fi virtualInfo) Name() string       { return fi.name }
+func (fi virtualInfo) Size() int64        { return 0 }
+func (fi virtualInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (fi virtualInfo) ModTime() time.Time { return fi.modTime }
+func (fi virtualInfo) IsDir() bool        { return true }
+func (fi virtualInfo) Sys() interface{}   { return nil }
+
+// renamedFile overrides the name in the stat of an http.File
+type renamedFile struct {
+	http.File
+	name string
+}
+
+// Stat implements http.File
+func (f *renamedFile) Stat() (os.FileInfo, error) {
+	stat, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return renamedInfo{FileInfo: stat, name: f.name}, nil
+}
+
+// renamedInfo overrides the name of an os.FileInfo
+type renamedInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi renamedInfo) Name() string { return fi.name }
@@ -0,0 +1,108 @@
+package vfs_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func readdirNames(t *testing.T, fs http.FileSystem, name string) string {
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: unexpected error: %s", name, err.Error())
+	}
+	defer f.Close()
+	list, err := f.Readdir(0)
+	if err != nil {
+		t.Fatalf("readdir %s: unexpected error: %s", name, err.Error())
+	}
+	names := make([]string, len(list))
+	for i, item := range list {
+		names[i] = item.Name()
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func TestMounts(t *testing.T) {
+
+	mt, err := vfs.NewMounts(
+		vfs.Mount{Prefix: "/docs", FS: vfs.Dir("./../../_example")},
+		vfs.Mount{Prefix: "/media/foo", FS: vfs.Dir("./../../_example/folder1")},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	// virtual directories
+	if want, have := "docs, media", readdirNames(t, mt, "/"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	if want, have := "foo", readdirNames(t, mt, "/media"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	// mounted file systems
+	if want, have := "folder1, index.html", readdirNames(t, mt, "/docs"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	f, err := mt.Open("/media/foo/foo2")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	b, _ := ioutil.ReadAll(f)
+	f.Close()
+	if want, have := "bar2\n", string(b); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	// mount root takes the name of mount point
+	f, err = mt.Open("/media/foo")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	stat, _ := f.Stat()
+	f.Close()
+	if want, have := "foo", stat.Name(); want != have {
+		t.Errorf("expected %#v, got %#v", want, have)
+	}
+
+	if _, err := mt.Open("/others"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %#v", err)
+	}
+	if _, err := mt.Open("/docs/../others"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %#v", err)
+	}
+}
+
+func TestMountsNested(t *testing.T) {
+
+	mt, err := vfs.NewMounts(
+		vfs.Mount{Prefix: "/", FS: vfs.Dir("./../../_example")},
+		vfs.Mount{Prefix: "/folder1/extra", FS: vfs.Dir("./../../_example")},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := "folder1, index.html", readdirNames(t, mt, "/"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	if want, have := "extra, foo1, foo2", readdirNames(t, mt, "/folder1"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+	if want, have := "folder1, index.html", readdirNames(t, mt, "/folder1/extra"); want != have {
+		t.Errorf("\nexpected: %s\ngot:      %s", want, have)
+	}
+
+	if _, err := vfs.NewMounts(
+		vfs.Mount{Prefix: "/foo", FS: vfs.Dir(".")},
+		vfs.Mount{Prefix: "/foo/", FS: vfs.Dir(".")},
+	); err == nil {
+		t.Errorf("expected error for duplicated mount point")
+	}
+}
@@ -0,0 +1,101 @@
+package vfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotNative is returned for paths of file systems that are
+// not backed by native directories
+var ErrNotNative = errors.New("vfs: not a native file system")
+
+// Native is implemented by file systems backed by native directories,
+// to locate files to write to
+type Native interface {
+	// NativePath returns the native path of the slash separated
+	// path name, which may not exist yet
+	NativePath(name string) (string, error)
+}
+
+// NativePath returns the native path of name in the file system,
+// or ErrNotNative if the file system does not implement Native
+func NativePath(fs http.FileSystem, name string) (string, error) {
+	if native, ok := fs.(Native); ok {
+		return native.NativePath(name)
+	}
+	return "", &os.PathError{Op: "resolve", Path: name, Err: ErrNotNative}
+}
+
+// NativePath implements Native. Like Open, it refuses paths that
+// resolve, through symbolic links, to a location outside of the
+// directory. Missing parent directories are allowed.
+func (d Dir) NativePath(name string) (string, error) {
+	if filepath.Separator != '/' && strings.ContainsRune(name, filepath.Separator) {
+		return "", errors.New("vfs: invalid character in file path")
+	}
+
+	root, err := d.realRoot()
+	if err != nil {
+		return "", err
+	}
+
+	// resolve the longest existing part of the path
+	missing := ""
+	existing := filepath.Join(root, filepath.FromSlash(path.Clean("/"+name)))
+	for {
+		realName, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !Contains(root, realName) {
+				return "", &os.PathError{Op: "resolve", Path: name, Err: os.ErrPermission}
+			}
+			return filepath.Join(realName, missing), nil
+		}
+		if !os.IsNotExist(err) || existing == root {
+			return "", &os.PathError{Op: "resolve", Path: name, Err: unwrapPathError(err)}
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = filepath.Dir(existing)
+	}
+}
+
+// NativePath implements Native for the file system mounted at
+// the longest prefix of name
+func (mt *Mounts) NativePath(name string) (string, error) {
+	name = path.Clean("/" + name)
+	for _, mount := range mt.mounts {
+		if rel, ok := within(mount.Prefix, name); ok {
+			return NativePath(mount.FS, rel)
+		}
+	}
+	return "", &os.PathError{Op: "resolve", Path: name, Err: os.ErrNotExist}
+}
+
+// NativePath implements Native. Hidden paths, and paths in hidden
+// directories, fail like they do in Open.
+func (h *hideFS) NativePath(name string) (string, error) {
+
+	name = path.Clean("/" + name)
+	if h.openErr != nil {
+		hideErr := &os.PathError{Op: "resolve", Path: name, Err: h.openErr}
+		for i := 1; i < len(name); i++ {
+			if name[i] == '/' && h.hidden(name[:i], true) {
+				return "", hideErr
+			}
+		}
+		isDir := false
+		if f, err := h.fs.Open(name); err == nil {
+			if stat, err := f.Stat(); err == nil {
+				isDir = stat.IsDir()
+			}
+			f.Close()
+		}
+		if name != "/" && h.hidden(name, isDir) {
+			return "", hideErr
+		}
+	}
+	return NativePath(h.fs, name)
+}
@@ -0,0 +1,56 @@
+package vfs_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-serve/goserve/server/vfs"
+)
+
+func TestNativePath(t *testing.T) {
+
+	outside := t.TempDir()
+	dir := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlink not supported: %s", err)
+	}
+	realDir, _ := filepath.EvalSymlinks(dir)
+
+	mt, err := vfs.NewMounts(vfs.Mount{Prefix: "/media", FS: vfs.Dir(dir)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	fs := vfs.Hide(mt, vfs.IsDotfile)
+
+	tests := []struct {
+		name   string
+		native string
+		err    func(error) bool
+	}{
+		{"/media/new/file.txt", filepath.Join(realDir, "new", "file.txt"), nil},
+		{"/media/../media/a", filepath.Join(realDir, "a"), nil},
+		{"/media/escape/file.txt", "", os.IsPermission},
+		{"/media/.secret/file.txt", "", os.IsNotExist},
+		{"/other/file.txt", "", os.IsNotExist},
+	}
+	for _, test := range tests {
+		native, err := vfs.NativePath(fs, test.name)
+		if test.err != nil {
+			if !test.err(err) {
+				t.Errorf("%s: unexpected error %#v", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+		} else if want, have := test.native, native; want != have {
+			t.Errorf("%s: expected %#v, got %#v", test.name, want, have)
+		}
+	}
+
+	if _, err := vfs.NativePath(http.Dir(dir), "/a"); err == nil {
+		t.Errorf("expected error for file system that is not native")
+	}
+}
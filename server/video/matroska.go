@@ -0,0 +1,208 @@
+package video
+
+import (
+	"io"
+	"math/bits"
+	"strings"
+)
+
+// IDs of Matroska elements
+const (
+	idEBML         = 0x1A45DFA3
+	idDocType      = 0x4282
+	idSegment      = 0x18538067
+	idCluster      = 0x1F43B675
+	idTracks       = 0x1654AE6B
+	idTrackEntry   = 0xAE
+	idTrackType    = 0x83
+	idCodecID      = 0x86
+	idCodecPrivate = 0x63A2
+)
+
+// types of Matroska tracks
+const (
+	trackVideo = 1
+	trackAudio = 2
+)
+
+// element is an element of a Matroska file
+type element struct {
+	id uint32
+
+	// start and end offsets of its data
+	start, end int64
+}
+
+// readElements calls fn for each element from the offset start
+// to end, until fn returns an error. Elements of unknown size, or
+// cut by the end of the file, take the rest of their parent.
+func readElements(r io.ReadSeeker, start, end int64, fn func(e element) error) error {
+	for start < end {
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		id, n, err := readVint(r, 4, false)
+		if err != nil {
+			return err
+		}
+		size, m, err := readVint(r, 8, true)
+		if err != nil {
+			return err
+		}
+		e := element{id: uint32(id), start: start + int64(n+m), end: end}
+		if size >= 0 && size <= end-e.start {
+			e.end = e.start + size
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		start = e.end
+	}
+	return nil
+}
+
+// readVint reads a variable size integer of up to max bytes,
+// returning its value and size. IDs keep their length marker,
+// sizes with all bits set are unknown, returned as -1.
+func readVint(r io.Reader, max int, isSize bool) (value int64, n int, err error) {
+	var b [8]byte
+	if _, err = io.ReadFull(r, b[:1]); err != nil {
+		return
+	}
+	if n = bits.LeadingZeros8(b[0]) + 1; n > max {
+		return 0, 0, errInvalid
+	}
+	if _, err = io.ReadFull(r, b[1:n]); err != nil {
+		return
+	}
+	value = int64(b[0])
+	if isSize {
+		value &= 0xFF >> uint(n)
+	}
+	for _, c := range b[1:n] {
+		value = value<<8 | int64(c)
+	}
+	if isSize && value == 1<<uint(7*n)-1 {
+		value = -1
+	}
+	return
+}
+
+// data reads the data of the element, up to maxBox bytes
+func data(r io.ReadSeeker, e element) ([]byte, error) {
+	return content(r, box{start: e.start, end: e.end})
+}
+
+// sniffMatroska reads the codecs of the tracks of Matroska
+// and WebM files
+func sniffMatroska(r io.ReadSeeker) (*Format, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	f := &Format{Container: Matroska}
+	var video, audio []string
+
+	// truncated or invalid files keep the tracks read
+	readElements(r, 0, end, func(e element) error {
+		switch e.id {
+		case idEBML:
+			readElements(r, e.start, e.end, func(e element) error {
+				if e.id != idDocType {
+					return nil
+				}
+				if doc, err := data(r, e); err == nil && string(doc) == "webm" {
+					f.Container = WebM
+				}
+				return errDone
+			})
+		case idSegment:
+			readElements(r, e.start, e.end, func(e element) error {
+				switch e.id {
+				case idTracks:
+					readElements(r, e.start, e.end, func(e element) error {
+						if e.id != idTrackEntry {
+							return nil
+						}
+						switch typ, codec := trackEntry(r, e); typ {
+						case trackVideo:
+							video = append(video, codec)
+						case trackAudio:
+							audio = append(audio, codec)
+						}
+						return nil
+					})
+					return errDone
+				case idCluster:
+					// media data follows the tracks
+					return errDone
+				}
+				return nil
+			})
+			return errDone
+		}
+		return nil
+	})
+	for _, codec := range append(video, audio...) {
+		f.add(codec)
+	}
+	return f, nil
+}
+
+// trackEntry returns the type and the codec of a track
+func trackEntry(r io.ReadSeeker, entry element) (typ int, codec string) {
+	var id string
+	var private []byte
+	readElements(r, entry.start, entry.end, func(e element) error {
+		switch e.id {
+		case idTrackType:
+			if b, err := data(r, e); err == nil && len(b) == 1 {
+				typ = int(b[0])
+			}
+		case idCodecID:
+			if b, err := data(r, e); err == nil {
+				id = strings.TrimRight(string(b), "\x00")
+			}
+		case idCodecPrivate:
+			private, _ = data(r, e)
+		}
+		return nil
+	})
+	if id == "" {
+		// cut by the end of the file
+		return 0, ""
+	}
+	return typ, matroskaCodec(id, private)
+}
+
+// matroskaCodec names the codec of a Matroska codec ID,
+// e.g. "V_VP9", with its private data
+func matroskaCodec(id string, private []byte) string {
+	switch {
+	case id == "V_VP8":
+		return "vp8"
+	case id == "V_VP9":
+		return "vp9"
+	case id == "V_AV1":
+		return av1Codec(private)
+	case id == "V_MPEG4/ISO/AVC":
+		return avcCodec("avc1", private)
+	case id == "V_MPEGH/ISO/HEVC":
+		return hevcCodec("hev1", private)
+	case id == "A_OPUS":
+		return "opus"
+	case id == "A_VORBIS":
+		return "vorbis"
+	case strings.HasPrefix(id, "A_AAC"):
+		return aacCodec(private)
+	case id == "A_FLAC":
+		return "flac"
+	case id == "A_MPEG/L3":
+		return "mp3"
+	case id == "A_AC3":
+		return "ac-3"
+	case id == "A_EAC3":
+		return "ec-3"
+	}
+	return id
+}
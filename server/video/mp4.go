@@ -0,0 +1,304 @@
+package video
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxBox is the size of the largest box or element read in memory
+const maxBox = 1 << 20
+
+var errInvalid = errors.New("video: invalid header")
+
+// errDone stops reading boxes or elements
+var errDone = errors.New("video: done")
+
+// isBox reports if the type is the one of a box
+// starting MP4 or QuickTime files
+func isBox(typ string) bool {
+	switch typ {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// box is a box of an MP4 or QuickTime file
+type box struct {
+	typ string
+
+	// start and end offsets of its content
+	start, end int64
+}
+
+// readBoxes calls fn for each box from the offset start to end,
+// until fn returns an error
+func readBoxes(r io.ReadSeeker, start, end int64, fn func(b box) error) error {
+	for start+8 <= end {
+		if _, err := r.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		var header [16]byte
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return err
+		}
+		b := box{typ: string(header[4:8]), start: start + 8}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		switch size {
+		case 0:
+			// up to the end
+			size = end - start
+		case 1:
+			// 64-bit size
+			if _, err := io.ReadFull(r, header[8:]); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			b.start += 8
+		}
+		if size < b.start-start || size > end-start {
+			return errInvalid
+		}
+		b.end = start + size
+		if err := fn(b); err != nil {
+			return err
+		}
+		start = b.end
+	}
+	return nil
+}
+
+// find returns the first box of the path of types in the parent box
+func find(r io.ReadSeeker, parent box, types ...string) (found box, ok bool) {
+	err := readBoxes(r, parent.start, parent.end, func(b box) error {
+		if b.typ != types[0] {
+			return nil
+		}
+		if len(types) > 1 {
+			found, ok = find(r, b, types[1:]...)
+		} else {
+			found, ok = b, true
+		}
+		return errDone
+	})
+	return found, ok && err == errDone
+}
+
+// content reads the content of the box, up to maxBox bytes
+func content(r io.ReadSeeker, b box) ([]byte, error) {
+	if b.end-b.start > maxBox {
+		return nil, errInvalid
+	}
+	if _, err := r.Seek(b.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, b.end-b.start)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+// sniffMP4 reads the codecs of the tracks of MP4 and QuickTime files,
+// which may follow the media data
+func sniffMP4(r io.ReadSeeker) (*Format, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	f := &Format{Container: QuickTime}
+	var video, audio []string
+	known := false
+
+	// truncated or invalid files keep the tracks read
+	readBoxes(r, 0, end, func(b box) error {
+		switch b.typ {
+		case "ftyp":
+			known = true
+			if brand, err := content(r, b); err == nil && len(brand) >= 4 && string(brand[:4]) != "qt  " {
+				f.Container = MP4
+			}
+		case "moov":
+			known = true
+			return readBoxes(r, b.start, b.end, func(trak box) error {
+				if trak.typ != "trak" {
+					return nil
+				}
+				switch handler, codec := trackCodec(r, trak); handler {
+				case "vide":
+					video = append(video, codec)
+				case "soun":
+					audio = append(audio, codec)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if !known {
+		return nil, ErrUnknown
+	}
+	for _, codec := range append(video, audio...) {
+		f.add(codec)
+	}
+	return f, nil
+}
+
+// trackCodec returns the handler type of the track, e.g. "vide"
+// or "soun", and the codec of its first sample description
+func trackCodec(r io.ReadSeeker, trak box) (handler, codec string) {
+	if hdlr, ok := find(r, trak, "mdia", "hdlr"); ok {
+		if data, err := content(r, hdlr); err == nil && len(data) >= 12 {
+			handler = string(data[8:12])
+		}
+	}
+	stsd, ok := find(r, trak, "mdia", "minf", "stbl", "stsd")
+	if !ok {
+		return
+	}
+	// skip the version, flags and entry count
+	stsd.start += 8
+	readBoxes(r, stsd.start, stsd.end, func(entry box) error {
+		data, err := content(r, entry)
+		if err != nil {
+			return err
+		}
+		codec = sampleCodec(handler, entry.typ, data)
+		return errDone
+	})
+	return
+}
+
+// sampleCodec names the codec of a sample description
+func sampleCodec(handler, typ string, entry []byte) string {
+	var children map[string][]byte
+	switch handler {
+	case "vide":
+		if len(entry) >= 78 {
+			children = boxesIn(entry[78:])
+		}
+	case "soun":
+		if len(entry) >= 28 {
+			// QuickTime sound descriptions have more fields
+			// with their version
+			offset := 28
+			switch binary.BigEndian.Uint16(entry[8:]) {
+			case 1:
+				offset += 16
+			case 2:
+				offset += 36
+			}
+			if offset <= len(entry) {
+				children = boxesIn(entry[offset:])
+			}
+			if wave, ok := children["wave"]; ok {
+				children = boxesIn(wave)
+			}
+		}
+	}
+	switch typ {
+	case "avc1", "avc3":
+		return avcCodec(typ, children["avcC"])
+	case "hvc1", "hev1":
+		return hevcCodec(typ, children["hvcC"])
+	case "av01":
+		return av1Codec(children["av1C"])
+	case "vp09":
+		return vp9Codec(children["vpcC"])
+	case "mp4a":
+		return esdsCodec(children["esds"])
+	case "Opus":
+		return "opus"
+	case "fLaC":
+		return "flac"
+	case ".mp3":
+		return "mp3"
+	}
+	return strings.TrimSpace(typ)
+}
+
+// boxesIn returns the content of the boxes in the data, by type
+func boxesIn(data []byte) map[string][]byte {
+	boxes := make(map[string][]byte)
+	for len(data) >= 8 {
+		size := binary.BigEndian.Uint32(data)
+		if size < 8 || uint64(size) > uint64(len(data)) {
+			break
+		}
+		boxes[string(data[4:8])] = data[8:size]
+		data = data[size:]
+	}
+	return boxes
+}
+
+// vp9Codec names a VP9 codec from its VPCodecConfigurationBox
+func vp9Codec(config []byte) string {
+	if len(config) < 7 {
+		return "vp09"
+	}
+	return fmt.Sprintf("vp09.%02d.%02d.%02d", config[4], config[5], config[6]>>4)
+}
+
+// esdsCodec names an MPEG-4 audio codec from its ES descriptor
+func esdsCodec(esds []byte) string {
+	if len(esds) < 4 {
+		return "mp4a.40.2"
+	}
+	tag, es := descriptor(esds[4:])
+	if tag != 0x03 || len(es) < 3 {
+		return "mp4a.40.2"
+	}
+	flags := es[2]
+	es = es[3:]
+	if flags&0x80 != 0 && len(es) >= 2 {
+		// depends on stream
+		es = es[2:]
+	}
+	if flags&0x40 != 0 && len(es) >= 1 && len(es) > int(es[0]) {
+		// URL
+		es = es[1+int(es[0]):]
+	}
+	if flags&0x20 != 0 && len(es) >= 2 {
+		// OCR stream
+		es = es[2:]
+	}
+	tag, config := descriptor(es)
+	if tag != 0x04 || len(config) < 13 {
+		return "mp4a.40.2"
+	}
+	if objectType := config[0]; objectType != 0x40 {
+		// e.g. MP3 as "mp4a.6B"
+		return fmt.Sprintf("mp4a.%02X", objectType)
+	}
+	if tag, specific := descriptor(config[13:]); tag == 0x05 {
+		return aacCodec(specific)
+	}
+	return "mp4a.40.2"
+}
+
+// descriptor returns the tag and the content of the
+// MPEG-4 descriptor at the start of the data
+func descriptor(data []byte) (tag byte, body []byte) {
+	if len(data) < 2 {
+		return
+	}
+	tag = data[0]
+	size, i := 0, 1
+	for {
+		// sizes take up to 4 bytes of 7 bits
+		if i >= len(data) || i > 4 {
+			return 0, nil
+		}
+		b := data[i]
+		i++
+		size = size<<7 | int(b&0x7F)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if size > len(data)-i {
+		return 0, nil
+	}
+	return tag, data[i : i+size]
+}
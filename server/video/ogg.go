@@ -0,0 +1,69 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// maxStreams is the number of logical streams read in Ogg files
+const maxStreams = 16
+
+// oggCodecs are the codecs of Ogg streams, by the
+// start of their first packet
+var oggCodecs = []struct {
+	prefix string
+	codec  string
+	video  bool
+}{
+	{"\x80theora", "theora", true},
+	{"OVP80", "vp8", true},
+	{"\x01vorbis", "vorbis", false},
+	{"OpusHead", "opus", false},
+	{"\x7fFLAC", "flac", false},
+	{"Speex   ", "speex", false},
+}
+
+// sniffOgg reads the codecs of the streams of Ogg files, which
+// start with a page for each stream before any media data
+func sniffOgg(r io.Reader) (*Format, error) {
+	f := &Format{Container: Ogg}
+	var video, audio []string
+	br := bufio.NewReader(r)
+	for i := 0; i < maxStreams; i++ {
+		var header [27]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil || string(header[:4]) != "OggS" {
+			break
+		}
+		if header[5]&0x02 == 0 {
+			// not the beginning of a stream
+			break
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(br, segments); err != nil {
+			break
+		}
+		size := 0
+		for _, segment := range segments {
+			size += int(segment)
+		}
+		packet := make([]byte, size)
+		if _, err := io.ReadFull(br, packet); err != nil {
+			break
+		}
+		for _, c := range oggCodecs {
+			if !bytes.HasPrefix(packet, []byte(c.prefix)) {
+				continue
+			}
+			if c.video {
+				video = append(video, c.codec)
+			} else {
+				audio = append(audio, c.codec)
+			}
+		}
+	}
+	for _, codec := range append(video, audio...) {
+		f.add(codec)
+	}
+	return f, nil
+}
@@ -0,0 +1,204 @@
+// Package video sniffs the container and codecs of video files
+// from their headers, to tell browsers if they may play them
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknown is returned for files in an unknown container
+var ErrUnknown = errors.New("video: unknown container")
+
+// Containers sniffed
+const (
+	MP4       = "mp4"
+	QuickTime = "quicktime"
+	WebM      = "webm"
+	Matroska  = "matroska"
+	Ogg       = "ogg"
+)
+
+// Format is the container and codecs of a video file
+type Format struct {
+	// Container is MP4, QuickTime, WebM, Matroska or Ogg
+	Container string
+
+	// Codecs of the tracks, video first, as in the "codecs"
+	// parameter of RFC 6381, e.g. "avc1.64001F" or "vp9".
+	// Codecs unknown to browsers are named as in their
+	// container, e.g. "apch" for ProRes in QuickTime.
+	Codecs []string
+}
+
+// Sniff reads the container and codecs of a video file. Only
+// headers are read, seeking over the media data.
+func Sniff(r io.ReadSeeker) (*Format, error) {
+	var magic [12]byte
+	n, err := io.ReadFull(r, magic[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, ErrUnknown
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	switch {
+	case n >= 4 && bytes.Equal(magic[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return sniffMatroska(r)
+	case n >= 4 && string(magic[:4]) == "OggS":
+		return sniffOgg(r)
+	case n >= 8 && isBox(string(magic[4:8])):
+		return sniffMP4(r)
+	}
+	return nil, ErrUnknown
+}
+
+// MediaType returns the media type of the file for browsers. Files
+// in QuickTime or Matroska containers with codecs allowed in MP4 or
+// WebM are typed as such, as browsers play them the same.
+func (f *Format) MediaType() string {
+	switch f.Container {
+	case MP4:
+		return "video/mp4"
+	case QuickTime:
+		if f.all(mp4Codec) {
+			return "video/mp4"
+		}
+		return "video/quicktime"
+	case WebM:
+		return "video/webm"
+	case Matroska:
+		if f.all(webmCodec) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case Ogg:
+		for _, codec := range f.Codecs {
+			if codec == "theora" || codec == "vp8" {
+				return "video/ogg"
+			}
+		}
+		return "audio/ogg"
+	}
+	return "application/octet-stream"
+}
+
+// Type returns the media type with its codecs, e.g.
+// `video/webm; codecs="vp9,opus"`, for the type of a
+// source of a <video> element
+func (f *Format) Type() string {
+	if len(f.Codecs) == 0 {
+		return f.MediaType()
+	}
+	return mime.FormatMediaType(f.MediaType(), map[string]string{
+		"codecs": strings.Join(f.Codecs, ","),
+	})
+}
+
+// all reports if all the codecs of the file match
+func (f *Format) all(match func(codec string) bool) bool {
+	for _, codec := range f.Codecs {
+		if !match(codec) {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds a codec, once
+func (f *Format) add(codec string) {
+	for _, c := range f.Codecs {
+		if c == codec {
+			return
+		}
+	}
+	f.Codecs = append(f.Codecs, codec)
+}
+
+// mp4Codec reports if the codec may be in an MP4 file
+func mp4Codec(codec string) bool {
+	switch strings.SplitN(codec, ".", 2)[0] {
+	case "avc1", "avc3", "hvc1", "hev1", "av01", "vp09", "mp4a", "opus", "flac", "mp3", "ac-3", "ec-3":
+		return true
+	}
+	return false
+}
+
+// webmCodec reports if the codec may be in a WebM file
+func webmCodec(codec string) bool {
+	switch strings.SplitN(codec, ".", 2)[0] {
+	case "vp8", "vp9", "vp09", "av01", "opus", "vorbis":
+		return true
+	}
+	return false
+}
+
+// avcCodec names an H.264 codec from its AVCDecoderConfigurationRecord
+func avcCodec(name string, config []byte) string {
+	if len(config) < 4 {
+		return name
+	}
+	return fmt.Sprintf("%s.%02X%02X%02X", name, config[1], config[2], config[3])
+}
+
+// hevcCodec names an H.265 codec from its HEVCDecoderConfigurationRecord
+func hevcCodec(name string, config []byte) string {
+	if len(config) < 13 {
+		return name
+	}
+	space := []string{"", "A", "B", "C"}[config[1]>>6]
+	tier := "L"
+	if config[1]&0x20 != 0 {
+		tier = "H"
+	}
+	// the compatibility flags are written in reverse bit order
+	flags := bits.Reverse32(binary.BigEndian.Uint32(config[2:6]))
+	codec := fmt.Sprintf("%s.%s%d.%X.%s%d", name, space, config[1]&0x1F, flags, tier, config[12])
+	constraints := config[6:12]
+	for len(constraints) > 0 && constraints[len(constraints)-1] == 0 {
+		constraints = constraints[:len(constraints)-1]
+	}
+	for _, b := range constraints {
+		codec += fmt.Sprintf(".%02X", b)
+	}
+	return codec
+}
+
+// av1Codec names an AV1 codec from its AV1CodecConfigurationRecord
+func av1Codec(config []byte) string {
+	if len(config) < 3 {
+		return "av01"
+	}
+	profile, level := config[1]>>5, config[1]&0x1F
+	tier := "M"
+	if config[2]&0x80 != 0 {
+		tier = "H"
+	}
+	depth := 8
+	if config[2]&0x40 != 0 {
+		depth = 10
+		if profile == 2 && config[2]&0x20 != 0 {
+			depth = 12
+		}
+	}
+	return fmt.Sprintf("av01.%d.%02d%s.%02d", profile, level, tier, depth)
+}
+
+// aacCodec names an AAC codec from its AudioSpecificConfig
+func aacCodec(config []byte) string {
+	if len(config) < 1 {
+		return "mp4a.40.2"
+	}
+	objectType := int(config[0] >> 3)
+	if objectType == 31 && len(config) > 1 {
+		objectType = 32 + (int(config[0]&0x07)<<3 | int(config[1]>>5))
+	}
+	return "mp4a.40." + strconv.Itoa(objectType)
+}
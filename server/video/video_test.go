@@ -0,0 +1,195 @@
+package video_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/go-serve/goserve/server/video"
+)
+
+// box returns an MP4 box of the contents
+func box(typ string, contents ...[]byte) []byte {
+	b := append(make([]byte, 4), typ...)
+	for _, content := range contents {
+		b = append(b, content...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+// trak returns an MP4 track of the handler type and sample description
+func trak(handler string, entry []byte) []byte {
+	hdlr := append(make([]byte, 8), handler...)
+	hdlr = append(hdlr, make([]byte, 13)...)
+	return box("trak", box("mdia",
+		box("hdlr", hdlr),
+		box("minf", box("stbl", box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, entry))),
+	))
+}
+
+func visual(typ, configType string, config []byte) []byte {
+	return box(typ, make([]byte, 78), box(configType, config))
+}
+
+// aac returns an MP4 sample description of AAC-LC
+func aac() []byte {
+	esds := []byte{
+		0, 0, 0, 0,
+		0x03, 22, 0, 1, 0,
+		0x04, 17, 0x40, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0x05, 2, 0x12, 0x10,
+	}
+	return box("mp4a", make([]byte, 28), box("esds", esds))
+}
+
+// element returns a Matroska element of the data, of
+// unknown size without data
+func element(id uint32, data ...[]byte) []byte {
+	var b []byte
+	for shift := uint(24); shift < 32; shift -= 8 {
+		if c := byte(id >> shift); c != 0 || len(b) > 0 {
+			b = append(b, c)
+		}
+	}
+	if len(data) == 0 {
+		return append(b, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	}
+	var content []byte
+	for _, d := range data {
+		content = append(content, d...)
+	}
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(content)))
+	size[0] = 0x01
+	return append(append(b, size...), content...)
+}
+
+func track(typ byte, codec string, private []byte) []byte {
+	entry := element(0xAE, element(0x83, []byte{typ}), element(0x86, []byte(codec)))
+	if private != nil {
+		entry = element(0xAE, element(0x83, []byte{typ}), element(0x86, []byte(codec)), element(0x63A2, private))
+	}
+	return entry
+}
+
+func matroska(docType string, tracks ...[]byte) []byte {
+	b := element(0x1A45DFA3, element(0x4282, []byte(docType)))
+	segment := element(0x18538067)
+	segment = append(segment, element(0x1549A966, []byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40})...)
+	segment = append(segment, element(0x1654AE6B, tracks...)...)
+	segment = append(segment, element(0x1F43B675, make([]byte, 64))...)
+	return append(b, segment...)
+}
+
+// page returns an Ogg page starting a stream with the packet
+func page(packet string) []byte {
+	b := append([]byte("OggS"), 0, 0x02)
+	b = append(b, make([]byte, 20)...)
+	return append(append(b, 1, byte(len(packet))), packet...)
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		file []byte
+		want string
+	}{
+		{
+			name: "mp4 with the movie after the media data",
+			file: bytes.Join([][]byte{
+				box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2avc1mp41")),
+				box("mdat", make([]byte, 256)),
+				box("moov",
+					box("mvhd", make([]byte, 100)),
+					trak("vide", visual("avc1", "avcC", []byte{1, 0x64, 0x00, 0x1F, 0xFF})),
+					trak("soun", aac()),
+				),
+			}, nil),
+			want: `mp4 video/mp4; codecs="avc1.64001F,mp4a.40.2"`,
+		},
+		{
+			name: "mp4 with hevc, av1 and subtitles",
+			file: bytes.Join([][]byte{
+				box("ftyp", []byte("mp42\x00\x00\x00\x00")),
+				box("moov",
+					trak("vide", visual("hvc1", "hvcC", []byte{1, 0x01, 0x60, 0, 0, 0, 0xB0, 0, 0, 0, 0, 0, 93})),
+					trak("vide", visual("av01", "av1C", []byte{0x81, 0x08, 0x0C, 0})),
+					trak("text", box("tx3g", make([]byte, 40))),
+				),
+			}, nil),
+			want: `mp4 video/mp4; codecs="hvc1.1.6.L93.B0,av01.0.08M.08"`,
+		},
+		{
+			name: "quicktime with h264",
+			file: bytes.Join([][]byte{
+				box("ftyp", []byte("qt  \x00\x00\x02\x00qt  ")),
+				box("moov", trak("vide", visual("avc1", "avcC", []byte{1, 0x4D, 0x40, 0x28}))),
+			}, nil),
+			want: `quicktime video/mp4; codecs=avc1.4D4028`,
+		},
+		{
+			name: "quicktime with prores",
+			file: bytes.Join([][]byte{
+				box("ftyp", []byte("qt  \x00\x00\x02\x00qt  ")),
+				box("moov", trak("vide", box("apch", make([]byte, 78))), trak("soun", aac())),
+			}, nil),
+			want: `quicktime video/quicktime; codecs="apch,mp4a.40.2"`,
+		},
+		{
+			name: "webm",
+			file: matroska("webm", track(2, "A_OPUS", nil), track(1, "V_VP9", nil)),
+			want: `webm video/webm; codecs="vp9,opus"`,
+		},
+		{
+			name: "matroska playable as webm",
+			file: matroska("matroska", track(1, "V_AV1", []byte{0x81, 0x04, 0x4C, 0})),
+			want: `matroska video/webm; codecs=av01.0.04M.10`,
+		},
+		{
+			name: "matroska",
+			file: matroska("matroska", track(1, "V_MPEG4/ISO/AVC", []byte{1, 0x64, 0x00, 0x28}), track(2, "A_AAC", []byte{0x12, 0x10}), track(17, "S_TEXT/UTF8", nil)),
+			want: `matroska video/x-matroska; codecs="avc1.640028,mp4a.40.2"`,
+		},
+		{
+			name: "ogg",
+			file: bytes.Join([][]byte{page("\x01vorbis\x00\x00\x00\x00"), page("\x80theora\x03\x02"), page("")[:4]}, nil),
+			want: `ogg video/ogg; codecs="theora,vorbis"`,
+		},
+		{
+			name: "ogg audio",
+			file: page("OpusHead\x01\x02"),
+			want: `ogg audio/ogg; codecs=opus`,
+		},
+	}
+	for _, test := range tests {
+		f, err := video.Sniff(bytes.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err.Error())
+			continue
+		}
+		if want, have := test.want, f.Container+" "+f.Type(); want != have {
+			t.Errorf("%s: expected %s, got %s", test.name, want, have)
+		}
+	}
+}
+
+func TestSniffUnknown(t *testing.T) {
+	for _, file := range []string{"", "not a video", "\x00\x00\x00\x08ftyp"[:6], "\x00\x00\x00\x10mdat"} {
+		if _, err := video.Sniff(strings.NewReader(file)); err != video.ErrUnknown {
+			t.Errorf("%#v: expected ErrUnknown, got %v", file, err)
+		}
+	}
+}
+
+func TestSniffTruncated(t *testing.T) {
+	file := matroska("webm", track(1, "V_VP8", nil), track(2, "A_VORBIS", nil))
+	f, err := video.Sniff(bytes.NewReader(file[:len(file)-90]))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if want, have := `video/webm; codecs=vp8`, f.Type(); want != have {
+		t.Errorf("expected %s, got %s", want, have)
+	}
+}